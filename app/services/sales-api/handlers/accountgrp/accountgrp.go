@@ -0,0 +1,122 @@
+// Package accountgrp maintains the group of handlers for password recovery
+// and email verification.
+package accountgrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ardanlabs/service/business/core/account"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/web/trusted"
+	"github.com/ardanlabs/service/foundation/web"
+)
+
+// Handlers manages the set of account endpoints.
+type Handlers struct {
+	Account account.Core
+}
+
+// VerifyStatus represents the response to verifying an email.
+type VerifyStatus struct {
+	Status string `json:"status"`
+}
+
+// ForgotPassword emails a password reset link. It always responds the same
+// way so it can't be used to find out which emails exist.
+func (h Handlers) ForgotPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var fp account.ForgotPassword
+	if err := web.Decode(r, &fp); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	if err := h.Account.ForgotPassword(ctx, fp, v.Now); err != nil {
+		return fmt.Errorf("forgot password: %w", err)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusAccepted)
+}
+
+// ResetPassword sets a new password using the emailed token.
+func (h Handlers) ResetPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var rp account.ResetPassword
+	if err := web.Decode(r, &rp); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	if err := h.Account.ResetPassword(ctx, rp, v.Now); err != nil {
+		switch {
+		case errors.Is(err, account.ErrInvalidToken):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("reset password: %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Verify marks the email of a user as verified using the emailed token.
+func (h Handlers) Verify(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		return trusted.NewRequestError(account.ErrInvalidToken, http.StatusBadRequest)
+	}
+
+	if err := h.Account.VerifyEmail(ctx, token, v.Now); err != nil {
+		switch {
+		case errors.Is(err, account.ErrInvalidToken):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("verify email: %w", err)
+		}
+	}
+
+	status := VerifyStatus{
+		Status: "email verified",
+	}
+
+	return web.Respond(ctx, w, status, http.StatusOK)
+}
+
+// ResendVerification emails a new verification link to the authenticated
+// user.
+func (h Handlers) ResendVerification(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	if err := h.Account.SendVerification(ctx, claims.Subject, v.Now); err != nil {
+		switch {
+		case errors.Is(err, account.ErrNotFound):
+			return trusted.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("userID[%s]: %w", claims.Subject, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusAccepted)
+}
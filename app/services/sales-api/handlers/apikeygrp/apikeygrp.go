@@ -0,0 +1,110 @@
+// Package apikeygrp maintains the group of handlers for API key access.
+package apikeygrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ardanlabs/service/business/core/apikey"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/web/trusted"
+	"github.com/ardanlabs/service/foundation/web"
+)
+
+// Handlers manages the set of API key endpoints. Users manage their own keys.
+type Handlers struct {
+	APIKey apikey.Core
+}
+
+// Create generates a new API key for the authenticated user. Keys can't be
+// created with an API key, so a leaked key can't be used to mint more.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	if claims.APIKeyID != "" {
+		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	var nk apikey.NewAPIKey
+	if err := web.Decode(r, &nk); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	key, err := h.APIKey.Create(ctx, claims.Subject, nk, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, apikey.ErrInvalidScope), errors.Is(err, apikey.ErrExpired):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, apikey.ErrNotFound):
+			return trusted.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("apikey[%+v]: %w", &nk, err)
+		}
+	}
+
+	return web.Respond(ctx, w, key, http.StatusCreated)
+}
+
+// Query returns the API keys of the authenticated user.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	keys, err := h.APIKey.QueryByUserID(ctx, claims.Subject)
+	if err != nil {
+		return fmt.Errorf("unable to query for api keys: %w", err)
+	}
+
+	return web.Respond(ctx, w, keys, http.StatusOK)
+}
+
+// Revoke revokes an API key. Users allowed to manage API keys can revoke the
+// keys of any user.
+func (h Handlers) Revoke(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	keyID := web.Param(r, "key_id")
+
+	key, err := h.APIKey.QueryByID(ctx, keyID)
+	if err != nil {
+		switch {
+		case errors.Is(err, apikey.ErrInvalidID):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, apikey.ErrNotFound):
+			return trusted.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", keyID, err)
+		}
+	}
+
+	// If you can't manage keys and are looking to revoke someone else's key.
+	if !auth.Can(ctx, auth.PermAPIKeysManage) && claims.Subject != key.UserID {
+		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	if err := h.APIKey.Revoke(ctx, keyID, v.Now); err != nil {
+		return fmt.Errorf("ID[%s]: %w", keyID, err)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
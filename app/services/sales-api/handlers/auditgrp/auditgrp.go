@@ -0,0 +1,95 @@
+// Package auditgrp maintains the group of handlers for the audit log.
+package auditgrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/audit"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/business/web/trusted"
+	"github.com/ardanlabs/service/foundation/web"
+)
+
+// Handlers manages the set of audit log endpoints.
+type Handlers struct {
+	Audit audit.Core
+}
+
+// Query returns a page of audit entries, most recent first, filtered by
+// entity, actor and time range.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	qs := r.URL.Query()
+
+	pageNumber := 1
+	if page := qs.Get("page"); page != "" {
+		var err error
+		pageNumber, err = strconv.Atoi(page)
+		if err != nil || pageNumber < 1 {
+			return trusted.NewRequestError(fmt.Errorf("invalid page format [%s]", page), http.StatusBadRequest)
+		}
+	}
+
+	rowsPerPage := 20
+	if rows := qs.Get("rows"); rows != "" {
+		var err error
+		rowsPerPage, err = strconv.Atoi(rows)
+		if err != nil || rowsPerPage < 1 {
+			return trusted.NewRequestError(fmt.Errorf("invalid rows format [%s]", rows), http.StatusBadRequest)
+		}
+	}
+
+	filter, err := parseFilter(qs)
+	if err != nil {
+		return trusted.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	entries, err := h.Audit.Query(ctx, filter, pageNumber, rowsPerPage)
+	if err != nil {
+		if validate.IsFieldErrors(err) {
+			return err
+		}
+		return fmt.Errorf("unable to query for audit entries: %w", err)
+	}
+
+	return web.Respond(ctx, w, entries, http.StatusOK)
+}
+
+// parseFilter constructs the query filter from the query string.
+func parseFilter(qs url.Values) (audit.QueryFilter, error) {
+	var filter audit.QueryFilter
+
+	if entityType := qs.Get("entity_type"); entityType != "" {
+		filter.EntityType = &entityType
+	}
+
+	if entityID := qs.Get("entity_id"); entityID != "" {
+		filter.EntityID = &entityID
+	}
+
+	if actorID := qs.Get("actor_id"); actorID != "" {
+		filter.ActorID = &actorID
+	}
+
+	if date := qs.Get("start_date"); date != "" {
+		t, err := time.Parse(time.RFC3339, date)
+		if err != nil {
+			return audit.QueryFilter{}, fmt.Errorf("invalid start_date format [%s]", date)
+		}
+		filter.StartDate = &t
+	}
+
+	if date := qs.Get("end_date"); date != "" {
+		t, err := time.Parse(time.RFC3339, date)
+		if err != nil {
+			return audit.QueryFilter{}, fmt.Errorf("invalid end_date format [%s]", date)
+		}
+		filter.EndDate = &t
+	}
+
+	return filter, nil
+}
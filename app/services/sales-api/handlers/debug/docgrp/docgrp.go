@@ -0,0 +1,24 @@
+// Package docgrp maintains the group of handlers for documenting the API.
+package docgrp
+
+import (
+	"net/http"
+)
+
+// Handlers manages the set of documentation endpoints.
+type Handlers struct {
+	Spec []byte
+}
+
+// OpenAPI returns the OpenAPI document describing the API. The document is
+// generated once at startup since the routes don't change while running.
+func (h Handlers) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(h.Spec)
+}
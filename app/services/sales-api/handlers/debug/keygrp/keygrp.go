@@ -0,0 +1,103 @@
+// Package keygrp maintains the group of handlers for managing the signing
+// keys at runtime.
+package keygrp
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/keyrotation"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/foundation/keystore"
+	"go.uber.org/zap"
+)
+
+// Handlers manages the set of key endpoints.
+type Handlers struct {
+	Log         *zap.SugaredLogger
+	Auth        *auth.Auth
+	KeyStore    *keystore.KeyStore
+	KeyRotation keyrotation.Core
+	GracePeriod time.Duration
+}
+
+// Reload rereads the key files from disk so new keys can be used to verify
+// tokens and removed keys no longer are. Removing the active key fails the
+// reload.
+func (h Handlers) Reload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.KeyStore.Reload(h.Auth.ActiveKID()); err != nil {
+		h.Log.Errorw("keys reload", "ERROR", err)
+		h.respond(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.Log.Infow("keys reload", "status", "keys reloaded", "kids", h.KeyStore.KIDs())
+	h.respond(w, r, http.StatusOK, "ok")
+}
+
+// Activate changes the key used to sign new tokens to the kid provided in the
+// query string. The previous key keeps verifying tokens for the grace period.
+// The rotation is saved in the database, where the other instances pick it
+// up, so the key needs to be deployed with every instance.
+func (h Handlers) Activate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	kid := r.URL.Query().Get("kid")
+	prevKID := h.Auth.ActiveKID()
+
+	if _, err := h.KeyStore.PrivateKey(kid); err != nil {
+		h.Log.Errorw("keys activate", "kid", kid, "ERROR", err)
+		h.respond(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rot, err := h.KeyRotation.Activate(r.Context(), kid, prevKID, h.GracePeriod, time.Now().UTC())
+	if err != nil {
+		h.Log.Errorw("keys activate", "kid", kid, "ERROR", err)
+		h.respond(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := h.Auth.SetRotation(rot); err != nil {
+		h.Log.Errorw("keys activate", "kid", kid, "ERROR", err)
+		h.respond(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.Log.Infow("keys activate", "status", "active key changed", "kid", kid, "retired", prevKID, "grace", h.GracePeriod)
+	h.respond(w, r, http.StatusOK, "ok")
+}
+
+func (h Handlers) respond(w http.ResponseWriter, r *http.Request, statusCode int, status string) {
+	data := struct {
+		Status    string `json:"status"`
+		ActiveKID string `json:"active_kid"`
+	}{
+		Status:    status,
+		ActiveKID: h.Auth.ActiveKID(),
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		h.Log.Errorw("keys", "ERROR", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if _, err := w.Write(jsonData); err != nil {
+		h.Log.Errorw("keys", "ERROR", err)
+	}
+
+	h.Log.Infow("keys", "statusCode", statusCode, "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr)
+}
@@ -3,46 +3,471 @@ package handlers
 
 import (
 	"expvar"
+	"net"
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"time"
 
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/accountgrp"
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/apikeygrp"
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/auditgrp"
 	"github.com/ardanlabs/service/app/services/sales-api/handlers/debug/checkgrp"
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/debug/docgrp"
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/debug/keygrp"
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/jwksgrp"
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/mfagrp"
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/productgrp"
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/revocationgrp"
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/rolegrp"
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/salegrp"
 	"github.com/ardanlabs/service/app/services/sales-api/handlers/testgrp"
 	"github.com/ardanlabs/service/app/services/sales-api/handlers/usergrp"
+	"github.com/ardanlabs/service/business/core/account"
+	"github.com/ardanlabs/service/business/core/apikey"
+	"github.com/ardanlabs/service/business/core/audit"
+	"github.com/ardanlabs/service/business/core/keyrotation"
+	"github.com/ardanlabs/service/business/core/mfa"
+	"github.com/ardanlabs/service/business/core/product"
+	"github.com/ardanlabs/service/business/core/refresh"
+	"github.com/ardanlabs/service/business/core/revocation"
+	"github.com/ardanlabs/service/business/core/role"
+	"github.com/ardanlabs/service/business/core/sale"
 	"github.com/ardanlabs/service/business/core/user"
 	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/lockout"
+	"github.com/ardanlabs/service/business/sys/metrics"
+	"github.com/ardanlabs/service/business/sys/ratelimit"
 	"github.com/ardanlabs/service/business/web/mid"
+	"github.com/ardanlabs/service/business/web/trusted"
+	"github.com/ardanlabs/service/foundation/keystore"
+	"github.com/ardanlabs/service/foundation/mail"
+	"github.com/ardanlabs/service/foundation/openapi"
+	"github.com/ardanlabs/service/foundation/tracer"
 	"github.com/ardanlabs/service/foundation/web"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
 
+// APIMuxConfig contains all the mandatory systems required by handlers.
+type APIMuxConfig struct {
+	Shutdown        chan os.Signal
+	Log             *zap.SugaredLogger
+	Auth            *auth.Auth
+	DB              *sqlx.DB
+	Revocations     *revocation.Cache
+	Policy          *role.Policy
+	RefreshTokenTTL time.Duration
+	JWKSMaxAge      time.Duration
+	AccountLockout  lockout.Config
+	IPLockout       lockout.Config
+	MFA             mfa.Config
+	Account         account.Config
+	Mailer          mail.Mailer
+	RequireIfMatch  bool
+	Tracer          *tracer.Tracer
+	RateLimitStore  ratelimit.Store
+	RateLimit       ratelimit.Limit
+	IPRateLimit     ratelimit.Limit
+	TrustedProxies  []*net.IPNet
+}
+
 // APIMux constructs a http.Handler with all application routes defined.
-func APIMux(shutdown chan os.Signal, log *zap.SugaredLogger, a *auth.Auth, db *sqlx.DB) *web.App {
-	app := web.NewApp(shutdown, mid.Logger(log), mid.Error(log), mid.Metrics(), mid.Panics())
+func APIMux(cfg APIMuxConfig) *web.App {
+	log := cfg.Log
+	a := cfg.Auth
+	db := cfg.DB
 
-	app.Handle(http.MethodGet, "/test", testgrp.Handler)
-	app.Handle(http.MethodGet, "/testauth", testgrp.Handler, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app := web.NewApp(cfg.Shutdown, mid.Logger(log), mid.Metrics(), mid.Error(log), mid.Panics(), mid.Policy(cfg.Policy))
+	app.SetTracer(cfg.Tracer)
+	app.SetTrustedProxies(cfg.TrustedProxies)
 
-	authen := mid.Authenticate(a)
+	// Requests authenticate with a token or an API key.
+	keys := apikey.NewCore(log, db)
+	authen := mid.Authenticate(a, cfg.Revocations, keys)
 	admin := mid.Authorize(auth.RoleAdmin)
 
+	app.Handle(http.MethodGet, "/test", testgrp.Handler).Describe(web.Doc{
+		Summary:  "Check the service responds.",
+		Response: struct{ Status string }{},
+		Public:   true,
+	})
+	app.Handle(http.MethodGet, "/testauth", testgrp.Handler, authen, admin).Describe(web.Doc{
+		Summary:  "Check the service authorizes admins.",
+		Response: struct{ Status string }{},
+		Requires: []web.Requirement{{auth.RoleAdmin}},
+	})
+
+	// Every client of the API gets its own limit. The routes taking
+	// credentials or sending mail are limited further, and so are the bulk
+	// imports since each of them can hold the database for a while.
+	limiter := ratelimit.New(log, cfg.RateLimitStore, ratelimit.Config{
+		Default: cfg.RateLimit,
+		Routes: map[string]ratelimit.Limit{
+			"GET /v1/users/token":            {Requests: 10, Period: time.Minute},
+			"POST /v1/users/token/mfa":       {Requests: 10, Period: time.Minute},
+			"POST /v1/users/password/forgot": {Requests: 5, Period: time.Minute},
+			"POST /v1/users/password/reset":  {Requests: 5, Period: time.Minute},
+			"POST /v1/users/verify":          {Requests: 5, Period: time.Minute},
+			"POST /v1/users/import":          {Requests: 5, Period: time.Minute},
+		},
+	})
+	limit := mid.RateLimit(limiter)
+
+	// Requests failing authentication never reach the limit of their client,
+	// yet each API key costs a lookup, so every address gets a looser limit
+	// checked before authenticating.
+	ipLimiter := ratelimit.New(log, cfg.RateLimitStore, ratelimit.Config{
+		Default: cfg.IPRateLimit,
+	})
+	ipLimit := mid.RateLimitIP(ipLimiter)
+
+	// The API is versioned by path prefix. Requests without a version are
+	// routed by the version parameter of their Accept header, or to v1.
+	v1 := app.Version("v1")
+	app.SetDefaultVersion("v1")
+
+	// Most routes require the request to be authenticated.
+	api := v1.Group("", ipLimit, authen, limit)
+	users := api.Group("/users")
+
 	// Register user management and authentication endpoints.
 	ugh := usergrp.Handlers{
-		User: user.NewCore(log, db),
-		Auth: a,
+		Log:            log,
+		User:           user.NewCore(log, db),
+		Refresh:        refresh.NewCore(log, db, cfg.RefreshTokenTTL),
+		Auth:           a,
+		MFA:            mfa.NewCore(log, db, cfg.MFA),
+		Account:        account.NewCore(log, db, cfg.Mailer, cfg.Account),
+		AccountLockout: lockout.New(log, "account", cfg.AccountLockout),
+		IPLockout:      lockout.New(log, "ip", cfg.IPLockout),
+		MFALockout:     lockout.New(log, "mfa", cfg.AccountLockout),
+		RequireIfMatch: cfg.RequireIfMatch,
+	}
+	tokens := v1.Group("/users/token", limit)
+	tokens.Handle(http.MethodGet, "", ugh.Token).Describe(web.Doc{
+		Summary:  "Get tokens with Basic auth, or a challenge when multi-factor authentication is required.",
+		Response: usergrp.Tokens{},
+		Public:   true,
+	})
+	tokens.Handle(http.MethodPost, "/refresh", ugh.RefreshToken).Describe(web.Doc{
+		Summary:  "Exchange a refresh token for new tokens.",
+		Request:  usergrp.RefreshRequest{},
+		Response: usergrp.Tokens{},
+		Public:   true,
+	})
+	tokens.Handle(http.MethodPost, "/revoke", ugh.RevokeToken).Describe(web.Doc{
+		Summary: "Revoke a refresh token.",
+		Request: usergrp.RefreshRequest{},
+		Public:  true,
+	})
+	tokens.Handle(http.MethodPost, "/mfa", ugh.TokenMFA).Describe(web.Doc{
+		Summary:  "Answer a multi-factor authentication challenge for tokens.",
+		Request:  mfa.Answer{},
+		Response: usergrp.Tokens{},
+		Public:   true,
+	})
+	tokens.Handle(http.MethodPost, "/mfa/enroll", ugh.TokenMFAEnroll).Describe(web.Doc{
+		Summary:  "Enroll in multi-factor authentication with a challenge.",
+		Request:  usergrp.EnrollRequest{},
+		Response: mfa.Enrollment{},
+		Public:   true,
+	})
+
+	// Register password recovery and email verification endpoints.
+	agh := accountgrp.Handlers{
+		Account: ugh.Account,
+	}
+	v1.Handle(http.MethodPost, "/users/password/forgot", agh.ForgotPassword, limit).Describe(web.Doc{
+		Summary: "Email a password reset token.",
+		Request: account.ForgotPassword{},
+		Status:  http.StatusAccepted,
+		Public:  true,
+	})
+	v1.Handle(http.MethodPost, "/users/password/reset", agh.ResetPassword, limit).Describe(web.Doc{
+		Summary: "Reset a password with an emailed token.",
+		Request: account.ResetPassword{},
+		Public:  true,
+	})
+	v1.Handle(http.MethodGet, "/users/verify", agh.Verify, limit).Describe(web.Doc{
+		Summary:  "Verify an email with an emailed token.",
+		Query:    []web.QueryParam{{Name: "token", Description: "The emailed token."}},
+		Response: accountgrp.VerifyStatus{},
+		Public:   true,
+	})
+	users.Handle(http.MethodPost, "/verify", agh.ResendVerification).Describe(web.Doc{
+		Summary: "Email a new verification token to the authenticated user.",
+		Status:  http.StatusAccepted,
+	})
+
+	// Register API key management endpoints.
+	kgh := apikeygrp.Handlers{
+		APIKey: keys,
+	}
+	users.Handle(http.MethodGet, "/apikeys", kgh.Query).Describe(web.Doc{
+		Summary:  "List the API keys of the authenticated user.",
+		Response: []apikey.APIKey{},
+	})
+	users.Handle(http.MethodPost, "/apikeys", kgh.Create).Describe(web.Doc{
+		Summary:  "Create an API key for the authenticated user.",
+		Request:  apikey.NewAPIKey{},
+		Response: apikey.CreatedAPIKey{},
+		Status:   http.StatusCreated,
+	})
+	users.Handle(http.MethodDelete, "/apikeys/:key_id", kgh.Revoke).Describe(web.Doc{
+		Summary: "Revoke an API key.",
+	})
+
+	// Register multi-factor authentication enrollment endpoints.
+	mgh := mfagrp.Handlers{
+		MFA:  ugh.MFA,
+		User: ugh.User,
+	}
+	users.Handle(http.MethodGet, "/mfa", mgh.Status).Describe(web.Doc{
+		Summary:  "Report the multi-factor authentication status of the authenticated user.",
+		Response: mfa.Status{},
+	})
+	users.Handle(http.MethodPost, "/mfa/enroll", mgh.Enroll).Describe(web.Doc{
+		Summary:  "Start enrolling in multi-factor authentication.",
+		Response: mfa.Enrollment{},
+	})
+	users.Handle(http.MethodPost, "/mfa/confirm", mgh.Confirm).Describe(web.Doc{
+		Summary: "Enable multi-factor authentication with a code.",
+		Request: mfagrp.Code{},
+	})
+	users.Handle(http.MethodPost, "/mfa/disable", mgh.Disable).Describe(web.Doc{
+		Summary: "Disable multi-factor authentication with a code.",
+		Request: mfagrp.Code{},
+	})
+
+	// Register the public keys for token verification by other services.
+	jgh := jwksgrp.Handlers{
+		Auth:   a,
+		MaxAge: cfg.JWKSMaxAge,
+	}
+	app.Handle(http.MethodGet, "/.well-known/jwks.json", jgh.JWKS).Describe(web.Doc{
+		Summary:  "Get the public keys tokens are signed with.",
+		Response: map[string]interface{}{},
+		Public:   true,
+	})
+
+	// Register user management endpoints.
+	users.Handle(http.MethodGet, "", ugh.Query, mid.Require(auth.PermUsersRead)).Describe(web.Doc{
+		Summary:  "List users a page at a time.",
+		Query:    userQuery,
+		Response: user.QueryResult{},
+		Requires: []web.Requirement{{auth.PermUsersRead}},
+	})
+	users.Handle(http.MethodGet, "/:id", ugh.QueryByID, mid.RequireOrSelf("id", auth.PermUsersRead)).Describe(web.Doc{
+		Summary:  "Get a user. Users can always get themselves.",
+		Query:    []web.QueryParam{{Name: "includeDeleted", Type: "boolean"}},
+		Response: user.User{},
+		Requires: []web.Requirement{{auth.PermUsersRead}},
+	})
+	users.Handle(http.MethodPost, "", ugh.Create, mid.Require(auth.PermUsersWrite)).Describe(web.Doc{
+		Summary:  "Create a user.",
+		Request:  user.NewUser{},
+		Response: user.User{},
+		Status:   http.StatusCreated,
+		Requires: []web.Requirement{{auth.PermUsersWrite}},
+	})
+	users.Handle(http.MethodPut, "/:id", ugh.Update, mid.Require(auth.PermUsersWrite)).Describe(web.Doc{
+		Summary:  "Update a user, at the version in the If-Match header when there is one.",
+		Request:  user.UpdateUser{},
+		Requires: []web.Requirement{{auth.PermUsersWrite}},
+	})
+	users.Handle(http.MethodPatch, "/:id", ugh.Patch, mid.Require(auth.PermUsersWrite)).Describe(web.Doc{
+		Summary:  "Change a user with a JSON Merge Patch or JSON Patch document.",
+		Response: user.User{},
+		Requires: []web.Requirement{{auth.PermUsersWrite}},
+	})
+	users.Handle(http.MethodDelete, "/:id", ugh.Delete, mid.Require(auth.PermUsersWrite)).Describe(web.Doc{
+		Summary:  "Delete a user, who can be restored until purged.",
+		Requires: []web.Requirement{{auth.PermUsersWrite}},
+	})
+	users.Handle(http.MethodPost, "/:id/restore", ugh.Restore, mid.Require(auth.PermUsersWrite)).Describe(web.Doc{
+		Summary:  "Restore a deleted user.",
+		Response: user.User{},
+		Requires: []web.Requirement{{auth.PermUsersWrite}},
+	})
+	users.Handle(http.MethodPost, "/import", ugh.Import, mid.Require(auth.PermUsersWrite)).Describe(web.Doc{
+		Summary: "Import up to 200 users from a CSV or NDJSON body of up to 4MB. Every row needs a password.",
+		Query: []web.QueryParam{
+			{Name: "format", Description: "csv or ndjson, defaulting to the Content-Type."},
+			{Name: "mode", Description: "atomic or best-effort."},
+			{Name: "dryRun", Type: "boolean"},
+		},
+		Response: user.ImportResult{},
+		Requires: []web.Requirement{{auth.PermUsersWrite}},
+	})
+	users.Handle(http.MethodGet, "/export", ugh.Export, mid.Require(auth.PermUsersRead)).Describe(web.Doc{
+		Summary: "Export users as CSV or NDJSON, without passwords. A password must be added to every row to import the document.",
+		Query: append([]web.QueryParam{
+			{Name: "format", Description: "csv or ndjson, defaulting to the Accept header."},
+		}, userFilter...),
+		Status:   http.StatusOK,
+		Requires: []web.Requirement{{auth.PermUsersRead}},
+	})
+
+	// Register token revocation endpoints.
+	rgh := revocationgrp.Handlers{
+		Revocation: revocation.NewCore(log, db),
+		Cache:      cfg.Revocations,
+	}
+	api.Handle(http.MethodPost, "/revocations", rgh.Create, mid.Require(auth.PermTokensRevoke)).Describe(web.Doc{
+		Summary:  "Revoke a token, or all the tokens of a user.",
+		Request:  revocation.NewRevocation{},
+		Response: revocation.Revocation{},
+		Status:   http.StatusCreated,
+		Requires: []web.Requirement{{auth.PermTokensRevoke}},
+	})
+
+	// Register role and permission management endpoints.
+	rlgh := rolegrp.Handlers{
+		Role:   role.NewCore(log, db),
+		Policy: cfg.Policy,
 	}
-	app.Handle(http.MethodGet, "/users/token", ugh.Token)
-	app.Handle(http.MethodGet, "/users/:page/:rows", ugh.Query, authen, admin)
-	app.Handle(http.MethodGet, "/users/:id", ugh.QueryByID, authen)
-	app.Handle(http.MethodPost, "/users", ugh.Create, authen, admin)
-	app.Handle(http.MethodPut, "/users/:id", ugh.Update, authen, admin)
-	app.Handle(http.MethodDelete, "/users/:id", ugh.Delete, authen, admin)
+	roles := api.Group("/roles")
+	roles.Handle(http.MethodGet, "", rlgh.Query, mid.Require(auth.PermRolesRead)).Describe(web.Doc{
+		Summary:  "List the roles.",
+		Response: []role.Role{},
+		Requires: []web.Requirement{{auth.PermRolesRead}},
+	})
+	roles.Handle(http.MethodGet, "/permissions", rlgh.Permissions, mid.Require(auth.PermRolesRead)).Describe(web.Doc{
+		Summary:  "List the permissions roles can grant.",
+		Response: []string{},
+		Requires: []web.Requirement{{auth.PermRolesRead}},
+	})
+	roles.Handle(http.MethodGet, "/:role", rlgh.QueryByName, mid.Require(auth.PermRolesRead)).Describe(web.Doc{
+		Summary:  "Get a role.",
+		Response: role.Role{},
+		Requires: []web.Requirement{{auth.PermRolesRead}},
+	})
+	roles.Handle(http.MethodPost, "", rlgh.Create, mid.Require(auth.PermRolesWrite)).Describe(web.Doc{
+		Summary:  "Create a role.",
+		Request:  role.NewRole{},
+		Response: role.Role{},
+		Status:   http.StatusCreated,
+		Requires: []web.Requirement{{auth.PermRolesWrite}},
+	})
+	roles.Handle(http.MethodPut, "/:role", rlgh.Update, mid.Require(auth.PermRolesWrite)).Describe(web.Doc{
+		Summary:  "Update the permissions of a role.",
+		Request:  role.UpdateRole{},
+		Response: role.Role{},
+		Requires: []web.Requirement{{auth.PermRolesWrite}},
+	})
+	roles.Handle(http.MethodDelete, "/:role", rlgh.Delete, mid.Require(auth.PermRolesWrite)).Describe(web.Doc{
+		Summary:  "Delete a role.",
+		Requires: []web.Requirement{{auth.PermRolesWrite}},
+	})
+
+	// Register the audit log endpoints.
+	adgh := auditgrp.Handlers{
+		Audit: audit.NewCore(log, db),
+	}
+	api.Handle(http.MethodGet, "/audit", adgh.Query, mid.Require(auth.PermAuditRead)).Describe(web.Doc{
+		Summary: "List audit log entries a page at a time.",
+		Query: []web.QueryParam{
+			{Name: "page", Type: "integer"},
+			{Name: "rows", Type: "integer"},
+			{Name: "entity_type"},
+			{Name: "entity_id"},
+			{Name: "actor_id"},
+			{Name: "start_date", Description: "RFC 3339 time."},
+			{Name: "end_date", Description: "RFC 3339 time."},
+		},
+		Response: []audit.Audit{},
+		Requires: []web.Requirement{{auth.PermAuditRead}},
+	})
+
+	// Register product management endpoints.
+	pgh := productgrp.Handlers{
+		Product: product.NewCore(log, db),
+	}
+	products := api.Group("/products")
+	products.Handle(http.MethodGet, "/:page/:rows", pgh.Query, mid.Require(auth.PermProductsRead)).Describe(web.Doc{
+		Summary:  "List products a page at a time.",
+		Response: []product.Product{},
+		Requires: []web.Requirement{{auth.PermProductsRead}},
+	})
+	products.Handle(http.MethodGet, "/:id", pgh.QueryByID, mid.Require(auth.PermProductsRead)).Describe(web.Doc{
+		Summary:  "Get a product.",
+		Response: product.Product{},
+		Requires: []web.Requirement{{auth.PermProductsRead}},
+	})
+	users.Handle(http.MethodGet, "/:id/products", pgh.QueryByUserID, mid.Require(auth.PermProductsRead)).Describe(web.Doc{
+		Summary:  "List the products of a user.",
+		Response: []product.Product{},
+		Requires: []web.Requirement{{auth.PermProductsRead}},
+	})
+	products.Handle(http.MethodPost, "", pgh.Create, mid.Require(auth.PermProductsWrite)).Describe(web.Doc{
+		Summary:  "Create a product.",
+		Request:  product.NewProduct{},
+		Response: product.Product{},
+		Status:   http.StatusCreated,
+		Requires: []web.Requirement{{auth.PermProductsWrite}},
+	})
+	products.Handle(http.MethodPut, "/:id", pgh.Update, mid.Require(auth.PermProductsWrite)).Describe(web.Doc{
+		Summary:  "Update a product.",
+		Request:  product.UpdateProduct{},
+		Requires: []web.Requirement{{auth.PermProductsWrite}},
+	})
+	products.Handle(http.MethodDelete, "/:id", pgh.Delete, mid.Require(auth.PermProductsWrite)).Describe(web.Doc{
+		Summary:  "Delete a product.",
+		Requires: []web.Requirement{{auth.PermProductsWrite}},
+	})
+
+	// Register sale endpoints.
+	sgh := salegrp.Handlers{
+		Sale:    sale.NewCore(log, db),
+		Product: pgh.Product,
+	}
+	products.Handle(http.MethodPost, "/:id/sales", sgh.Create, mid.Require(auth.PermSalesWrite)).Describe(web.Doc{
+		Summary:  "Record a sale of a product.",
+		Request:  sale.NewSale{},
+		Response: sale.Sale{},
+		Status:   http.StatusCreated,
+		Requires: []web.Requirement{{auth.PermSalesWrite}},
+	})
+	products.Handle(http.MethodGet, "/:id/sales", sgh.QueryByProductID).Describe(web.Doc{
+		Summary:  "List the sales of a product. Owners can always list the sales of their products, others need the permission to read sales.",
+		Response: []sale.Sale{},
+	})
 
 	return app
 }
 
+// userFilter documents the query string parameters users are filtered by.
+var userFilter = []web.QueryParam{
+	{Name: "name"},
+	{Name: "email"},
+	{Name: "role"},
+	{Name: "start_created_date", Description: "RFC 3339 time."},
+	{Name: "end_created_date", Description: "RFC 3339 time."},
+	{Name: "includeDeleted", Type: "boolean"},
+}
+
+// userQuery documents the query string parameters of the user list.
+var userQuery = append([]web.QueryParam{
+	{Name: "page", Type: "integer"},
+	{Name: "rows", Type: "integer", Description: "Defaults to 20, at most 100."},
+	{Name: "orderBy", Description: "Field and direction, like name,DESC."},
+	{Name: "cursor", Description: "Cursor of the next or previous page, replacing page and orderBy."},
+}, userFilter...)
+
+// OpenAPI generates the OpenAPI document describing the routes of the API.
+func OpenAPI(app *web.App, build string) ([]byte, error) {
+	cfg := openapi.Config{
+		Info: openapi.Info{
+			Title:   "Sales API",
+			Version: build,
+		},
+		Error: trusted.ErrorResponse{},
+	}
+
+	return openapi.Marshal(cfg, app.Routes())
+}
+
 // DebugStandardLibraryMux registers all the debug routes from the standard library
 // into a new mux bypassing the use of the DefaultServerMux. Using the
 // DefaultServerMux would be a security risk since a dependency could inject a
@@ -61,21 +486,54 @@ func DebugStandardLibraryMux() *http.ServeMux {
 	return mux
 }
 
+// DebugMuxConfig contains all the mandatory systems required by the debug
+// handlers.
+type DebugMuxConfig struct {
+	Build          string
+	Log            *zap.SugaredLogger
+	DB             *sqlx.DB
+	Auth           *auth.Auth
+	KeyStore       *keystore.KeyStore
+	KeyRotation    keyrotation.Core
+	KeyGracePeriod time.Duration
+	OpenAPI        []byte
+}
+
 // DebugMux registers all the debug standard library routes and then custom
 // debug application routes for the service. This bypassing the use of the
 // DefaultServerMux. Using the DefaultServerMux would be a security risk since
 // a dependency could inject a handler into our service without us knowing it.
-func DebugMux(build string, log *zap.SugaredLogger, db *sqlx.DB) http.Handler {
+func DebugMux(cfg DebugMuxConfig) http.Handler {
 	mux := DebugStandardLibraryMux()
 
 	// Register debug check endpoints.
 	cgh := checkgrp.Handlers{
-		Build: build,
-		Log:   log,
-		DB:    db,
+		Build: cfg.Build,
+		Log:   cfg.Log,
+		DB:    cfg.DB,
 	}
 	mux.HandleFunc("/debug/readiness", cgh.Readiness)
 	mux.HandleFunc("/debug/liveness", cgh.Liveness)
 
+	// Register signing key management endpoints.
+	kgh := keygrp.Handlers{
+		Log:         cfg.Log,
+		Auth:        cfg.Auth,
+		KeyStore:    cfg.KeyStore,
+		KeyRotation: cfg.KeyRotation,
+		GracePeriod: cfg.KeyGracePeriod,
+	}
+	mux.HandleFunc("/debug/keys/reload", kgh.Reload)
+	mux.HandleFunc("/debug/keys/activate", kgh.Activate)
+
+	// Register the OpenAPI document describing the API.
+	dgh := docgrp.Handlers{
+		Spec: cfg.OpenAPI,
+	}
+	mux.HandleFunc("/openapi.json", dgh.OpenAPI)
+
+	// Register the metrics for Prometheus to scrape.
+	mux.Handle("/metrics", metrics.Handler())
+
 	return mux
 }
@@ -0,0 +1,33 @@
+// Package jwksgrp maintains the group of handlers for publishing the public
+// keys used to verify tokens.
+package jwksgrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/foundation/web"
+)
+
+// Handlers manages the set of JWKS endpoints.
+type Handlers struct {
+	Auth   *auth.Auth
+	MaxAge time.Duration
+}
+
+// JWKS returns the public keys in the JSON Web Key Set format so other
+// services can validate the tokens issued by this service.
+func (h Handlers) JWKS(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	jwks, err := h.Auth.JWKS()
+	if err != nil {
+		return fmt.Errorf("constructing jwks: %w", err)
+	}
+
+	// Keys change rarely so let clients and proxies cache the document.
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.MaxAge.Seconds())))
+
+	return web.Respond(ctx, w, jwks, http.StatusOK)
+}
@@ -0,0 +1,131 @@
+// Package mfagrp maintains the group of handlers for multi-factor
+// authentication enrollment.
+package mfagrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/mfa"
+	"github.com/ardanlabs/service/business/core/user"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/business/web/trusted"
+	"github.com/ardanlabs/service/foundation/web"
+)
+
+// Handlers manages the set of multi-factor authentication endpoints. They
+// always act on the authenticated user.
+type Handlers struct {
+	MFA  mfa.Core
+	User user.Core
+}
+
+// Code represents the payload of the endpoints that require a code.
+type Code struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// Status reports if multi-factor authentication is enabled and required.
+func (h Handlers) Status(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	st, err := h.MFA.Status(ctx, claims.Subject, claims.Roles)
+	if err != nil {
+		return fmt.Errorf("userID[%s]: %w", claims.Subject, err)
+	}
+
+	return web.Respond(ctx, w, st, http.StatusOK)
+}
+
+// Enroll generates a new secret and recovery codes. Multi-factor
+// authentication is enabled once a code is confirmed.
+func (h Handlers) Enroll(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	usr, err := h.User.QueryByID(ctx, claims.Subject)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound):
+			return trusted.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", claims.Subject, err)
+		}
+	}
+
+	enr, err := h.MFA.Enroll(ctx, usr.ID, usr.Email, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, mfa.ErrAlreadyEnabled):
+			return trusted.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("enrolling userID[%s]: %w", usr.ID, err)
+		}
+	}
+
+	return web.Respond(ctx, w, enr, http.StatusOK)
+}
+
+// Confirm enables multi-factor authentication with a code from the
+// authenticator app that was set up.
+func (h Handlers) Confirm(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return h.withCode(ctx, w, r, h.MFA.Confirm)
+}
+
+// Disable turns off multi-factor authentication with a code from the
+// authenticator app or a recovery code.
+func (h Handlers) Disable(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return h.withCode(ctx, w, r, h.MFA.Disable)
+}
+
+// withCode decodes the code in the request and applies the action for the
+// authenticated user.
+func (h Handlers) withCode(ctx context.Context, w http.ResponseWriter, r *http.Request, action func(context.Context, string, string, time.Time) error) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	var c Code
+	if err := web.Decode(r, &c); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	if err := validate.Check(c); err != nil {
+		return err
+	}
+
+	if err := action(ctx, claims.Subject, c.Code, v.Now); err != nil {
+		switch {
+		case errors.Is(err, mfa.ErrInvalidCode):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, mfa.ErrNotEnrolled):
+			return trusted.NewRequestError(err, http.StatusNotFound)
+		case errors.Is(err, mfa.ErrAlreadyEnabled):
+			return trusted.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("userID[%s]: %w", claims.Subject, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
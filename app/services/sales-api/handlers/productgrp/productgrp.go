@@ -0,0 +1,199 @@
+// Package productgrp maintains the group of handlers for product access.
+package productgrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ardanlabs/service/business/core/product"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/web/trusted"
+	"github.com/ardanlabs/service/foundation/web"
+)
+
+// Handlers manages the set of product endpoints.
+type Handlers struct {
+	Product product.Core
+}
+
+// Create adds a new product to the system.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	var np product.NewProduct
+	if err := web.Decode(r, &np); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	// The product is always owned by the user creating it.
+	np.UserID = claims.Subject
+
+	prd, err := h.Product.Create(ctx, np, v.Now)
+	if err != nil {
+		return fmt.Errorf("creating new product, np[%+v]: %w", np, err)
+	}
+
+	return web.Respond(ctx, w, prd, http.StatusCreated)
+}
+
+// Update updates a product in the system.
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	var upd product.UpdateProduct
+	if err := web.Decode(r, &upd); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	productID := web.Param(r, "id")
+
+	if err := h.checkOwner(ctx, claims, productID); err != nil {
+		return err
+	}
+
+	if err := h.Product.Update(ctx, productID, upd, v.Now); err != nil {
+		switch {
+		case errors.Is(err, product.ErrInvalidID):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, product.ErrNotFound):
+			return trusted.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] Product[%+v]: %w", productID, &upd, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Delete removes a product from the system.
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	productID := web.Param(r, "id")
+
+	if err := h.checkOwner(ctx, claims, productID); err != nil {
+		return err
+	}
+
+	if err := h.Product.Delete(ctx, productID, v.Now); err != nil {
+		switch {
+		case errors.Is(err, product.ErrInvalidID):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("ID[%s]: %w", productID, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Query returns a list of products with paging.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	page := web.Param(r, "page")
+	pageNumber, err := strconv.Atoi(page)
+	if err != nil {
+		return trusted.NewRequestError(fmt.Errorf("invalid page format [%s]", page), http.StatusBadRequest)
+	}
+	rows := web.Param(r, "rows")
+	rowsPerPage, err := strconv.Atoi(rows)
+	if err != nil {
+		return trusted.NewRequestError(fmt.Errorf("invalid rows format [%s]", rows), http.StatusBadRequest)
+	}
+
+	products, err := h.Product.Query(ctx, pageNumber, rowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to query for products: %w", err)
+	}
+
+	return web.Respond(ctx, w, products, http.StatusOK)
+}
+
+// QueryByID returns a product by its ID.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	productID := web.Param(r, "id")
+
+	prd, err := h.Product.QueryByID(ctx, productID)
+	if err != nil {
+		switch {
+		case errors.Is(err, product.ErrInvalidID):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, product.ErrNotFound):
+			return trusted.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", productID, err)
+		}
+	}
+
+	return web.Respond(ctx, w, prd, http.StatusOK)
+}
+
+// QueryByUserID returns the products owned by the specified user.
+func (h Handlers) QueryByUserID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID := web.Param(r, "id")
+
+	products, err := h.Product.QueryByUserID(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, product.ErrInvalidID):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("userID[%s]: %w", userID, err)
+		}
+	}
+
+	return web.Respond(ctx, w, products, http.StatusOK)
+}
+
+// checkOwner validates the claims belong to the user owning the specified
+// product or grant the permission to manage the products of every user.
+func (h Handlers) checkOwner(ctx context.Context, claims auth.Claims, productID string) error {
+	if auth.Can(ctx, auth.PermProductsManage) {
+		return nil
+	}
+
+	prd, err := h.Product.QueryByID(ctx, productID)
+	if err != nil {
+		switch {
+		case errors.Is(err, product.ErrInvalidID):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, product.ErrNotFound):
+			return trusted.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", productID, err)
+		}
+	}
+
+	// If you are looking to change a product you don't own.
+	if prd.UserID != claims.Subject {
+		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+// Package revocationgrp maintains the group of handlers for token revocation.
+package revocationgrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ardanlabs/service/business/core/revocation"
+	"github.com/ardanlabs/service/business/web/trusted"
+	"github.com/ardanlabs/service/foundation/web"
+)
+
+// Handlers manages the set of revocation endpoints.
+type Handlers struct {
+	Revocation revocation.Core
+	Cache      *revocation.Cache
+}
+
+// Create revokes a single token or all the tokens issued to a user.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var nr revocation.NewRevocation
+	if err := web.Decode(r, &nr); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	rev, err := h.Revocation.Create(ctx, nr, v.Now)
+	if err != nil {
+		if errors.Is(err, revocation.ErrInvalidID) {
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		}
+		return fmt.Errorf("revocation[%+v]: %w", &nr, err)
+	}
+
+	// Apply the revocation on this instance right away. Other instances pick
+	// it up on their next refresh.
+	h.Cache.Add(rev)
+
+	return web.Respond(ctx, w, rev, http.StatusCreated)
+}
@@ -0,0 +1,151 @@
+// Package rolegrp maintains the group of handlers for role management.
+package rolegrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ardanlabs/service/business/core/role"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/web/trusted"
+	"github.com/ardanlabs/service/foundation/web"
+)
+
+// Handlers manages the set of role endpoints.
+type Handlers struct {
+	Role   role.Core
+	Policy *role.Policy
+}
+
+// Query returns all the roles.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	roles, err := h.Role.Query(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query for roles: %w", err)
+	}
+
+	return web.Respond(ctx, w, roles, http.StatusOK)
+}
+
+// Permissions returns the permissions roles can grant.
+func (h Handlers) Permissions(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return web.Respond(ctx, w, auth.Permissions, http.StatusOK)
+}
+
+// QueryByName returns a role by its name.
+func (h Handlers) QueryByName(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	name := web.Param(r, "role")
+
+	rl, err := h.Role.QueryByName(ctx, name)
+	if err != nil {
+		if errors.Is(err, role.ErrNotFound) {
+			return trusted.NewRequestError(err, http.StatusNotFound)
+		}
+		return fmt.Errorf("role[%s]: %w", name, err)
+	}
+
+	return web.Respond(ctx, w, rl, http.StatusOK)
+}
+
+// Create adds a new role.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var nr role.NewRole
+	if err := web.Decode(r, &nr); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	rl, err := h.Role.Create(ctx, nr, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, role.ErrUnknownPermission):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, role.ErrExists):
+			return trusted.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("role[%+v]: %w", &nr, err)
+		}
+	}
+
+	if err := h.refresh(ctx); err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, rl, http.StatusCreated)
+}
+
+// Update replaces the permissions granted by a role.
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var ur role.UpdateRole
+	if err := web.Decode(r, &ur); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	name := web.Param(r, "role")
+
+	rl, err := h.Role.Update(ctx, name, ur, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, role.ErrUnknownPermission), errors.Is(err, role.ErrAdminLockout):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, role.ErrNotFound):
+			return trusted.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("role[%s] update[%+v]: %w", name, &ur, err)
+		}
+	}
+
+	if err := h.refresh(ctx); err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, rl, http.StatusOK)
+}
+
+// Delete removes a role.
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	name := web.Param(r, "role")
+
+	if err := h.Role.Delete(ctx, name, v.Now); err != nil {
+		switch {
+		case errors.Is(err, role.ErrBuiltIn):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, role.ErrNotFound):
+			return trusted.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("role[%s]: %w", name, err)
+		}
+	}
+
+	if err := h.refresh(ctx); err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// refresh applies a change to the roles on this instance right away. Other
+// instances pick it up on their next refresh.
+func (h Handlers) refresh(ctx context.Context) error {
+	if err := h.Policy.Refresh(ctx); err != nil {
+		return fmt.Errorf("refreshing policy: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+// Package salegrp maintains the group of handlers for sale access.
+package salegrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ardanlabs/service/business/core/product"
+	"github.com/ardanlabs/service/business/core/sale"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/web/trusted"
+	"github.com/ardanlabs/service/foundation/web"
+)
+
+// Handlers manages the set of sale endpoints.
+type Handlers struct {
+	Sale    sale.Core
+	Product product.Core
+}
+
+// Create records a sale of the specified product for the authenticated user.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	var ns sale.NewSale
+	if err := web.Decode(r, &ns); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	// The sale is always made to the user recording it for the product
+	// identified in the path.
+	ns.UserID = claims.Subject
+	ns.ProductID = web.Param(r, "id")
+
+	sl, err := h.Sale.Create(ctx, ns, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, sale.ErrInvalidID):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, sale.ErrProductNotFound):
+			return trusted.NewRequestError(err, http.StatusNotFound)
+		case errors.Is(err, sale.ErrInsufficientStock):
+			return trusted.NewRequestError(sale.ErrInsufficientStock, http.StatusConflict)
+		default:
+			return fmt.Errorf("creating new sale, ns[%+v]: %w", ns, err)
+		}
+	}
+
+	return web.Respond(ctx, w, sl, http.StatusCreated)
+}
+
+// QueryByProductID returns the sales recorded for the specified product. Only
+// the owner of the product or a user allowed to read all sales can see them.
+func (h Handlers) QueryByProductID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	productID := web.Param(r, "id")
+
+	prd, err := h.Product.QueryByID(ctx, productID)
+	if err != nil {
+		switch {
+		case errors.Is(err, product.ErrInvalidID):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, product.ErrNotFound):
+			return trusted.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", productID, err)
+		}
+	}
+
+	// If you can't read all sales and are looking to retrieve sales of a
+	// product you don't own.
+	if !auth.Can(ctx, auth.PermSalesRead) && prd.UserID != claims.Subject {
+		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	sales, err := h.Sale.QueryByProductID(ctx, productID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sale.ErrInvalidID):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("productID[%s]: %w", productID, err)
+		}
+	}
+
+	return web.Respond(ctx, w, sales, http.StatusOK)
+}
@@ -5,19 +5,67 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ardanlabs/service/business/core/account"
+	"github.com/ardanlabs/service/business/core/mfa"
+	"github.com/ardanlabs/service/business/core/refresh"
 	"github.com/ardanlabs/service/business/core/user"
 	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/lockout"
+	"github.com/ardanlabs/service/business/sys/metrics"
+	"github.com/ardanlabs/service/business/sys/order"
+	"github.com/ardanlabs/service/business/sys/paging"
+	"github.com/ardanlabs/service/business/sys/validate"
 	"github.com/ardanlabs/service/business/web/trusted"
 	"github.com/ardanlabs/service/foundation/web"
+	"go.uber.org/zap"
 )
 
+// maxRowsPerPage is the most users returned in a page, whatever the number
+// of rows asked for.
+const maxRowsPerPage = 100
+
+// maxPatchSize is the largest patch document accepted, far more than any
+// change to a user needs.
+const maxPatchSize = 64 << 10
+
 // Handlers manages the set of user endpoints.
 type Handlers struct {
-	User user.Core
-	Auth *auth.Auth
+	Log            *zap.SugaredLogger
+	User           user.Core
+	Refresh        refresh.Core
+	Auth           *auth.Auth
+	MFA            mfa.Core
+	Account        account.Core
+	AccountLockout *lockout.Tracker
+	IPLockout      *lockout.Tracker
+	MFALockout     *lockout.Tracker
+	RequireIfMatch bool
+}
+
+// Tokens represents the API token and refresh token handed out to a user.
+type Tokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshRequest represents the payload of the endpoints that take a refresh
+// token.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// EnrollRequest represents the payload for enrolling in multi-factor
+// authentication with the challenge handed out by Token.
+type EnrollRequest struct {
+	Challenge string `json:"mfa_challenge" validate:"required"`
 }
 
 // Create adds a new user to the system.
@@ -40,62 +88,125 @@ func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Req
 		return fmt.Errorf("user[%+v]: %w", &usr, err)
 	}
 
+	// The user exists at this point, so failing the request would only make
+	// the client retry into a conflict. The verification can be resent.
+	if err := h.Account.SendVerification(ctx, usr.ID, v.Now); err != nil {
+		h.Log.Errorw("create user", "traceid", v.TraceID, "userID", usr.ID, "status", "unable to send verification", "ERROR", err)
+	}
+
 	return web.Respond(ctx, w, usr, http.StatusCreated)
 }
 
-// Update updates a user in the system.
+// Update updates a user in the system. When the request carries an If-Match
+// header the update only happens if the user still has the version the
+// client read.
 func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	v, err := web.GetValues(ctx)
 	if err != nil {
 		return web.NewShutdownError("web value missing from context")
 	}
 
-	claims, err := auth.GetClaims(ctx)
+	version, err := h.ifMatch(r)
 	if err != nil {
-		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+		return err
 	}
 
 	var upd user.UpdateUser
 	if err := web.Decode(r, &upd); err != nil {
 		return fmt.Errorf("unable to decode payload: %w", err)
 	}
+	upd.Version = version
 
 	userID := web.Param(r, "id")
-
-	// If you are not an admin and looking to retrieve someone other than yourself.
-	if !claims.Authorized(auth.RoleAdmin) && claims.Subject != userID {
-		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
-	}
-
-	if err := h.User.Update(ctx, userID, upd, v.Now); err != nil {
+	usr, err := h.User.Update(ctx, userID, upd, v.Now)
+	if err != nil {
 		switch {
 		case errors.Is(err, user.ErrInvalidID):
 			return trusted.NewRequestError(err, http.StatusBadRequest)
 		case errors.Is(err, user.ErrNotFound):
 			return trusted.NewRequestError(err, http.StatusNotFound)
+		case errors.Is(err, user.ErrConflict):
+			return trusted.NewRequestError(err, http.StatusPreconditionFailed)
 		default:
 			return fmt.Errorf("ID[%s] User[%+v]: %w", userID, &upd, err)
 		}
 	}
 
+	w.Header().Set("ETag", etag(usr.Version))
+
 	return web.Respond(ctx, w, nil, http.StatusNoContent)
 }
 
-// Delete removes a user from the system.
-func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	claims, err := auth.GetClaims(ctx)
+// Patch changes a user with a JSON Merge Patch or a JSON Patch document,
+// picked by the media type of the request, and responds with the user as
+// patched. If-Match is handled like it is for Update.
+func (h Handlers) Patch(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
 	if err != nil {
-		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	version, err := h.ifMatch(r)
+	if err != nil {
+		return err
+	}
+
+	doc, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+
+		// The reader fails once the limit is read, without a type for the
+		// error to be told apart.
+		if len(doc) == maxPatchSize {
+			err := fmt.Errorf("patch document is over %d bytes", maxPatchSize)
+			return trusted.NewRequestError(err, http.StatusRequestEntityTooLarge)
+		}
+		return fmt.Errorf("unable to read payload: %w", err)
+	}
+
+	p := user.Patch{
+		Type:     strings.TrimSpace(strings.SplitN(r.Header.Get("Content-Type"), ";", 2)[0]),
+		Document: doc,
+		Version:  version,
 	}
 
 	userID := web.Param(r, "id")
+	usr, err := h.User.Patch(ctx, userID, p, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidID), errors.Is(err, user.ErrInvalidPatch):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, user.ErrNotFound):
+			return trusted.NewRequestError(err, http.StatusNotFound)
+		case errors.Is(err, user.ErrUnsupportedPatch):
+			w.Header().Set("Accept-Patch", user.PatchMerge+", "+user.PatchJSON)
+			return trusted.NewRequestError(err, http.StatusUnsupportedMediaType)
+		case errors.Is(err, user.ErrPatchFailed):
+			return trusted.NewRequestError(err, http.StatusUnprocessableEntity)
+		case errors.Is(err, user.ErrConflict):
+			return trusted.NewRequestError(err, http.StatusPreconditionFailed)
+		case errors.Is(err, user.ErrUniqueEmail):
+			return trusted.NewRequestError(err, http.StatusConflict)
+		case validate.IsFieldErrors(err):
+			return err
+		default:
+			return fmt.Errorf("ID[%s]: %w", userID, err)
+		}
+	}
+
+	w.Header().Set("ETag", etag(usr.Version))
+
+	return web.Respond(ctx, w, usr, http.StatusOK)
+}
 
-	// If you are not an admin and looking to delete someone other than yourself.
-	if !claims.Authorized(auth.RoleAdmin) && claims.Subject != userID {
-		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+// Delete removes a user from the system.
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
 	}
 
-	if err := h.User.Delete(ctx, userID); err != nil {
+	userID := web.Param(r, "id")
+	if err := h.User.Delete(ctx, userID, v.Now); err != nil {
 		switch {
 		case errors.Is(err, user.ErrInvalidID):
 			return trusted.NewRequestError(err, http.StatusBadRequest)
@@ -107,48 +218,111 @@ func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Req
 	return web.Respond(ctx, w, nil, http.StatusNoContent)
 }
 
-// Query returns a list of users with paging.
+// Query returns a list of users with filtering, ordering and paging. When a
+// cursor is provided the page is located from the cursor instead of the page
+// number.
 func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	page := web.Param(r, "page")
-	pageNumber, err := strconv.Atoi(page)
-	if err != nil {
-		return trusted.NewRequestError(fmt.Errorf("invalid page format [%s]", page), http.StatusBadRequest)
+	qs := r.URL.Query()
+
+	pageNumber := 1
+	if page := qs.Get("page"); page != "" {
+		var err error
+		pageNumber, err = strconv.Atoi(page)
+		if err != nil || pageNumber < 1 {
+			return trusted.NewRequestError(fmt.Errorf("invalid page format [%s]", page), http.StatusBadRequest)
+		}
 	}
-	rows := web.Param(r, "rows")
-	rowsPerPage, err := strconv.Atoi(rows)
-	if err != nil {
-		return trusted.NewRequestError(fmt.Errorf("invalid rows format [%s]", rows), http.StatusBadRequest)
+
+	rowsPerPage := 20
+	if rows := qs.Get("rows"); rows != "" {
+		var err error
+		rowsPerPage, err = strconv.Atoi(rows)
+		if err != nil || rowsPerPage < 1 {
+			return trusted.NewRequestError(fmt.Errorf("invalid rows format [%s]", rows), http.StatusBadRequest)
+		}
+		if rowsPerPage > maxRowsPerPage {
+			rowsPerPage = maxRowsPerPage
+		}
 	}
 
-	users, err := h.User.Query(ctx, pageNumber, rowsPerPage)
+	filter, err := parseFilter(qs)
 	if err != nil {
-		return fmt.Errorf("unable to query for users: %w", err)
+		return trusted.NewRequestError(err, http.StatusBadRequest)
 	}
 
-	return web.Respond(ctx, w, users, http.StatusOK)
+	var result user.QueryResult
+	switch cursor := qs.Get("cursor"); cursor {
+	case "":
+		orderBy, err := order.Parse(qs.Get("orderBy"), user.DefaultOrderBy)
+		if err != nil {
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		}
+		result, err = h.User.Query(ctx, filter, orderBy, pageNumber, rowsPerPage)
+		if err != nil {
+			return queryError(err)
+		}
+
+	default:
+		result, err = h.User.QueryByCursor(ctx, filter, cursor, rowsPerPage)
+		if err != nil {
+			return queryError(err)
+		}
+	}
+
+	return web.Respond(ctx, w, result, http.StatusOK)
 }
 
-// QueryByID returns a user by its ID.
+// QueryByID returns a user by its ID. Deleted users are only returned when
+// includeDeleted is set.
 func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	claims, err := auth.GetClaims(ctx)
+	includeDeleted, err := parseIncludeDeleted(r.URL.Query())
 	if err != nil {
-		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+		return trusted.NewRequestError(err, http.StatusBadRequest)
 	}
 
 	userID := web.Param(r, "id")
 
-	// If you are not an admin and looking to retrieve someone other than yourself.
-	if !claims.Authorized(auth.RoleAdmin) && claims.Subject != userID {
-		return trusted.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	var usr user.User
+	switch includeDeleted {
+	case true:
+		usr, err = h.User.QueryByIDIncludeDeleted(ctx, userID)
+	default:
+		usr, err = h.User.QueryByID(ctx, userID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidID):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, user.ErrNotFound):
+			return trusted.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", userID, err)
+		}
 	}
 
-	usr, err := h.User.QueryByID(ctx, userID)
+	w.Header().Set("ETag", etag(usr.Version))
+
+	return web.Respond(ctx, w, usr, http.StatusOK)
+}
+
+// Restore undoes the deletion of a user.
+func (h Handlers) Restore(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	userID := web.Param(r, "id")
+
+	usr, err := h.User.Restore(ctx, userID, v.Now)
 	if err != nil {
 		switch {
 		case errors.Is(err, user.ErrInvalidID):
 			return trusted.NewRequestError(err, http.StatusBadRequest)
 		case errors.Is(err, user.ErrNotFound):
 			return trusted.NewRequestError(err, http.StatusNotFound)
+		case errors.Is(err, user.ErrNotDeleted), errors.Is(err, user.ErrUniqueEmail):
+			return trusted.NewRequestError(err, http.StatusConflict)
 		default:
 			return fmt.Errorf("ID[%s]: %w", userID, err)
 		}
@@ -157,6 +331,91 @@ func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.
 	return web.Respond(ctx, w, usr, http.StatusOK)
 }
 
+// Import creates users from a CSV or NDJSON document, reporting the outcome
+// of every row. The import is atomic unless the best-effort mode is asked
+// for, and nothing is created on a dry run. Imports over the size or row
+// limits of the core are rejected with a 413.
+func (h Handlers) Import(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	qs := r.URL.Query()
+
+	opts := user.ImportOptions{
+		Format: parseFormat(qs, r.Header.Get("Content-Type")),
+		Mode:   user.ImportAtomic,
+	}
+	if mode := qs.Get("mode"); mode != "" {
+		opts.Mode = mode
+	}
+	if dryRun := qs.Get("dryRun"); dryRun != "" {
+		opts.DryRun, err = strconv.ParseBool(dryRun)
+		if err != nil {
+			return trusted.NewRequestError(fmt.Errorf("invalid dryRun format [%s]", dryRun), http.StatusBadRequest)
+		}
+	}
+
+	result, err := h.User.Import(ctx, r.Body, opts, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrUnknownFormat), errors.Is(err, user.ErrUnknownImportMode):
+			return trusted.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, user.ErrImportTooLarge):
+			return trusted.NewRequestError(err, http.StatusRequestEntityTooLarge)
+		default:
+			return fmt.Errorf("importing users: %w", err)
+		}
+	}
+
+	// An atomic import that was rejected didn't create anything.
+	if opts.Mode == user.ImportAtomic && result.Failed > 0 {
+		return web.Respond(ctx, w, result, http.StatusUnprocessableEntity)
+	}
+
+	return web.Respond(ctx, w, result, http.StatusOK)
+}
+
+// Export streams the users matching the filter as a CSV or NDJSON document.
+func (h Handlers) Export(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	qs := r.URL.Query()
+
+	filter, err := parseFilter(qs)
+	if err != nil {
+		return trusted.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	if err := validate.Check(filter); err != nil {
+		return err
+	}
+
+	format := parseFormat(qs, r.Header.Get("Accept"))
+	contentType, exists := contentTypes[format]
+	if !exists {
+		return trusted.NewRequestError(fmt.Errorf("format %q: %w", format, user.ErrUnknownFormat), http.StatusBadRequest)
+	}
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	web.SetStatusCode(ctx, http.StatusOK)
+	w.WriteHeader(http.StatusOK)
+
+	// Once the users start streaming the status can't change anymore and an
+	// error response would be appended to the document, so an error part way
+	// through is only logged and cuts the document short.
+	if _, err := h.User.Export(ctx, w, format, filter); err != nil {
+		h.Log.Errorw("ERROR", "traceid", v.TraceID, "message", fmt.Errorf("exporting users: %w", err))
+		metrics.AddErrors(ctx)
+	}
+
+	return nil
+}
+
 // Token provides an API token for the authenticated user.
 func (h Handlers) Token(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	v, err := web.GetValues(ctx)
@@ -170,25 +429,420 @@ func (h Handlers) Token(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		return trusted.NewRequestError(err, http.StatusUnauthorized)
 	}
 
+	// Failures are tracked by email whether the account exists or not, so
+	// being locked out doesn't reveal which emails exist either.
+	account := strings.ToLower(email)
+	ip := v.RemoteIP
+
+	if err := h.checkLocked(w, account, ip, v.Now); err != nil {
+		return err
+	}
+
 	claims, err := h.User.Authenticate(ctx, v.Now, email, pass)
 	if err != nil {
 		switch {
-		case errors.Is(err, user.ErrNotFound):
-			return trusted.NewRequestError(err, http.StatusNotFound)
 		case errors.Is(err, user.ErrAuthenticationFailure):
+			metrics.AddAuthFailures(ctx)
+			h.AccountLockout.Fail(ctx, account, v.Now)
+			h.IPLockout.Fail(ctx, ip, v.Now)
 			return trusted.NewRequestError(err, http.StatusUnauthorized)
 		default:
 			return fmt.Errorf("authenticating: %w", err)
 		}
 	}
 
-	var tkn struct {
-		Token string `json:"token"`
+	// Only the account is forgiven, otherwise a client could keep guessing
+	// passwords for other accounts by logging into its own now and then.
+	h.AccountLockout.Succeed(account)
+
+	// Users that must use multi-factor authentication only get a challenge
+	// until they prove the second factor.
+	st, err := h.MFA.Status(ctx, claims.Subject, claims.Roles)
+	if err != nil {
+		return fmt.Errorf("mfa status: %w", err)
 	}
+
+	if st.Required {
+
+		// A correct password only forgives the password failures, so the
+		// codes of a user can't be guessed by asking for new challenges.
+		if wait, locked := h.MFALockout.Locked(claims.Subject, v.Now); locked {
+			return lockedOut(w, wait)
+		}
+
+		ch, err := h.MFA.CreateChallenge(ctx, claims.Subject, st, v.Now)
+		if err != nil {
+			return fmt.Errorf("creating mfa challenge: %w", err)
+		}
+
+		return web.Respond(ctx, w, ch, http.StatusOK)
+	}
+
+	return h.respondTokens(ctx, w, claims, v.Now)
+}
+
+// TokenMFA provides an API token for a user that answers the challenge
+// handed out by Token with a code from an authenticator app or a recovery
+// code.
+func (h Handlers) TokenMFA(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var ans mfa.Answer
+	if err := web.Decode(r, &ans); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	// Wrong codes are tracked by user, across all of the user's challenges.
+	userID, err := h.MFA.ChallengeUser(ctx, ans.Challenge, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, mfa.ErrInvalidChallenge):
+			metrics.AddAuthFailures(ctx)
+			return trusted.NewRequestError(err, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("checking mfa challenge: %w", err)
+		}
+	}
+
+	if wait, locked := h.MFALockout.Locked(userID, v.Now); locked {
+		return lockedOut(w, wait)
+	}
+
+	if _, err := h.MFA.Answer(ctx, ans, v.Now); err != nil {
+		switch {
+		case errors.Is(err, mfa.ErrInvalidCode):
+			metrics.AddAuthFailures(ctx)
+			h.MFALockout.Fail(ctx, userID, v.Now)
+			return trusted.NewRequestError(err, http.StatusUnauthorized)
+		case errors.Is(err, mfa.ErrInvalidChallenge), errors.Is(err, mfa.ErrNotEnrolled):
+			metrics.AddAuthFailures(ctx)
+			return trusted.NewRequestError(err, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("answering mfa challenge: %w", err)
+		}
+	}
+
+	h.MFALockout.Succeed(userID)
+
+	claims, err := h.User.Claims(ctx, userID, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound):
+			return trusted.NewRequestError(mfa.ErrInvalidChallenge, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("claims for userID[%s]: %w", userID, err)
+		}
+	}
+
+	return h.respondTokens(ctx, w, claims, v.Now)
+}
+
+// TokenMFAEnroll enrolls a user that is required to use multi-factor
+// authentication but hasn't enrolled yet, using the challenge handed out by
+// Token. The challenge is then answered with a code from the newly set up
+// authenticator app.
+func (h Handlers) TokenMFAEnroll(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var req EnrollRequest
+	if err := web.Decode(r, &req); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	if err := validate.Check(req); err != nil {
+		return err
+	}
+
+	userID, err := h.MFA.ChallengeUser(ctx, req.Challenge, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, mfa.ErrInvalidChallenge):
+			return trusted.NewRequestError(err, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("checking mfa challenge: %w", err)
+		}
+	}
+
+	usr, err := h.User.QueryByID(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound):
+			return trusted.NewRequestError(mfa.ErrInvalidChallenge, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("ID[%s]: %w", userID, err)
+		}
+	}
+
+	enr, err := h.MFA.Enroll(ctx, usr.ID, usr.Email, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, mfa.ErrAlreadyEnabled):
+			return trusted.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("enrolling userID[%s]: %w", userID, err)
+		}
+	}
+
+	return web.Respond(ctx, w, enr, http.StatusOK)
+}
+
+// respondTokens responds with an API token for the claims along with a
+// refresh token starting a new session.
+func (h Handlers) respondTokens(ctx context.Context, w http.ResponseWriter, claims auth.Claims, now time.Time) error {
+	var tkn Tokens
+
+	var err error
 	tkn.Token, err = h.Auth.GenerateToken(claims)
 	if err != nil {
 		return fmt.Errorf("generating token: %w", err)
 	}
 
+	tkn.RefreshToken, err = h.Refresh.Create(ctx, claims.Subject, now)
+	if err != nil {
+		return fmt.Errorf("generating refresh token: %w", err)
+	}
+
 	return web.Respond(ctx, w, tkn, http.StatusOK)
 }
+
+// checkLocked fails with a 429 telling the client when to retry if either the
+// account or the source IP are locked out.
+func (h Handlers) checkLocked(w http.ResponseWriter, account string, ip string, now time.Time) error {
+	wait, locked := h.AccountLockout.Locked(account, now)
+	if ipWait, ipLocked := h.IPLockout.Locked(ip, now); ipLocked && ipWait > wait {
+		wait, locked = ipWait, true
+	}
+
+	if !locked {
+		return nil
+	}
+
+	return lockedOut(w, wait)
+}
+
+// lockedOut fails with a 429 telling the client to retry after the wait.
+func lockedOut(w http.ResponseWriter, wait time.Duration) error {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return trusted.NewRequestError(lockout.ErrLocked, http.StatusTooManyRequests)
+}
+
+// RefreshToken exchanges a refresh token for a new API token and a new
+// refresh token. The refresh token provided can't be used again.
+func (h Handlers) RefreshToken(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var rt RefreshRequest
+	if err := web.Decode(r, &rt); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	if err := validate.Check(rt); err != nil {
+		return err
+	}
+
+	userID, refreshToken, err := h.Refresh.Rotate(ctx, rt.RefreshToken, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, refresh.ErrInvalidToken), errors.Is(err, refresh.ErrTokenReused):
+			return trusted.NewRequestError(err, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("rotating refresh token: %w", err)
+		}
+	}
+
+	claims, err := h.User.Claims(ctx, userID, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound):
+			return trusted.NewRequestError(refresh.ErrInvalidToken, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("claims for userID[%s]: %w", userID, err)
+		}
+	}
+
+	var tkn Tokens
+	tkn.Token, err = h.Auth.GenerateToken(claims)
+	if err != nil {
+		return fmt.Errorf("generating token: %w", err)
+	}
+	tkn.RefreshToken = refreshToken
+
+	return web.Respond(ctx, w, tkn, http.StatusOK)
+}
+
+// RevokeToken revokes a refresh token and every token rotated from the same
+// login, so the session can't be renewed anymore.
+func (h Handlers) RevokeToken(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var rt RefreshRequest
+	if err := web.Decode(r, &rt); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	if err := validate.Check(rt); err != nil {
+		return err
+	}
+
+	if err := h.Refresh.Revoke(ctx, rt.RefreshToken, v.Now); err != nil {
+		switch {
+		case errors.Is(err, refresh.ErrInvalidToken):
+			return trusted.NewRequestError(err, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("revoking refresh token: %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// =============================================================================
+
+// parseFilter constructs the query filter from the query string.
+func parseFilter(qs url.Values) (user.QueryFilter, error) {
+	var filter user.QueryFilter
+
+	if name := qs.Get("name"); name != "" {
+		filter.Name = &name
+	}
+
+	if email := qs.Get("email"); email != "" {
+		filter.Email = &email
+	}
+
+	if role := qs.Get("role"); role != "" {
+		filter.Role = &role
+	}
+
+	if createdDate := qs.Get("start_created_date"); createdDate != "" {
+		t, err := time.Parse(time.RFC3339, createdDate)
+		if err != nil {
+			return user.QueryFilter{}, fmt.Errorf("invalid start_created_date format [%s]", createdDate)
+		}
+		filter.StartCreatedDate = &t
+	}
+
+	if createdDate := qs.Get("end_created_date"); createdDate != "" {
+		t, err := time.Parse(time.RFC3339, createdDate)
+		if err != nil {
+			return user.QueryFilter{}, fmt.Errorf("invalid end_created_date format [%s]", createdDate)
+		}
+		filter.EndCreatedDate = &t
+	}
+
+	includeDeleted, err := parseIncludeDeleted(qs)
+	if err != nil {
+		return user.QueryFilter{}, err
+	}
+	filter.IncludeDeleted = includeDeleted
+
+	return filter, nil
+}
+
+// contentTypes maps the formats users are imported and exported in to their
+// media types.
+var contentTypes = map[string]string{
+	user.FormatCSV:    "text/csv",
+	user.FormatNDJSON: "application/x-ndjson",
+}
+
+// parseFormat reads the format of an import or export from the query string,
+// falling back to the first known media type in the specified header and
+// then to NDJSON.
+func parseFormat(qs url.Values, header string) string {
+	if format := qs.Get("format"); format != "" {
+		return format
+	}
+
+	for _, mediaType := range strings.Split(header, ",") {
+		mediaType = strings.TrimSpace(strings.SplitN(mediaType, ";", 2)[0])
+		for format, contentType := range contentTypes {
+			if strings.EqualFold(mediaType, contentType) {
+				return format
+			}
+		}
+	}
+
+	return user.FormatNDJSON
+}
+
+// parseIncludeDeleted reads the option to include deleted users from the
+// query string.
+func parseIncludeDeleted(qs url.Values) (bool, error) {
+	v := qs.Get("includeDeleted")
+	if v == "" {
+		return false, nil
+	}
+
+	includeDeleted, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid includeDeleted format [%s]", v)
+	}
+
+	return includeDeleted, nil
+}
+
+// etag formats the version of a user as a strong entity tag.
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// ifMatch reads the version the client expects the user to have from the
+// If-Match header. A nil version means the update is unconditional, which is
+// only allowed when the handlers don't require If-Match.
+func (h Handlers) ifMatch(r *http.Request) (*int, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+
+	switch value {
+	case "":
+		if h.RequireIfMatch {
+			err := errors.New("the If-Match header is required")
+			return nil, trusted.NewRequestError(err, http.StatusPreconditionRequired)
+		}
+		return nil, nil
+
+	case "*":
+		return nil, nil
+	}
+
+	// Weak tags never match for a modification, and a user only has one
+	// current version so a list of tags isn't supported.
+	unquoted, err := strconv.Unquote(value)
+	if err != nil || strings.HasPrefix(value, "W/") || strings.Contains(value, ",") {
+		err := fmt.Errorf("invalid If-Match format [%s]", value)
+		return nil, trusted.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	version, err := strconv.Atoi(unquoted)
+	if err != nil {
+		return nil, trusted.NewRequestError(user.ErrConflict, http.StatusPreconditionFailed)
+	}
+
+	return &version, nil
+}
+
+// queryError maps the errors from querying users to request errors.
+func queryError(err error) error {
+	switch {
+	case errors.Is(err, order.ErrInvalidOrder):
+		return trusted.NewRequestError(err, http.StatusBadRequest)
+	case errors.Is(err, paging.ErrInvalidCursor):
+		return trusted.NewRequestError(err, http.StatusBadRequest)
+	case validate.IsFieldErrors(err):
+		return err
+	default:
+		return fmt.Errorf("unable to query for users: %w", err)
+	}
+}
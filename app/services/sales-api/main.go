@@ -14,10 +14,22 @@ import (
 
 	"github.com/ardanlabs/conf/v3"
 	"github.com/ardanlabs/service/app/services/sales-api/handlers"
+	"github.com/ardanlabs/service/business/core/account"
+	"github.com/ardanlabs/service/business/core/keyrotation"
+	"github.com/ardanlabs/service/business/core/mfa"
+	"github.com/ardanlabs/service/business/core/revocation"
+	"github.com/ardanlabs/service/business/core/role"
+	"github.com/ardanlabs/service/business/core/user"
 	"github.com/ardanlabs/service/business/sys/auth"
 	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/lockout"
+	"github.com/ardanlabs/service/business/sys/metrics"
+	"github.com/ardanlabs/service/business/sys/ratelimit"
 	"github.com/ardanlabs/service/foundation/keystore"
 	"github.com/ardanlabs/service/foundation/logger"
+	"github.com/ardanlabs/service/foundation/mail"
+	"github.com/ardanlabs/service/foundation/tracer"
+	"github.com/ardanlabs/service/foundation/web"
 	"github.com/emadolsky/automaxprocs/maxprocs"
 	"go.uber.org/zap"
 )
@@ -74,19 +86,74 @@ func run(log *zap.SugaredLogger) error {
 			ShutdownTimeout time.Duration `conf:"default:20s,mask"`
 			APIHost         string        `conf:"default:0.0.0.0:3000"`
 			DebugHost       string        `conf:"default:0.0.0.0:4000"`
+			TrustedProxies  []string      `conf:"help:networks of the proxies allowed to set X-Forwarded-For separated by ;"`
 		}
 		Auth struct {
-			KeysFolder string `conf:"default:zarf/keys/"`
-			ActiveKID  string `conf:"default:54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"`
+			KeysFolder      string        `conf:"default:zarf/keys/"`
+			ActiveKID       string        `conf:"default:54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"`
+			RefreshTokenTTL time.Duration `conf:"default:720h"`
+			JWKSMaxAge      time.Duration `conf:"default:1h"`
+			KeyGracePeriod  time.Duration `conf:"default:2h"`
+			RevocationSync  time.Duration `conf:"default:30s"`
+			RoleSync        time.Duration `conf:"default:30s"`
+			KeySync         time.Duration `conf:"default:1m"`
+		}
+		MFA struct {
+			Issuer       string        `conf:"default:Sales API"`
+			ChallengeTTL time.Duration `conf:"default:5m"`
+			RequireAdmin bool          `conf:"default:true"`
+			SecretKey    string        `conf:"required,mask"`
+		}
+		Account struct {
+			TokenKey  string        `conf:"required,mask"`
+			BaseURL   string        `conf:"default:http://localhost:3000"`
+			ResetTTL  time.Duration `conf:"default:1h"`
+			VerifyTTL time.Duration `conf:"default:72h"`
+		}
+		Mail struct {
+			Host     string
+			Port     int `conf:"default:587"`
+			User     string
+			Password string `conf:"mask"`
+			From     string `conf:"default:noreply@example.com"`
+			Folder   string `conf:"default:zarf/mail/"`
+		}
+		Users struct {
+			DeletedRetention time.Duration `conf:"default:720h"`
+			PurgeInterval    time.Duration `conf:"default:1h"`
+			RequireIfMatch   bool          `conf:"default:false"`
+		}
+		Lockout struct {
+			AccountThreshold int           `conf:"default:5"`
+			IPThreshold      int           `conf:"default:20"`
+			BaseDelay        time.Duration `conf:"default:1s"`
+			MaxDelay         time.Duration `conf:"default:15m"`
+			Window           time.Duration `conf:"default:15m"`
+		}
+		RateLimit struct {
+			Backend    string        `conf:"default:memory"`
+			Requests   int           `conf:"default:600"`
+			Period     time.Duration `conf:"default:1m"`
+			Burst      int           `conf:"default:100"`
+			IPRequests int           `conf:"default:3000"`
+			IPBurst    int           `conf:"default:500"`
+		}
+		Tracing struct {
+			Endpoint    string
+			ServiceName string        `conf:"default:sales-api"`
+			Probability float64       `conf:"default:0.05"`
+			Timeout     time.Duration `conf:"default:10s"`
 		}
 		DB struct {
-			User         string `conf:"default:postgres"`
-			Password     string `conf:"default:postgres,mask"`
-			Host         string `conf:"default:localhost"`
-			Name         string `conf:"default:postgres"`
-			MaxIdleConns int    `conf:"default:0"`
-			MaxOpenConns int    `conf:"default:0"`
-			DisableTLS   bool   `conf:"default:true"`
+			User               string        `conf:"default:postgres"`
+			Password           string        `conf:"default:postgres,mask"`
+			Host               string        `conf:"default:localhost"`
+			Name               string        `conf:"default:postgres"`
+			MaxIdleConns       int           `conf:"default:0"`
+			MaxOpenConns       int           `conf:"default:0"`
+			DisableTLS         bool          `conf:"default:true"`
+			SlowQueryThreshold time.Duration `conf:"default:200ms"`
+			ExplainSlowQueries bool          `conf:"default:false"`
 		}
 	}{
 		Version: conf.Version{
@@ -136,6 +203,23 @@ func run(log *zap.SugaredLogger) error {
 		return fmt.Errorf("constructing auth: %w", err)
 	}
 
+	// Reload the key files on SIGHUP so keys can be added or removed without
+	// a restart. The active key is changed through the debug endpoint and
+	// can't be removed.
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+
+	go func() {
+		for range reload {
+			if err := ks.Reload(auth.ActiveKID()); err != nil {
+				log.Errorw("keys reload", "ERROR", err)
+				continue
+			}
+			log.Infow("keys reload", "status", "keys reloaded", "kids", ks.KIDs())
+		}
+	}()
+
 	// =========================================================================
 	// Database Support
 
@@ -159,16 +243,226 @@ func run(log *zap.SugaredLogger) error {
 		db.Close()
 	}()
 
+	// Report the connection pool and the queries that run slower than the
+	// threshold. Explaining slow queries costs another round trip so it is
+	// only meant to be turned on outside of production.
+	metrics.RegisterDBStats(db.Stats)
+	database.ReportSlowQueries(cfg.DB.SlowQueryThreshold, cfg.DB.ExplainSlowQueries)
+
+	// =========================================================================
+	// Mail Support
+
+	// Send mail through the SMTP server when one is configured, otherwise
+	// write it to a folder for local development.
+	var mailer mail.Mailer
+	switch cfg.Mail.Host {
+	case "":
+		log.Infow("startup", "status", "initializing mail support", "folder", cfg.Mail.Folder)
+		mailer = mail.NewFile(log, cfg.Mail.Folder, cfg.Mail.From)
+	default:
+		log.Infow("startup", "status", "initializing mail support", "host", cfg.Mail.Host)
+		mailer = mail.NewSMTP(mail.SMTPConfig{
+			Host:     cfg.Mail.Host,
+			Port:     cfg.Mail.Port,
+			User:     cfg.Mail.User,
+			Password: cfg.Mail.Password,
+			From:     cfg.Mail.From,
+		})
+	}
+
+	// =========================================================================
+	// Rate Limiting Support
+
+	// Keep the token buckets in memory unless several instances of the
+	// service run, in which case the database lets them share the limits.
+	log.Infow("startup", "status", "initializing rate limiting support", "backend", cfg.RateLimit.Backend)
+
+	var rateLimits ratelimit.Store
+	switch cfg.RateLimit.Backend {
+	case "memory":
+		rateLimits = ratelimit.NewMemory()
+	case "postgres":
+		rateLimits = ratelimit.NewPostgres(log, db)
+	default:
+		return fmt.Errorf("unknown rate limit backend %q", cfg.RateLimit.Backend)
+	}
+
+	// =========================================================================
+	// Token Revocation Support
+
+	// Keep a local copy of the revoked tokens so they can be checked on every
+	// request, synchronized with the database so revocations made by other
+	// instances of the service are picked up.
+	log.Infow("startup", "status", "initializing token revocation support", "sync", cfg.Auth.RevocationSync)
+
+	revocations := revocation.NewCache(log, revocation.NewCore(log, db))
+	revocations.Start(cfg.Auth.RevocationSync)
+	defer func() {
+		log.Infow("shutdown", "status", "stopping token revocation support")
+		revocations.Shutdown()
+	}()
+
+	// =========================================================================
+	// Key Rotation Support
+
+	// The key files are deployed with every instance, but the key signing new
+	// tokens is shared through the database so activating a key through the
+	// debug endpoint of any instance changes it for all of them. ActiveKID
+	// applies until a key is activated.
+	log.Infow("startup", "status", "initializing key rotation support", "sync", cfg.Auth.KeySync)
+
+	keyRotation := keyrotation.NewCore(log, db)
+	keySync := keyrotation.NewSync(log, keyRotation, auth)
+	keySync.Start(cfg.Auth.KeySync)
+	defer func() {
+		log.Infow("shutdown", "status", "stopping key rotation support")
+		keySync.Shutdown()
+	}()
+
+	// =========================================================================
+	// Permission Support
+
+	// Keep a local copy of the permissions each role grants so they can be
+	// checked on every request, synchronized with the database so roles edited
+	// through other instances of the service are picked up.
+	log.Infow("startup", "status", "initializing permission support", "sync", cfg.Auth.RoleSync)
+
+	policy := role.NewPolicy(log, role.NewCore(log, db))
+	policy.Start(cfg.Auth.RoleSync)
+	defer func() {
+		log.Infow("shutdown", "status", "stopping permission support")
+		policy.Shutdown()
+	}()
+
+	// =========================================================================
+	// Deleted User Purging
+
+	// Deleted users are kept for the retention period so they can be restored,
+	// then purged for good along with their products and sales.
+	log.Infow("startup", "status", "initializing deleted user purging", "retention", cfg.Users.DeletedRetention)
+
+	purger := user.NewPurger(log, user.NewCore(log, db), cfg.Users.DeletedRetention)
+	purger.Start(cfg.Users.PurgeInterval)
+	defer func() {
+		log.Infow("shutdown", "status", "stopping deleted user purging")
+		purger.Shutdown()
+	}()
+
+	// =========================================================================
+	// Start Tracing Support
+
+	// Spans are exported to an OpenTelemetry collector over OTLP/HTTP, like
+	// http://localhost:4318/v1/traces. Without an endpoint requests still
+	// continue the traces of their callers, but no spans are recorded.
+	log.Infow("startup", "status", "initializing tracing support", "endpoint", cfg.Tracing.Endpoint)
+
+	var exporter tracer.Exporter
+	if cfg.Tracing.Endpoint != "" {
+		exporter = tracer.NewOTLP(cfg.Tracing.Endpoint, cfg.Tracing.ServiceName, nil, cfg.Tracing.Timeout)
+	}
+
+	trc := tracer.New(log, tracer.Config{
+		Exporter:    exporter,
+		Probability: cfg.Tracing.Probability,
+	})
+	defer func() {
+		log.Infow("shutdown", "status", "stopping tracing support")
+		trc.Shutdown()
+	}()
+
+	// =========================================================================
+	// Construct API Mux
+
+	log.Infow("startup", "status", "initializing V1 API support")
+
+	// Behind a proxy the clients are only told apart by the IP addresses in
+	// the X-Forwarded-For header, which only the trusted proxies can set.
+	proxies, err := web.ParseNetworks(cfg.Web.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("parsing trusted proxies: %w", err)
+	}
+
+	// Make a channel to listen for an interrupt or terminate signal from the OS.
+	// Use a buffered channel because the signal package requires it.
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
+
+	apiMux := handlers.APIMux(handlers.APIMuxConfig{
+		Shutdown:        shutdown,
+		Log:             log,
+		Auth:            auth,
+		TrustedProxies:  proxies,
+		DB:              db,
+		Revocations:     revocations,
+		Policy:          policy,
+		RefreshTokenTTL: cfg.Auth.RefreshTokenTTL,
+		JWKSMaxAge:      cfg.Auth.JWKSMaxAge,
+		AccountLockout: lockout.Config{
+			Threshold: cfg.Lockout.AccountThreshold,
+			BaseDelay: cfg.Lockout.BaseDelay,
+			MaxDelay:  cfg.Lockout.MaxDelay,
+			Window:    cfg.Lockout.Window,
+		},
+		IPLockout: lockout.Config{
+			Threshold: cfg.Lockout.IPThreshold,
+			BaseDelay: cfg.Lockout.BaseDelay,
+			MaxDelay:  cfg.Lockout.MaxDelay,
+			Window:    cfg.Lockout.Window,
+		},
+		MFA: mfa.Config{
+			Issuer:       cfg.MFA.Issuer,
+			ChallengeTTL: cfg.MFA.ChallengeTTL,
+			RequireAdmin: cfg.MFA.RequireAdmin,
+			SecretKey:    []byte(cfg.MFA.SecretKey),
+		},
+		Account: account.Config{
+			Key:       []byte(cfg.Account.TokenKey),
+			BaseURL:   cfg.Account.BaseURL,
+			ResetTTL:  cfg.Account.ResetTTL,
+			VerifyTTL: cfg.Account.VerifyTTL,
+		},
+		Mailer:         mailer,
+		RequireIfMatch: cfg.Users.RequireIfMatch,
+		Tracer:         trc,
+		RateLimitStore: rateLimits,
+		RateLimit: ratelimit.Limit{
+			Requests: cfg.RateLimit.Requests,
+			Period:   cfg.RateLimit.Period,
+			Burst:    cfg.RateLimit.Burst,
+		},
+		IPRateLimit: ratelimit.Limit{
+			Requests: cfg.RateLimit.IPRequests,
+			Period:   cfg.RateLimit.Period,
+			Burst:    cfg.RateLimit.IPBurst,
+		},
+	})
+
 	// =========================================================================
 	// Start Debug Service
 
 	log.Infow("startup", "status", "debug v1 router started", "host", cfg.Web.DebugHost)
 
+	// The OpenAPI document is generated from the routes of the API, so the
+	// API mux is constructed first.
+	spec, err := handlers.OpenAPI(apiMux, build)
+	if err != nil {
+		return fmt.Errorf("generating openapi document: %w", err)
+	}
+
 	// The Debug function returns a mux to listen and serve on for all the debug
 	// related endpoints. This includes the standard library endpoints.
 
 	// Construct the mux for the debug calls.
-	debugMux := handlers.DebugMux(build, log, db)
+	debugMux := handlers.DebugMux(handlers.DebugMuxConfig{
+		Build:          build,
+		Log:            log,
+		DB:             db,
+		Auth:           auth,
+		KeyStore:       ks,
+		KeyRotation:    keyRotation,
+		KeyGracePeriod: cfg.Auth.KeyGracePeriod,
+		OpenAPI:        spec,
+	})
 
 	// Start the service listening for debug requests.
 	// Not concerned with shutting this down with load shedding.
@@ -181,15 +475,6 @@ func run(log *zap.SugaredLogger) error {
 	// =========================================================================
 	// Start API Service
 
-	log.Infow("startup", "status", "initializing V1 API support")
-
-	// Make a channel to listen for an interrupt or terminate signal from the OS.
-	// Use a buffered channel because the signal package requires it.
-	shutdown := make(chan os.Signal, 1)
-	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
-
-	apiMux := handlers.APIMux(shutdown, log, auth, db)
-
 	// Construct a server to service the requests against the mux.
 	api := http.Server{
 		Addr:         cfg.Web.APIHost,
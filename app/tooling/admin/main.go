@@ -5,37 +5,61 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"time"
 
+	"github.com/ardanlabs/service/business/core/user"
 	"github.com/ardanlabs/service/business/data/dbschema"
 	"github.com/ardanlabs/service/business/sys/auth"
 	"github.com/ardanlabs/service/business/sys/database"
 	"github.com/ardanlabs/service/foundation/keystore"
 	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
 )
 
 func main() {
-	err := migrate()
+	var err error
+
+	switch cmd := subcommand(); cmd {
+	case "", "migrate":
+		err = migrate()
+	case "users-import":
+		err = usersImport(os.Args[2:])
+	case "users-export":
+		err = usersExport(os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown command %q, use migrate, users-import or users-export", cmd)
+	}
 
 	if err != nil {
 		log.Fatalln(err)
 	}
 }
 
-func migrate() error {
-	dbConfig := database.Config{
-		User:       "postgres",
-		Password:   "postgres",
-		Host:       "localhost",
-		Name:       "postgres",
-		DisableTLS: true,
+// subcommand returns the command to run, which is empty when none is given.
+func subcommand() string {
+	if len(os.Args) < 2 {
+		return ""
 	}
+	return os.Args[1]
+}
 
+// dbConfig is the database the commands work with.
+var dbConfig = database.Config{
+	User:       "postgres",
+	Password:   "postgres",
+	Host:       "localhost",
+	Name:       "postgres",
+	DisableTLS: true,
+}
+
+func migrate() error {
 	db, err := database.Open(dbConfig)
 	if err != nil {
 		return fmt.Errorf("connect database: %w", err)
@@ -60,6 +84,92 @@ func migrate() error {
 	return nil
 }
 
+// usersImport creates the users in a CSV or NDJSON file, or read from stdin
+// when the file is "-", and prints the outcome of every row as JSON.
+func usersImport(args []string) error {
+	fs := flag.NewFlagSet("users-import", flag.ContinueOnError)
+	format := fs.String("format", user.FormatCSV, "format of the file: csv or ndjson")
+	mode := fs.String("mode", user.ImportAtomic, "atomic or best-effort")
+	dryRun := fs.Bool("dry-run", false, "check the rows without creating users")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("usage: users-import [-format csv|ndjson] [-mode atomic|best-effort] [-dry-run] <file|->")
+	}
+
+	in := os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return fmt.Errorf("opening file: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	db, err := database.Open(dbConfig)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer db.Close()
+
+	opts := user.ImportOptions{
+		Format: *format,
+		Mode:   *mode,
+		DryRun: *dryRun,
+	}
+
+	core := user.NewCore(zap.NewNop().Sugar(), db)
+	result, err := core.Import(context.Background(), in, opts, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("importing users: %w", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		return fmt.Errorf("writing result: %w", err)
+	}
+
+	if result.Failed > 0 {
+		return fmt.Errorf("%d of %d rows failed", result.Failed, len(result.Rows))
+	}
+
+	return nil
+}
+
+// usersExport writes the users to stdout as CSV or NDJSON.
+func usersExport(args []string) error {
+	fs := flag.NewFlagSet("users-export", flag.ContinueOnError)
+	format := fs.String("format", user.FormatCSV, "format of the export: csv or ndjson")
+	includeDeleted := fs.Bool("include-deleted", false, "export deleted users as well")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := database.Open(dbConfig)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer db.Close()
+
+	filter := user.QueryFilter{
+		IncludeDeleted: *includeDeleted,
+	}
+
+	core := user.NewCore(zap.NewNop().Sugar(), db)
+	n, err := core.Export(context.Background(), os.Stdout, *format, filter)
+	if err != nil {
+		return fmt.Errorf("exporting users: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "%d users exported\n", n)
+
+	return nil
+}
+
 func gentoken2() error {
 
 	// Construct a key store based on the key files stored in
@@ -0,0 +1,313 @@
+// Package account provides support for recovering a forgotten password and
+// verifying the email of a user. Both work by emailing the user a signed
+// token that expires and can only be used once.
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/account/db"
+	"github.com/ardanlabs/service/business/core/audit"
+	auditdb "github.com/ardanlabs/service/business/core/audit/db"
+	refreshdb "github.com/ardanlabs/service/business/core/refresh/db"
+	"github.com/ardanlabs/service/business/core/revocation"
+	revocationdb "github.com/ardanlabs/service/business/core/revocation/db"
+	userdb "github.com/ardanlabs/service/business/core/user/db"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/foundation/mail"
+	"github.com/ardanlabs/service/foundation/tracer"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Set of error variables for account operations.
+var (
+	ErrInvalidID    = errors.New("ID is not in its proper form")
+	ErrNotFound     = errors.New("user not found")
+	ErrInvalidToken = errors.New("token is not valid")
+)
+
+// Config represents the settings for account tokens. The key signs the
+// tokens and the base URL is used to build the links that are emailed.
+type Config struct {
+	Key       []byte
+	BaseURL   string
+	ResetTTL  time.Duration
+	VerifyTTL time.Duration
+}
+
+// Core manages the set of APIs for account access.
+type Core struct {
+	log        *zap.SugaredLogger
+	db         *sqlx.DB
+	store      db.Store
+	user       userdb.Store
+	refresh    refreshdb.Store
+	revocation revocationdb.Store
+	audit      auditdb.Store
+	mailer     mail.Mailer
+	cfg        Config
+}
+
+// auditEntity names users in the audit log, like the user core does.
+const auditEntity = "user"
+
+// NewCore constructs a core for account api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB, mailer mail.Mailer, cfg Config) Core {
+	return Core{
+		log:        log,
+		db:         sqlxDB,
+		store:      db.NewStore(log, sqlxDB),
+		user:       userdb.NewStore(log, sqlxDB),
+		refresh:    refreshdb.NewStore(log, sqlxDB),
+		revocation: revocationdb.NewStore(log, sqlxDB),
+		audit:      auditdb.NewStore(log, sqlxDB),
+		mailer:     mailer,
+		cfg:        cfg,
+	}
+}
+
+// ForgotPassword emails a password reset link to the user with the
+// specified email. Nothing happens if there is no such user, so callers
+// can't tell which emails exist.
+func (c Core) ForgotPassword(ctx context.Context, fp ForgotPassword, now time.Time) error {
+	ctx, span := tracer.Start(ctx, "account.ForgotPassword")
+	defer span.End()
+
+	if err := validate.Check(fp); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	dbUsr, err := c.user.QueryByEmail(ctx, fp.Email)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return nil
+		}
+		return fmt.Errorf("query: %w", err)
+	}
+
+	token, err := c.issue(ctx, dbUsr.ID, purposeReset, c.cfg.ResetTTL, now)
+	if err != nil {
+		return err
+	}
+
+	msg := mail.Message{
+		To:      dbUsr.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Use the link below to reset your password. It expires in %v.\n\n%s\n\nIf you didn't ask to reset your password you can ignore this email.\n",
+			c.cfg.ResetTTL, c.link("/users/password/reset", token)),
+	}
+	c.send(ctx, msg)
+
+	return nil
+}
+
+// ResetPassword sets a new password for the user the token was issued to.
+// Every token and session the user had is revoked, since the old password
+// may have been compromised. Receiving the token also proves the user owns
+// the email.
+func (c Core) ResetPassword(ctx context.Context, rp ResetPassword, now time.Time) error {
+	ctx, span := tracer.Start(ctx, "account.ResetPassword")
+	defer span.End()
+
+	if err := validate.Check(rp); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(rp.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("generating password hash: %w", err)
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		dbUsr, err := c.use(ctx, tx, rp.Token, purposeReset, now)
+		if err != nil {
+			return err
+		}
+
+		before := toAuditUser(dbUsr)
+
+		dbUsr.PasswordHash = hash
+		dbUsr.EmailVerified = true
+		dbUsr.DateUpdated = now
+
+		if err := c.user.Tran(tx).Update(ctx, dbUsr); err != nil {
+			return fmt.Errorf("reset: %w", err)
+		}
+
+		if err := c.revocation.Tran(tx).Create(ctx, revocation.NewUserRevocation(dbUsr.ID, now)); err != nil {
+			return fmt.Errorf("revoking tokens: %w", err)
+		}
+
+		if err := c.refresh.Tran(tx).RevokeUser(ctx, dbUsr.ID, now); err != nil {
+			return fmt.Errorf("revoking refresh tokens: %w", err)
+		}
+
+		after := toAuditUser(dbUsr)
+		after.Version++
+
+		if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionResetPassword, auditEntity, dbUsr.ID, before, after, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	return database.WithinTran(ctx, c.log, c.db, tran)
+}
+
+// SendVerification emails a link to verify the email of the user. Nothing
+// happens if the email is already verified.
+func (c Core) SendVerification(ctx context.Context, userID string, now time.Time) error {
+	ctx, span := tracer.Start(ctx, "account.SendVerification")
+	defer span.End()
+
+	if err := validate.CheckID(userID); err != nil {
+		return ErrInvalidID
+	}
+
+	dbUsr, err := c.user.QueryByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("query: %w", err)
+	}
+
+	if dbUsr.EmailVerified {
+		return nil
+	}
+
+	token, err := c.issue(ctx, dbUsr.ID, purposeVerify, c.cfg.VerifyTTL, now)
+	if err != nil {
+		return err
+	}
+
+	msg := mail.Message{
+		To:      dbUsr.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Use the link below to verify your email. It expires in %v.\n\n%s\n",
+			c.cfg.VerifyTTL, c.link("/users/verify", token)),
+	}
+	c.send(ctx, msg)
+
+	return nil
+}
+
+// VerifyEmail marks the email of the user the token was issued to as
+// verified.
+func (c Core) VerifyEmail(ctx context.Context, token string, now time.Time) error {
+	ctx, span := tracer.Start(ctx, "account.VerifyEmail")
+	defer span.End()
+
+	tran := func(tx sqlx.ExtContext) error {
+		dbUsr, err := c.use(ctx, tx, token, purposeVerify, now)
+		if err != nil {
+			return err
+		}
+
+		before := toAuditUser(dbUsr)
+
+		dbUsr.EmailVerified = true
+		dbUsr.DateUpdated = now
+
+		if err := c.user.Tran(tx).Update(ctx, dbUsr); err != nil {
+			return fmt.Errorf("verify: %w", err)
+		}
+
+		after := toAuditUser(dbUsr)
+		after.Version++
+
+		if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionUpdate, auditEntity, dbUsr.ID, before, after, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	return database.WithinTran(ctx, c.log, c.db, tran)
+}
+
+// =============================================================================
+
+// issue records a new token for the user and returns it signed.
+func (c Core) issue(ctx context.Context, userID string, purpose string, ttl time.Duration, now time.Time) (string, error) {
+	dbTkn := db.Token{
+		ID:          validate.GenerateID(),
+		UserID:      userID,
+		Purpose:     purpose,
+		DateCreated: now,
+		DateExpires: now.Add(ttl),
+	}
+
+	if err := c.store.Create(ctx, dbTkn); err != nil {
+		return "", fmt.Errorf("create: %w", err)
+	}
+
+	token, err := sign(c.cfg.Key, claims{ID: dbTkn.ID, Purpose: purpose, ExpiresAt: dbTkn.DateExpires.Unix()})
+	if err != nil {
+		return "", fmt.Errorf("signing token: %w", err)
+	}
+
+	return token, nil
+}
+
+// use checks the token and marks it as used, returning the user it was
+// issued to. The signature and expiry are checked before touching the
+// database, the record makes sure the token is only used once.
+func (c Core) use(ctx context.Context, tx sqlx.ExtContext, token string, purpose string, now time.Time) (userdb.User, error) {
+	cl, err := parse(c.cfg.Key, token, purpose, now)
+	if err != nil {
+		return userdb.User{}, err
+	}
+
+	if err := validate.CheckID(cl.ID); err != nil {
+		return userdb.User{}, ErrInvalidToken
+	}
+
+	dbTkn, err := c.store.Tran(tx).QueryByIDForUpdate(ctx, cl.ID)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return userdb.User{}, ErrInvalidToken
+		}
+		return userdb.User{}, fmt.Errorf("token: %w", err)
+	}
+
+	if dbTkn.DateUsed != nil || dbTkn.Purpose != purpose || !now.Before(dbTkn.DateExpires) {
+		return userdb.User{}, ErrInvalidToken
+	}
+
+	if err := c.store.Tran(tx).Use(ctx, dbTkn.ID, now); err != nil {
+		return userdb.User{}, fmt.Errorf("token: %w", err)
+	}
+
+	dbUsr, err := c.user.Tran(tx).QueryByID(ctx, dbTkn.UserID)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return userdb.User{}, ErrInvalidToken
+		}
+		return userdb.User{}, fmt.Errorf("query: %w", err)
+	}
+
+	return dbUsr, nil
+}
+
+// link builds the URL emailed to the user for the token.
+func (c Core) link(path string, token string) string {
+	return c.cfg.BaseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// send emails the message. Delivery is best effort: a failure is logged so
+// it doesn't reveal to the caller whether the user exists, and the user can
+// always ask for a new email.
+func (c Core) send(ctx context.Context, msg mail.Message) {
+	if err := c.mailer.Send(ctx, msg); err != nil {
+		c.log.Errorw("mail", "status", "sending", "subject", msg.Subject, "ERROR", err)
+	}
+}
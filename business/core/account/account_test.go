@@ -0,0 +1,155 @@
+package account_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/account"
+	"github.com/ardanlabs/service/business/core/user"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/foundation/docker"
+	"github.com/ardanlabs/service/foundation/mail"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+// mailbox keeps the messages sent so the tests can read the tokens.
+type mailbox struct {
+	msgs []mail.Message
+}
+
+func (m *mailbox) Send(ctx context.Context, msg mail.Message) error {
+	m.msgs = append(m.msgs, msg)
+	return nil
+}
+
+// token extracts the token from the link in the last message.
+func (m *mailbox) token() string {
+	if len(m.msgs) == 0 {
+		return ""
+	}
+
+	match := regexp.MustCompile(`token=(\S+)`).FindStringSubmatch(m.msgs[len(m.msgs)-1].Body)
+	if match == nil {
+		return ""
+	}
+
+	token, _ := url.QueryUnescape(match[1])
+	return token
+}
+
+func TestAccount(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testaccount")
+	t.Cleanup(teardown)
+
+	mb := mailbox{}
+	core := account.NewCore(log, db, &mb, account.Config{
+		Key:       []byte("test key"),
+		BaseURL:   "http://localhost:3000",
+		ResetTTL:  time.Hour,
+		VerifyTTL: time.Hour,
+	})
+	usrCore := user.NewCore(log, db)
+
+	t.Log("Given the need to recover passwords and verify emails.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen resetting a forgotten password.", testID)
+		{
+			ctx := context.Background()
+			now := time.Now().UTC()
+
+			if err := core.ForgotPassword(ctx, account.ForgotPassword{Email: "nobody@example.com"}, now); err != nil || len(mb.msgs) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould silently ignore unknown emails : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould silently ignore unknown emails.", dbtest.Success, testID)
+
+			if err := core.ForgotPassword(ctx, account.ForgotPassword{Email: "user@example.com"}, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to request a reset : %s.", dbtest.Failed, testID, err)
+			}
+
+			token := mb.token()
+			if token == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould email a reset link.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould email a reset link.", dbtest.Success, testID)
+
+			if err := core.VerifyEmail(ctx, token, now); !errors.Is(err, account.ErrInvalidToken) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept a reset token for verification : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept a reset token for verification.", dbtest.Success, testID)
+
+			rp := account.ResetPassword{
+				Token:           token,
+				Password:        "new gophers",
+				PasswordConfirm: "new gophers",
+			}
+			if err := core.ResetPassword(ctx, rp, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reset the password : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to reset the password.", dbtest.Success, testID)
+
+			if _, err := usrCore.Authenticate(ctx, now, "user@example.com", "new gophers"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate with the new password : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to authenticate with the new password.", dbtest.Success, testID)
+
+			if err := core.ResetPassword(ctx, rp, now); !errors.Is(err, account.ErrInvalidToken) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to use a token twice : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to use a token twice.", dbtest.Success, testID)
+
+			rp.Token = token[:len(token)-2] + "xx"
+			if err := core.ResetPassword(ctx, rp, now); !errors.Is(err, account.ErrInvalidToken) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept a tampered token : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept a tampered token.", dbtest.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen verifying an email.", testID)
+		{
+			ctx := context.Background()
+			now := time.Now().UTC()
+
+			const userID = "5cf37266-3473-4006-984f-9325122678b7"
+
+			if err := core.SendVerification(ctx, userID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to send a verification : %s.", dbtest.Failed, testID, err)
+			}
+
+			token := mb.token()
+			if err := core.VerifyEmail(ctx, token, now.Add(2*time.Hour)); !errors.Is(err, account.ErrInvalidToken) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept an expired token : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept an expired token.", dbtest.Success, testID)
+
+			if err := core.VerifyEmail(ctx, token, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to verify the email : %s.", dbtest.Failed, testID, err)
+			}
+
+			usr, err := usrCore.QueryByID(ctx, userID)
+			if err != nil || !usr.EmailVerified {
+				t.Fatalf("\t%s\tTest %d:\tShould see the email verified : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see the email verified.", dbtest.Success, testID)
+		}
+	}
+}
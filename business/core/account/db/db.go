@@ -0,0 +1,101 @@
+// Package db contains account token related CRUD functionality.
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for account token access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Tran returns a new Store value bound to the specified transaction so
+// several calls can be made as a single unit of work.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log: s.log,
+		db:  tx,
+	}
+}
+
+// Create inserts a new token into the database.
+func (s Store) Create(ctx context.Context, tkn Token) error {
+	const q = `
+	INSERT INTO user_tokens
+		(token_id, user_id, purpose, date_created, date_expires)
+	VALUES
+		(:token_id, :user_id, :purpose, :date_created, :date_expires)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, tkn); err != nil {
+		return fmt.Errorf("inserting token: %w", err)
+	}
+
+	return nil
+}
+
+// Use marks the token as used.
+func (s Store) Use(ctx context.Context, tokenID string, now time.Time) error {
+	data := struct {
+		TokenID  string    `db:"token_id"`
+		DateUsed time.Time `db:"date_used"`
+	}{
+		TokenID:  tokenID,
+		DateUsed: now,
+	}
+
+	const q = `
+	UPDATE
+		user_tokens
+	SET
+		"date_used" = :date_used
+	WHERE
+		token_id = :token_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("using tokenID[%s]: %w", tokenID, err)
+	}
+
+	return nil
+}
+
+// QueryByIDForUpdate gets the specified token from the database and locks
+// the row until the enclosing transaction completes.
+func (s Store) QueryByIDForUpdate(ctx context.Context, tokenID string) (Token, error) {
+	data := struct {
+		TokenID string `db:"token_id"`
+	}{
+		TokenID: tokenID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		user_tokens
+	WHERE
+		token_id = :token_id
+	FOR UPDATE`
+
+	var tkn Token
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &tkn); err != nil {
+		return Token{}, fmt.Errorf("selecting tokenID[%s]: %w", tokenID, err)
+	}
+
+	return tkn, nil
+}
@@ -0,0 +1,28 @@
+package db
+
+import "time"
+
+// Token represent the structure we need for moving data
+// between the app and the database.
+type Token struct {
+	ID          string     `db:"token_id"`
+	UserID      string     `db:"user_id"`
+	Purpose     string     `db:"purpose"`
+	DateCreated time.Time  `db:"date_created"`
+	DateExpires time.Time  `db:"date_expires"`
+	DateUsed    *time.Time `db:"date_used"`
+}
+
+/*
+CREATE TABLE user_tokens (
+	token_id     UUID,
+	user_id      UUID,
+	purpose      TEXT,
+	date_created TIMESTAMP,
+	date_expires TIMESTAMP,
+	date_used    TIMESTAMP NULL,
+
+	PRIMARY KEY (token_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+*/
@@ -0,0 +1,39 @@
+package account
+
+import (
+	"time"
+
+	userdb "github.com/ardanlabs/service/business/core/user/db"
+)
+
+// ForgotPassword contains the information needed to request a password
+// reset.
+type ForgotPassword struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPassword contains the information needed to reset a password with
+// the token that was emailed to the user.
+type ResetPassword struct {
+	Token           string `json:"token" validate:"required"`
+	Password        string `json:"password" validate:"required"`
+	PasswordConfirm string `json:"password_confirm" validate:"eqfield=Password"`
+}
+
+// auditUser is the part of a user the account changes, with the names the
+// user core records, so the audit entries of a user read the same.
+type auditUser struct {
+	EmailVerified bool      `json:"email_verified"`
+	DateUpdated   time.Time `json:"date_updated"`
+	Version       int       `json:"version"`
+}
+
+// =============================================================================
+
+func toAuditUser(dbUsr userdb.User) auditUser {
+	return auditUser{
+		EmailVerified: dbUsr.EmailVerified,
+		DateUpdated:   dbUsr.DateUpdated,
+		Version:       dbUsr.Version,
+	}
+}
@@ -0,0 +1,72 @@
+package account
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Set of purposes a token can be issued for. A token is only accepted for
+// the purpose it was issued for.
+const (
+	purposeReset  = "reset"
+	purposeVerify = "verify"
+)
+
+// claims represents the signed content of a token.
+type claims struct {
+	ID        string `json:"id"`
+	Purpose   string `json:"pur"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// sign encodes the claims and signs them with the key. The token has the
+// form <claims>.<signature>, both base64url encoded.
+func sign(key []byte, c claims) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + signature(key, payload), nil
+}
+
+// parse checks the signature of the token and returns its claims if it was
+// issued for the purpose and hasn't expired.
+func parse(key []byte, token string, purpose string, now time.Time) (claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return claims{}, ErrInvalidToken
+	}
+
+	if !hmac.Equal([]byte(parts[1]), []byte(signature(key, parts[0]))) {
+		return claims{}, ErrInvalidToken
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return claims{}, ErrInvalidToken
+	}
+
+	var c claims
+	if err := json.Unmarshal(data, &c); err != nil {
+		return claims{}, ErrInvalidToken
+	}
+
+	if c.Purpose != purpose || now.Unix() >= c.ExpiresAt {
+		return claims{}, ErrInvalidToken
+	}
+
+	return c, nil
+}
+
+// signature returns the HMAC-SHA256 signature of the payload.
+func signature(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,330 @@
+// Package apikey provides support for long-lived API keys that let programs
+// authenticate as a user without the user's password. A key has the form
+// sales_<prefix>_<secret>. The prefix identifies the key and is stored as is,
+// only a hash of the secret is stored.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/apikey/db"
+	"github.com/ardanlabs/service/business/core/audit"
+	auditdb "github.com/ardanlabs/service/business/core/audit/db"
+	userdb "github.com/ardanlabs/service/business/core/user/db"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/foundation/tracer"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of error variables for API key operations.
+var (
+	ErrNotFound     = errors.New("api key not found")
+	ErrInvalidID    = errors.New("ID is not in its proper form")
+	ErrInvalidScope = errors.New("scope is not held by the user")
+	ErrExpired      = errors.New("expiry date must be in the future")
+)
+
+// keyPrefix starts every key so keys are easy to recognize, for example by
+// secret scanners.
+const keyPrefix = "sales"
+
+// lastUsedPrecision is how stale the last used time of a key can get, so
+// authenticating doesn't write to the database on every request.
+const lastUsedPrecision = time.Minute
+
+// encoding is used for the random parts of a key.
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// auditEntity names API keys in the audit log.
+const auditEntity = "api_key"
+
+// Core manages the set of APIs for API key access.
+type Core struct {
+	log   *zap.SugaredLogger
+	db    *sqlx.DB
+	store db.Store
+	user  userdb.Store
+	audit auditdb.Store
+}
+
+// NewCore constructs a core for API key api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Core {
+	return Core{
+		log:   log,
+		db:    sqlxDB,
+		store: db.NewStore(log, sqlxDB),
+		user:  userdb.NewStore(log, sqlxDB),
+		audit: auditdb.NewStore(log, sqlxDB),
+	}
+}
+
+// Create generates a new API key for the user.
+func (c Core) Create(ctx context.Context, userID string, nk NewAPIKey, now time.Time) (CreatedAPIKey, error) {
+	ctx, span := tracer.Start(ctx, "apikey.Create")
+	defer span.End()
+
+	if err := validate.CheckID(userID); err != nil {
+		return CreatedAPIKey{}, ErrInvalidID
+	}
+
+	if err := validate.Check(nk); err != nil {
+		return CreatedAPIKey{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	if nk.DateExpires != nil && !nk.DateExpires.After(now) {
+		return CreatedAPIKey{}, ErrExpired
+	}
+
+	dbUsr, err := c.user.QueryByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return CreatedAPIKey{}, ErrNotFound
+		}
+		return CreatedAPIKey{}, fmt.Errorf("query: %w", err)
+	}
+
+	for _, scope := range nk.Scopes {
+		if !contains(dbUsr.Roles, scope) {
+			return CreatedAPIKey{}, fmt.Errorf("scope[%s]: %w", scope, ErrInvalidScope)
+		}
+	}
+
+	prefix, err := random(6)
+	if err != nil {
+		return CreatedAPIKey{}, err
+	}
+
+	secret, err := random(32)
+	if err != nil {
+		return CreatedAPIKey{}, err
+	}
+
+	var expires *time.Time
+	if nk.DateExpires != nil {
+		t := nk.DateExpires.UTC()
+		expires = &t
+	}
+
+	dbKey := db.APIKey{
+		ID:          validate.GenerateID(),
+		UserID:      userID,
+		Name:        nk.Name,
+		Prefix:      prefix,
+		KeyHash:     hash(secret),
+		Scopes:      nk.Scopes,
+		DateCreated: now,
+		DateExpires: expires,
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		if err := c.store.Tran(tx).Create(ctx, dbKey); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+
+		if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionCreate, auditEntity, dbKey.ID, nil, toAPIKey(dbKey), now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return CreatedAPIKey{}, err
+	}
+
+	ck := CreatedAPIKey{
+		Key:    keyPrefix + "_" + prefix + "_" + secret,
+		APIKey: toAPIKey(dbKey),
+	}
+
+	return ck, nil
+}
+
+// Revoke revokes the API key, which can't be used anymore.
+func (c Core) Revoke(ctx context.Context, keyID string, now time.Time) error {
+	ctx, span := tracer.Start(ctx, "apikey.Revoke")
+	defer span.End()
+
+	if err := validate.CheckID(keyID); err != nil {
+		return ErrInvalidID
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		dbKey, err := store.QueryByID(ctx, keyID)
+		if err != nil {
+
+			// Revoking a key that doesn't exist changes nothing to audit.
+			if errors.Is(err, database.ErrDBNotFound) {
+				return nil
+			}
+			return fmt.Errorf("revoke: %w", err)
+		}
+
+		if dbKey.DateRevoked != nil {
+			return nil
+		}
+
+		if err := store.Revoke(ctx, keyID, now); err != nil {
+			return fmt.Errorf("revoke: %w", err)
+		}
+
+		before := toAPIKey(dbKey)
+		dbKey.DateRevoked = &now
+
+		if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionRevoke, auditEntity, keyID, before, toAPIKey(dbKey), now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	return database.WithinTran(ctx, c.log, c.db, tran)
+}
+
+// QueryByID gets the specified API key from the database.
+func (c Core) QueryByID(ctx context.Context, keyID string) (APIKey, error) {
+	ctx, span := tracer.Start(ctx, "apikey.QueryByID")
+	defer span.End()
+
+	if err := validate.CheckID(keyID); err != nil {
+		return APIKey{}, ErrInvalidID
+	}
+
+	dbKey, err := c.store.QueryByID(ctx, keyID)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return APIKey{}, ErrNotFound
+		}
+		return APIKey{}, fmt.Errorf("query: %w", err)
+	}
+
+	return toAPIKey(dbKey), nil
+}
+
+// QueryByUserID gets the API keys of the specified user, including the ones
+// that expired or were revoked.
+func (c Core) QueryByUserID(ctx context.Context, userID string) ([]APIKey, error) {
+	ctx, span := tracer.Start(ctx, "apikey.QueryByUserID")
+	defer span.End()
+
+	if err := validate.CheckID(userID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	dbKeys, err := c.store.QueryByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toAPIKeySlice(dbKeys), nil
+}
+
+// ClaimsByAPIKey implements the auth.APIKeyLookup interface. The claims carry
+// the roles of the user that are within the scopes of the key, so a key
+// loses a role when its user does.
+func (c Core) ClaimsByAPIKey(ctx context.Context, key string, now time.Time) (auth.Claims, error) {
+	ctx, span := tracer.Start(ctx, "apikey.ClaimsByAPIKey")
+	defer span.End()
+
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != keyPrefix {
+		return auth.Claims{}, auth.ErrInvalidAPIKey
+	}
+
+	dbKey, err := c.store.QueryByPrefix(ctx, parts[1])
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return auth.Claims{}, auth.ErrInvalidAPIKey
+		}
+		return auth.Claims{}, fmt.Errorf("query: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hash(parts[2])), []byte(dbKey.KeyHash)) != 1 {
+		return auth.Claims{}, auth.ErrInvalidAPIKey
+	}
+
+	if dbKey.DateRevoked != nil || (dbKey.DateExpires != nil && !now.Before(*dbKey.DateExpires)) {
+		return auth.Claims{}, auth.ErrInvalidAPIKey
+	}
+
+	dbUsr, err := c.user.QueryByID(ctx, dbKey.UserID)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return auth.Claims{}, auth.ErrInvalidAPIKey
+		}
+		return auth.Claims{}, fmt.Errorf("query: %w", err)
+	}
+
+	if dbKey.DateLastUsed == nil || now.Sub(*dbKey.DateLastUsed) >= lastUsedPrecision {
+		if err := c.store.UpdateLastUsed(ctx, dbKey.ID, now); err != nil {
+			return auth.Claims{}, fmt.Errorf("update: %w", err)
+		}
+	}
+
+	var roles []string
+	for _, scope := range dbKey.Scopes {
+		if contains(dbUsr.Roles, scope) {
+			roles = append(roles, scope)
+		}
+	}
+
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:  dbUsr.ID,
+			Issuer:   "service project",
+			IssuedAt: jwt.NewNumericDate(now.UTC()),
+		},
+		Roles:    roles,
+		APIKeyID: dbKey.ID,
+	}
+	if dbKey.DateExpires != nil {
+		claims.ExpiresAt = jwt.NewNumericDate(*dbKey.DateExpires)
+	}
+
+	return claims, nil
+}
+
+// =============================================================================
+
+// random returns n random bytes encoded in lower case base32.
+func random(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating key: %w", err)
+	}
+
+	return strings.ToLower(encoding.EncodeToString(b)), nil
+}
+
+// hash returns the hash of the secret that is stored in the database. The
+// secrets have enough entropy that a fast hash is sufficient.
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// contains reports if the value is in the list.
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,103 @@
+package apikey_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/apikey"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/foundation/docker"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func TestAPIKey(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testapikey")
+	t.Cleanup(teardown)
+
+	core := apikey.NewCore(log, db)
+
+	t.Log("Given the need to work with API keys.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling the API key of a user.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			const userID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+			nk := apikey.NewAPIKey{
+				Name:   "batch job",
+				Scopes: []string{auth.RoleAdmin},
+			}
+			if _, err := core.Create(ctx, userID, nk, now); !errors.Is(err, apikey.ErrInvalidScope) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT grant roles the user doesn't hold : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT grant roles the user doesn't hold.", dbtest.Success, testID)
+
+			expires := now.Add(24 * time.Hour)
+			nk = apikey.NewAPIKey{
+				Name:        "batch job",
+				Scopes:      []string{auth.RoleUser},
+				DateExpires: &expires,
+			}
+			key, err := core.Create(ctx, userID, nk, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an API key : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create an API key.", dbtest.Success, testID)
+
+			claims, err := core.ClaimsByAPIKey(ctx, key.Key, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate with the key : %s.", dbtest.Failed, testID, err)
+			}
+
+			if claims.Subject != userID || claims.APIKeyID != key.APIKey.ID || !claims.Authorized(auth.RoleUser) {
+				t.Fatalf("\t%s\tTest %d:\tShould get the claims of the user : %+v.", dbtest.Failed, testID, claims)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the claims of the user.", dbtest.Success, testID)
+
+			if _, err := core.ClaimsByAPIKey(ctx, key.Key+"x", now); !errors.Is(err, auth.ErrInvalidAPIKey) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept a wrong secret : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept a wrong secret.", dbtest.Success, testID)
+
+			if _, err := core.ClaimsByAPIKey(ctx, key.Key, expires); !errors.Is(err, auth.ErrInvalidAPIKey) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept an expired key : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept an expired key.", dbtest.Success, testID)
+
+			keys, err := core.QueryByUserID(ctx, userID)
+			if err != nil || len(keys) != 1 || keys[0].DateLastUsed == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould list the key with its last use : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list the key with its last use.", dbtest.Success, testID)
+
+			if err := core.Revoke(ctx, key.APIKey.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke the key : %s.", dbtest.Failed, testID, err)
+			}
+
+			if _, err := core.ClaimsByAPIKey(ctx, key.Key, now); !errors.Is(err, auth.ErrInvalidAPIKey) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept a revoked key : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept a revoked key.", dbtest.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Package db contains API key related CRUD functionality.
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for API key access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Tran returns a new Store value bound to the specified transaction so
+// several calls can be made as a single unit of work.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log: s.log,
+		db:  tx,
+	}
+}
+
+// Create inserts a new API key into the database.
+func (s Store) Create(ctx context.Context, key APIKey) error {
+	const q = `
+	INSERT INTO api_keys
+		(key_id, user_id, name, prefix, key_hash, scopes, date_created, date_expires)
+	VALUES
+		(:key_id, :user_id, :name, :prefix, :key_hash, :scopes, :date_created, :date_expires)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, key); err != nil {
+		return fmt.Errorf("inserting api key: %w", err)
+	}
+
+	return nil
+}
+
+// Revoke marks the API key as revoked.
+func (s Store) Revoke(ctx context.Context, keyID string, now time.Time) error {
+	data := struct {
+		KeyID       string    `db:"key_id"`
+		DateRevoked time.Time `db:"date_revoked"`
+	}{
+		KeyID:       keyID,
+		DateRevoked: now,
+	}
+
+	const q = `
+	UPDATE
+		api_keys
+	SET
+		"date_revoked" = :date_revoked
+	WHERE
+		key_id = :key_id AND
+		date_revoked IS NULL`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("revoking keyID[%s]: %w", keyID, err)
+	}
+
+	return nil
+}
+
+// UpdateLastUsed records when the API key was last used.
+func (s Store) UpdateLastUsed(ctx context.Context, keyID string, now time.Time) error {
+	data := struct {
+		KeyID        string    `db:"key_id"`
+		DateLastUsed time.Time `db:"date_last_used"`
+	}{
+		KeyID:        keyID,
+		DateLastUsed: now,
+	}
+
+	const q = `
+	UPDATE
+		api_keys
+	SET
+		"date_last_used" = :date_last_used
+	WHERE
+		key_id = :key_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("updating keyID[%s]: %w", keyID, err)
+	}
+
+	return nil
+}
+
+// QueryByID gets the specified API key from the database.
+func (s Store) QueryByID(ctx context.Context, keyID string) (APIKey, error) {
+	data := struct {
+		KeyID string `db:"key_id"`
+	}{
+		KeyID: keyID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		api_keys
+	WHERE
+		key_id = :key_id`
+
+	var key APIKey
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &key); err != nil {
+		return APIKey{}, fmt.Errorf("selecting keyID[%q]: %w", keyID, err)
+	}
+
+	return key, nil
+}
+
+// QueryByPrefix gets the API key with the specified prefix from the database.
+func (s Store) QueryByPrefix(ctx context.Context, prefix string) (APIKey, error) {
+	data := struct {
+		Prefix string `db:"prefix"`
+	}{
+		Prefix: prefix,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		api_keys
+	WHERE
+		prefix = :prefix`
+
+	var key APIKey
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &key); err != nil {
+		return APIKey{}, fmt.Errorf("selecting prefix[%q]: %w", prefix, err)
+	}
+
+	return key, nil
+}
+
+// QueryByUserID gets the API keys of the specified user from the database,
+// newest first.
+func (s Store) QueryByUserID(ctx context.Context, userID string) ([]APIKey, error) {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		api_keys
+	WHERE
+		user_id = :user_id
+	ORDER BY
+		date_created DESC`
+
+	var keys []APIKey
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, data, &keys); err != nil {
+		return nil, fmt.Errorf("selecting api keys userID[%s]: %w", userID, err)
+	}
+
+	return keys, nil
+}
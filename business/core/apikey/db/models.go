@@ -0,0 +1,40 @@
+package db
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIKey represent the structure we need for moving data
+// between the app and the database.
+type APIKey struct {
+	ID           string         `db:"key_id"`
+	UserID       string         `db:"user_id"`
+	Name         string         `db:"name"`
+	Prefix       string         `db:"prefix"`
+	KeyHash      string         `db:"key_hash"`
+	Scopes       pq.StringArray `db:"scopes"`
+	DateCreated  time.Time      `db:"date_created"`
+	DateExpires  *time.Time     `db:"date_expires"`
+	DateLastUsed *time.Time     `db:"date_last_used"`
+	DateRevoked  *time.Time     `db:"date_revoked"`
+}
+
+/*
+CREATE TABLE api_keys (
+	key_id         UUID,
+	user_id        UUID,
+	name           TEXT,
+	prefix         TEXT UNIQUE,
+	key_hash       TEXT,
+	scopes         TEXT[],
+	date_created   TIMESTAMP,
+	date_expires   TIMESTAMP NULL,
+	date_last_used TIMESTAMP NULL,
+	date_revoked   TIMESTAMP NULL,
+
+	PRIMARY KEY (key_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+*/
@@ -0,0 +1,54 @@
+package apikey
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/ardanlabs/service/business/core/apikey/db"
+)
+
+// APIKey represents an API key a user can authenticate with instead of a
+// token. The key itself is only returned when it is created.
+type APIKey struct {
+	ID           string     `json:"id"`
+	UserID       string     `json:"user_id"`
+	Name         string     `json:"name"`
+	Prefix       string     `json:"prefix"`
+	KeyHash      string     `json:"-"`
+	Scopes       []string   `json:"scopes"`
+	DateCreated  time.Time  `json:"date_created"`
+	DateExpires  *time.Time `json:"date_expires,omitempty"`
+	DateLastUsed *time.Time `json:"date_last_used,omitempty"`
+	DateRevoked  *time.Time `json:"date_revoked,omitempty"`
+}
+
+// NewAPIKey contains information needed to create a new APIKey. The scopes
+// are the roles the key grants, which must be held by the user. A key
+// without an expiry date never expires.
+type NewAPIKey struct {
+	Name        string     `json:"name" validate:"required"`
+	Scopes      []string   `json:"scopes" validate:"required,min=1"`
+	DateExpires *time.Time `json:"date_expires"`
+}
+
+// CreatedAPIKey is the result of creating an API key. This is the only time
+// the key is available.
+type CreatedAPIKey struct {
+	Key    string `json:"key"`
+	APIKey APIKey `json:"api_key"`
+}
+
+// =============================================================================
+
+func toAPIKey(dbKey db.APIKey) APIKey {
+	pk := (*APIKey)(unsafe.Pointer(&dbKey))
+	return *pk
+}
+
+func toAPIKeySlice(dbKeys []db.APIKey) []APIKey {
+	keys := make([]APIKey, len(dbKeys))
+	for i, dbKey := range dbKeys {
+		keys[i] = toAPIKey(dbKey)
+	}
+	return keys
+}
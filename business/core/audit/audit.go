@@ -0,0 +1,146 @@
+// Package audit provides support for recording the changes made to entities
+// by the other cores. An entry is written by the core making the change, in
+// the same transaction as the change, so an entry exists exactly when the
+// change was committed.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/audit/db"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/foundation/tracer"
+	"github.com/ardanlabs/service/foundation/web"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of actions recorded in the audit log.
+const (
+	ActionCreate        = "create"
+	ActionUpdate        = "update"
+	ActionDelete        = "delete"
+	ActionRestore       = "restore"
+	ActionPurge         = "purge"
+	ActionRevoke        = "revoke"
+	ActionResetPassword = "reset_password"
+)
+
+// Core manages the set of APIs for audit log access.
+type Core struct {
+	store db.Store
+}
+
+// NewCore constructs a core for audit log api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Core {
+	return Core{
+		store: db.NewStore(log, sqlxDB),
+	}
+}
+
+// Query retrieves a page of audit entries matching the filter, most recent
+// first.
+func (c Core) Query(ctx context.Context, filter QueryFilter, pageNumber int, rowsPerPage int) ([]Audit, error) {
+	ctx, span := tracer.Start(ctx, "audit.Query")
+	defer span.End()
+
+	if err := validate.Check(filter); err != nil {
+		return nil, fmt.Errorf("validating filter: %w", err)
+	}
+
+	dbEntries, err := c.store.Query(ctx, db.QueryFilter(filter), pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toAuditSlice(dbEntries), nil
+}
+
+// Record writes the audit entry for a change to an entity. The store is
+// expected to be bound to the transaction making the change. The before and
+// after values are the entity as it is returned to clients, so fields hidden
+// from JSON are never recorded. Before is nil for a create and after is nil
+// for a delete. The actor is the subject of the claims in the context, empty
+// when the change isn't made on behalf of a user.
+func Record(ctx context.Context, store db.Store, action string, entityType string, entityID string, before interface{}, after interface{}, now time.Time) error {
+	d, err := diff(before, after)
+	if err != nil {
+		return fmt.Errorf("diff: %w", err)
+	}
+
+	var actorID string
+	if claims, err := auth.GetClaims(ctx); err == nil {
+		actorID = claims.Subject
+	}
+
+	entry := db.Audit{
+		ID:          validate.GenerateID(),
+		ActorID:     actorID,
+		Action:      action,
+		EntityType:  entityType,
+		EntityID:    entityID,
+		Diff:        string(d),
+		TraceID:     web.GetTraceID(ctx),
+		DateCreated: now,
+	}
+
+	if err := store.Create(ctx, entry); err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// diff compares the JSON documents of both values field by field and returns
+// a document with the fields that changed.
+func diff(before interface{}, after interface{}) ([]byte, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]change)
+	for name, value := range b {
+		if !bytes.Equal(value, a[name]) {
+			changes[name] = change{Before: value, After: a[name]}
+		}
+	}
+	for name, value := range a {
+		if _, exists := b[name]; !exists {
+			changes[name] = change{After: value}
+		}
+	}
+
+	return json.Marshal(changes)
+}
+
+// fields returns the JSON value of each field of the value.
+func fields(v interface{}) (map[string]json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
@@ -0,0 +1,170 @@
+package audit_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/apikey"
+	"github.com/ardanlabs/service/business/core/audit"
+	"github.com/ardanlabs/service/business/core/user"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/foundation/docker"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func TestAudit(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testaudit")
+	t.Cleanup(teardown)
+
+	core := audit.NewCore(log, db)
+	usrCore := user.NewCore(log, db)
+
+	t.Log("Given the need to audit the changes made to entities.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen changing a user on behalf of an admin.", testID)
+		{
+			const actorID = "5cf37266-3473-4006-984f-9325122678b7"
+
+			claims := auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{Subject: actorID},
+				Roles:            []string{auth.RoleAdmin},
+			}
+			ctx := auth.SetClaims(context.Background(), claims)
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			nu := user.NewUser{
+				Name:            "Bill Kennedy",
+				Email:           "bill@ardanlabs.com",
+				Roles:           []string{auth.RoleUser},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+			usr, err := usrCore.Create(ctx, nu, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", dbtest.Failed, testID, err)
+			}
+
+			upd := user.UpdateUser{
+				Name:     dbtest.StringPointer("Jacob Walker"),
+				Password: dbtest.StringPointer("gophers2"),
+			}
+			if _, err := usrCore.Update(ctx, usr.ID, upd, now.Add(time.Hour)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update user : %s.", dbtest.Failed, testID, err)
+			}
+
+			entityType := "user"
+			filter := audit.QueryFilter{
+				EntityType: &entityType,
+				EntityID:   &usr.ID,
+			}
+			entries, err := core.Query(ctx, filter, 1, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query the audit log : %s.", dbtest.Failed, testID, err)
+			}
+
+			if len(entries) != 2 || entries[0].Action != audit.ActionUpdate || entries[1].Action != audit.ActionCreate {
+				t.Fatalf("\t%s\tTest %d:\tShould have an entry for every change, most recent first : %+v.", dbtest.Failed, testID, entries)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have an entry for every change, most recent first.", dbtest.Success, testID)
+
+			if entries[0].ActorID != actorID {
+				t.Fatalf("\t%s\tTest %d:\tShould record the actor : %s.", dbtest.Failed, testID, entries[0].ActorID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record the actor.", dbtest.Success, testID)
+
+			var diff map[string]struct {
+				Before json.RawMessage `json:"before"`
+				After  json.RawMessage `json:"after"`
+			}
+			if err := json.Unmarshal(entries[0].Diff, &diff); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the diff : %s.", dbtest.Failed, testID, err)
+			}
+
+			name, changed := diff["name"]
+			if !changed || string(name.Before) != `"Bill Kennedy"` || string(name.After) != `"Jacob Walker"` {
+				t.Fatalf("\t%s\tTest %d:\tShould record the changed fields : %s.", dbtest.Failed, testID, entries[0].Diff)
+			}
+			if _, changed := diff["email"]; changed {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT record unchanged fields : %s.", dbtest.Failed, testID, entries[0].Diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record only the changed fields.", dbtest.Success, testID)
+
+			// Only the name, the update date and the version change, the new
+			// password hash is never recorded.
+			if len(diff) != 3 {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT record the password hash : %s.", dbtest.Failed, testID, entries[0].Diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT record the password hash.", dbtest.Success, testID)
+
+			start := now.Add(30 * time.Minute)
+			filter.StartDate = &start
+			entries, err = core.Query(ctx, filter, 1, 10)
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to filter by time : %v %+v.", dbtest.Failed, testID, err, entries)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to filter by time.", dbtest.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen creating and revoking an API key.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+			keyCore := apikey.NewCore(log, db)
+
+			nu := user.NewUser{
+				Name:            "Jacob Walker",
+				Email:           "jacob@ardanlabs.com",
+				Roles:           []string{auth.RoleUser},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+			usr, err := usrCore.Create(ctx, nu, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", dbtest.Failed, testID, err)
+			}
+
+			ck, err := keyCore.Create(ctx, usr.ID, apikey.NewAPIKey{Name: "ci", Scopes: []string{auth.RoleUser}}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an API key : %s.", dbtest.Failed, testID, err)
+			}
+			if err := keyCore.Revoke(ctx, ck.APIKey.ID, now.Add(time.Hour)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke the API key : %s.", dbtest.Failed, testID, err)
+			}
+
+			entityType := "api_key"
+			filter := audit.QueryFilter{
+				EntityType: &entityType,
+				EntityID:   &ck.APIKey.ID,
+			}
+			entries, err := core.Query(ctx, filter, 1, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query the audit log : %s.", dbtest.Failed, testID, err)
+			}
+
+			if len(entries) != 2 || entries[0].Action != audit.ActionRevoke || entries[1].Action != audit.ActionCreate {
+				t.Fatalf("\t%s\tTest %d:\tShould have an entry for the creation and the revocation : %+v.", dbtest.Failed, testID, entries)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have an entry for the creation and the revocation.", dbtest.Success, testID)
+		}
+	}
+}
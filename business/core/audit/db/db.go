@@ -0,0 +1,78 @@
+// Package db contains audit log related CRUD functionality.
+package db
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for audit log access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Tran returns a new Store value bound to the specified transaction so
+// several calls can be made as a single unit of work.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log: s.log,
+		db:  tx,
+	}
+}
+
+// Create inserts a new audit entry into the database.
+func (s Store) Create(ctx context.Context, entry Audit) error {
+	const q = `
+	INSERT INTO audit_log
+		(audit_id, actor_id, action, entity_type, entity_id, diff, trace_id, date_created)
+	VALUES
+		(:audit_id, :actor_id, :action, :entity_type, :entity_id, CAST(:diff AS JSONB), :trace_id, :date_created)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, entry); err != nil {
+		return fmt.Errorf("inserting audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// Query retrieves a page of audit entries matching the filter, most recent
+// first.
+func (s Store) Query(ctx context.Context, filter QueryFilter, pageNumber int, rowsPerPage int) ([]Audit, error) {
+	data := map[string]interface{}{
+		"offset":        (pageNumber - 1) * rowsPerPage,
+		"rows_per_page": rowsPerPage,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		audit_log`
+
+	buf := bytes.NewBufferString(q)
+	applyFilter(filter, data, buf)
+
+	buf.WriteString(" ORDER BY date_created DESC, audit_id DESC")
+	buf.WriteString(" OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY")
+
+	var entries []Audit
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, buf.String(), data, &entries); err != nil {
+		return nil, fmt.Errorf("selecting audit entries: %w", err)
+	}
+
+	return entries, nil
+}
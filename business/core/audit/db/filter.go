@@ -0,0 +1,53 @@
+package db
+
+import (
+	"bytes"
+	"strings"
+	"time"
+)
+
+// QueryFilter holds the available fields a query can be filtered on. A nil
+// field is not part of the filter.
+type QueryFilter struct {
+	EntityType *string
+	EntityID   *string
+	ActorID    *string
+	StartDate  *time.Time
+	EndDate    *time.Time
+}
+
+// applyFilter adds the WHERE clause for the filter to the query buffer and
+// the matching named parameters to data.
+func applyFilter(filter QueryFilter, data map[string]interface{}, buf *bytes.Buffer) {
+	var wc []string
+
+	if filter.EntityType != nil {
+		data["entity_type"] = *filter.EntityType
+		wc = append(wc, "entity_type = :entity_type")
+	}
+
+	if filter.EntityID != nil {
+		data["entity_id"] = *filter.EntityID
+		wc = append(wc, "entity_id = :entity_id")
+	}
+
+	if filter.ActorID != nil {
+		data["actor_id"] = *filter.ActorID
+		wc = append(wc, "actor_id = :actor_id")
+	}
+
+	if filter.StartDate != nil {
+		data["start_date"] = filter.StartDate.UTC()
+		wc = append(wc, "date_created >= :start_date")
+	}
+
+	if filter.EndDate != nil {
+		data["end_date"] = filter.EndDate.UTC()
+		wc = append(wc, "date_created <= :end_date")
+	}
+
+	if len(wc) > 0 {
+		buf.WriteString(" WHERE ")
+		buf.WriteString(strings.Join(wc, " AND "))
+	}
+}
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"time"
+)
+
+// Audit represent the structure we need for moving data
+// between the app and the database.
+type Audit struct {
+	ID          string    `db:"audit_id"`
+	ActorID     string    `db:"actor_id"`
+	Action      string    `db:"action"`
+	EntityType  string    `db:"entity_type"`
+	EntityID    string    `db:"entity_id"`
+	Diff        string    `db:"diff"`
+	TraceID     string    `db:"trace_id"`
+	DateCreated time.Time `db:"date_created"`
+}
+
+/*
+CREATE TABLE audit_log (
+	audit_id     UUID,
+	actor_id     TEXT,
+	action       TEXT,
+	entity_type  TEXT,
+	entity_id    TEXT,
+	diff         JSONB,
+	trace_id     TEXT,
+	date_created TIMESTAMP,
+
+	PRIMARY KEY (audit_id)
+);
+*/
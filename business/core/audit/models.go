@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/audit/db"
+)
+
+// Audit represents a change made to an entity. The diff holds the fields that
+// changed, keyed by their JSON name, with the value before and after.
+type Audit struct {
+	ID          string          `json:"id"`
+	ActorID     string          `json:"actor_id"`
+	Action      string          `json:"action"`
+	EntityType  string          `json:"entity_type"`
+	EntityID    string          `json:"entity_id"`
+	Diff        json.RawMessage `json:"diff"`
+	TraceID     string          `json:"trace_id"`
+	DateCreated time.Time       `json:"date_created"`
+}
+
+// QueryFilter holds the available fields a query can be filtered on.
+type QueryFilter struct {
+	EntityType *string `validate:"omitempty,min=1"`
+	EntityID   *string `validate:"omitempty,min=1"`
+	ActorID    *string `validate:"omitempty,min=1"`
+	StartDate  *time.Time
+	EndDate    *time.Time
+}
+
+// change is the value of a field before and after a change. A field that
+// didn't exist on one side is left out.
+type change struct {
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// =============================================================================
+
+func toAudit(dbEntry db.Audit) Audit {
+	return Audit{
+		ID:          dbEntry.ID,
+		ActorID:     dbEntry.ActorID,
+		Action:      dbEntry.Action,
+		EntityType:  dbEntry.EntityType,
+		EntityID:    dbEntry.EntityID,
+		Diff:        json.RawMessage(dbEntry.Diff),
+		TraceID:     dbEntry.TraceID,
+		DateCreated: dbEntry.DateCreated,
+	}
+}
+
+func toAuditSlice(dbEntries []db.Audit) []Audit {
+	entries := make([]Audit, len(dbEntries))
+	for i, dbEntry := range dbEntries {
+		entries[i] = toAudit(dbEntry)
+	}
+	return entries
+}
@@ -0,0 +1,70 @@
+// Package db contains signing key rotation related CRUD functionality.
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for key rotation access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Tran returns a new Store value bound to the specified transaction so
+// several calls can be made as a single unit of work.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log: s.log,
+		db:  tx,
+	}
+}
+
+// Save inserts the key or replaces its state.
+func (s Store) Save(ctx context.Context, key Key) error {
+	const q = `
+	INSERT INTO key_rotations
+		(kid, active, retired_until, date_updated)
+	VALUES
+		(:kid, :active, :retired_until, :date_updated)
+	ON CONFLICT (kid) DO UPDATE SET
+		active = EXCLUDED.active,
+		retired_until = EXCLUDED.retired_until,
+		date_updated = EXCLUDED.date_updated`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, key); err != nil {
+		return fmt.Errorf("saving key kid[%s]: %w", key.KID, err)
+	}
+
+	return nil
+}
+
+// Query retrieves every key that was activated, whether it is still active
+// or was retired.
+func (s Store) Query(ctx context.Context) ([]Key, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		key_rotations`
+
+	var keys []Key
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, struct{}{}, &keys); err != nil {
+		return nil, fmt.Errorf("selecting keys: %w", err)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,23 @@
+package db
+
+import "time"
+
+// Key represent the structure we need for moving data
+// between the app and the database.
+type Key struct {
+	KID          string     `db:"kid"`
+	Active       bool       `db:"active"`
+	RetiredUntil *time.Time `db:"retired_until"`
+	DateUpdated  time.Time  `db:"date_updated"`
+}
+
+/*
+CREATE TABLE key_rotations (
+	kid           TEXT,
+	active        BOOLEAN,
+	retired_until TIMESTAMP NULL,
+	date_updated  TIMESTAMP,
+
+	PRIMARY KEY (kid)
+);
+*/
@@ -0,0 +1,138 @@
+// Package keyrotation provides support for sharing the rotation of the
+// signing keys between the instances of the service. The key files are
+// deployed with every instance, while the key that signs new tokens and the
+// grace periods of the retired keys are kept in the database.
+package keyrotation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/keyrotation/db"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/foundation/tracer"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidKID occurs when no key id is provided.
+var ErrInvalidKID = errors.New("kid is not valid")
+
+// Core manages the set of APIs for key rotation access.
+type Core struct {
+	log   *zap.SugaredLogger
+	db    *sqlx.DB
+	store db.Store
+}
+
+// NewCore constructs a core for key rotation api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Core {
+	return Core{
+		log:   log,
+		db:    sqlxDB,
+		store: db.NewStore(log, sqlxDB),
+	}
+}
+
+// Query retrieves the current rotation of the keys. The active kid is empty
+// until a key is activated. Retired keys are kept after their grace period,
+// so they keep failing to verify tokens.
+func (c Core) Query(ctx context.Context) (auth.Rotation, error) {
+	ctx, span := tracer.Start(ctx, "keyrotation.Query")
+	defer span.End()
+
+	dbKeys, err := c.store.Query(ctx)
+	if err != nil {
+		return auth.Rotation{}, fmt.Errorf("query: %w", err)
+	}
+
+	return toRotation(dbKeys), nil
+}
+
+// Activate makes the key sign new tokens. The key active until now is retired
+// and keeps verifying tokens for the grace period. The current kid is the key
+// retired when no key was activated before, as configured on the instance.
+func (c Core) Activate(ctx context.Context, kid string, current string, grace time.Duration, now time.Time) (auth.Rotation, error) {
+	ctx, span := tracer.Start(ctx, "keyrotation.Activate")
+	defer span.End()
+
+	if kid == "" {
+		return auth.Rotation{}, ErrInvalidKID
+	}
+
+	var rot auth.Rotation
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		dbKeys, err := store.Query(ctx)
+		if err != nil {
+			return fmt.Errorf("query: %w", err)
+		}
+
+		retiring := current
+		if active := toRotation(dbKeys).ActiveKID; active != "" {
+			retiring = active
+		}
+
+		if retiring != kid {
+			until := now.Add(grace)
+			retired := db.Key{
+				KID:          retiring,
+				RetiredUntil: &until,
+				DateUpdated:  now,
+			}
+			if err := store.Save(ctx, retired); err != nil {
+				return fmt.Errorf("retiring key: %w", err)
+			}
+		}
+
+		active := db.Key{
+			KID:         kid,
+			Active:      true,
+			DateUpdated: now,
+		}
+		if err := store.Save(ctx, active); err != nil {
+			return fmt.Errorf("activating key: %w", err)
+		}
+
+		dbKeys, err = store.Query(ctx)
+		if err != nil {
+			return fmt.Errorf("query: %w", err)
+		}
+		rot = toRotation(dbKeys)
+
+		return nil
+	}
+
+	// Two keys activated at the same time would both retire the key active
+	// before, so the activations are serialized.
+	opts := sql.TxOptions{Isolation: sql.LevelSerializable}
+	if err := database.WithinTranOptions(ctx, c.log, c.db, &opts, tran); err != nil {
+		return auth.Rotation{}, err
+	}
+
+	return rot, nil
+}
+
+// =============================================================================
+
+func toRotation(dbKeys []db.Key) auth.Rotation {
+	rot := auth.Rotation{
+		Retired: make(map[string]time.Time),
+	}
+
+	for _, dbKey := range dbKeys {
+		switch {
+		case dbKey.Active:
+			rot.ActiveKID = dbKey.KID
+		case dbKey.RetiredUntil != nil:
+			rot.Retired[dbKey.KID] = *dbKey.RetiredUntil
+		}
+	}
+
+	return rot
+}
@@ -0,0 +1,71 @@
+package keyrotation_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/keyrotation"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/foundation/docker"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func TestActivate(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testkeyrotation")
+	t.Cleanup(teardown)
+
+	core := keyrotation.NewCore(log, db)
+
+	t.Log("Given the need to share the rotation of the signing keys.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen activating keys.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			rot, err := core.Query(ctx)
+			if err != nil || rot.ActiveKID != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould have no active key before one is activated : %+v %v.", dbtest.Failed, testID, rot, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have no active key before one is activated.", dbtest.Success, testID)
+
+			if _, err := core.Activate(ctx, "new", "old", time.Hour, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to activate a key : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to activate a key.", dbtest.Success, testID)
+
+			rot, err = core.Query(ctx)
+			if err != nil || rot.ActiveKID != "new" || !rot.Retired["old"].Equal(now.Add(time.Hour)) {
+				t.Fatalf("\t%s\tTest %d:\tShould retire the configured key for the grace period : %+v %v.", dbtest.Failed, testID, rot, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould retire the configured key for the grace period.", dbtest.Success, testID)
+
+			rot, err = core.Activate(ctx, "next", "old", 0, now.Add(time.Minute))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to activate another key : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to activate another key.", dbtest.Success, testID)
+
+			if rot.ActiveKID != "next" || !rot.Retired["new"].Equal(now.Add(time.Minute)) || len(rot.Retired) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould retire the key active in the database : %+v.", dbtest.Failed, testID, rot)
+			}
+			t.Logf("\t%s\tTest %d:\tShould retire the key active in the database.", dbtest.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package keyrotation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+	"go.uber.org/zap"
+)
+
+// Sync keeps the rotation of the keys used by Auth in line with the database,
+// so a key activated through any instance of the service is picked up by all
+// of them within the refresh interval.
+type Sync struct {
+	log      *zap.SugaredLogger
+	core     Core
+	auth     *auth.Auth
+	shutdown chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewSync constructs a sync of the rotation used by the Auth.
+func NewSync(log *zap.SugaredLogger, core Core, a *auth.Auth) *Sync {
+	return &Sync{
+		log:      log,
+		core:     core,
+		auth:     a,
+		shutdown: make(chan struct{}),
+	}
+}
+
+// Start loads the rotation and starts refreshing it at the specified
+// interval until Shutdown is called.
+func (s *Sync) Start(interval time.Duration) {
+	s.refresh()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.refresh()
+			case <-s.shutdown:
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stops refreshing the rotation.
+func (s *Sync) Shutdown() {
+	close(s.shutdown)
+	s.wg.Wait()
+}
+
+// Refresh replaces the rotation used by the Auth with the one in the
+// database. Until a key is activated the configured active key is kept.
+func (s *Sync) Refresh(ctx context.Context) error {
+	rot, err := s.core.Query(ctx)
+	if err != nil {
+		return err
+	}
+
+	if rot.ActiveKID == "" {
+		return nil
+	}
+
+	return s.auth.SetRotation(rot)
+}
+
+// =============================================================================
+
+func (s *Sync) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.Refresh(ctx); err != nil {
+		s.log.Errorw("keys", "status", "refreshing rotation", "ERROR", err)
+	}
+}
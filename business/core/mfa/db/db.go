@@ -0,0 +1,285 @@
+// Package db contains multi-factor authentication related CRUD functionality.
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for multi-factor authentication access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Tran returns a new Store value bound to the specified transaction so
+// several calls can be made as a single unit of work.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log: s.log,
+		db:  tx,
+	}
+}
+
+// SaveSecret inserts the secret of a user, replacing any secret the user
+// already has.
+func (s Store) SaveSecret(ctx context.Context, sec Secret) error {
+	const q = `
+	INSERT INTO mfa_secrets
+		(user_id, secret, enabled, last_step, date_created, date_updated)
+	VALUES
+		(:user_id, :secret, :enabled, :last_step, :date_created, :date_updated)
+	ON CONFLICT (user_id) DO UPDATE SET
+		"secret" = :secret,
+		"enabled" = :enabled,
+		"last_step" = :last_step,
+		"date_updated" = :date_updated`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, sec); err != nil {
+		return fmt.Errorf("saving secret: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateSecret updates the state of the secret of a user.
+func (s Store) UpdateSecret(ctx context.Context, sec Secret) error {
+	const q = `
+	UPDATE
+		mfa_secrets
+	SET
+		"enabled" = :enabled,
+		"last_step" = :last_step,
+		"date_updated" = :date_updated
+	WHERE
+		user_id = :user_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, sec); err != nil {
+		return fmt.Errorf("updating secret userID[%s]: %w", sec.UserID, err)
+	}
+
+	return nil
+}
+
+// DeleteSecret removes the secret and the recovery codes of a user.
+func (s Store) DeleteSecret(ctx context.Context, userID string) error {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID,
+	}
+
+	const q = `
+	DELETE FROM
+		mfa_secrets
+	WHERE
+		user_id = :user_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting secret userID[%s]: %w", userID, err)
+	}
+
+	return s.DeleteRecoveryCodes(ctx, userID)
+}
+
+// QuerySecret gets the secret of a user from the database.
+func (s Store) QuerySecret(ctx context.Context, userID string) (Secret, error) {
+	return s.querySecret(ctx, userID, "")
+}
+
+// QuerySecretForUpdate gets the secret of a user from the database and locks
+// the row until the enclosing transaction completes.
+func (s Store) QuerySecretForUpdate(ctx context.Context, userID string) (Secret, error) {
+	return s.querySecret(ctx, userID, "FOR UPDATE")
+}
+
+func (s Store) querySecret(ctx context.Context, userID string, lock string) (Secret, error) {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID,
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		mfa_secrets
+	WHERE
+		user_id = :user_id ` + lock
+
+	var sec Secret
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &sec); err != nil {
+		return Secret{}, fmt.Errorf("selecting secret userID[%s]: %w", userID, err)
+	}
+
+	return sec, nil
+}
+
+// =============================================================================
+
+// CreateRecoveryCode inserts a new recovery code into the database.
+func (s Store) CreateRecoveryCode(ctx context.Context, rc RecoveryCode) error {
+	const q = `
+	INSERT INTO mfa_recovery_codes
+		(code_id, user_id, code_hash, date_created)
+	VALUES
+		(:code_id, :user_id, :code_hash, :date_created)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, rc); err != nil {
+		return fmt.Errorf("inserting recovery code: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteRecoveryCodes removes all the recovery codes of a user.
+func (s Store) DeleteRecoveryCodes(ctx context.Context, userID string) error {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID,
+	}
+
+	const q = `
+	DELETE FROM
+		mfa_recovery_codes
+	WHERE
+		user_id = :user_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting recovery codes userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// QueryRecoveryCodeForUpdate gets an unused recovery code of a user from the
+// database and locks the row until the enclosing transaction completes.
+func (s Store) QueryRecoveryCodeForUpdate(ctx context.Context, userID string, codeHash string) (RecoveryCode, error) {
+	data := struct {
+		UserID   string `db:"user_id"`
+		CodeHash string `db:"code_hash"`
+	}{
+		UserID:   userID,
+		CodeHash: codeHash,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		mfa_recovery_codes
+	WHERE
+		user_id = :user_id AND
+		code_hash = :code_hash AND
+		date_used IS NULL
+	FOR UPDATE`
+
+	var rc RecoveryCode
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &rc); err != nil {
+		return RecoveryCode{}, fmt.Errorf("selecting recovery code: %w", err)
+	}
+
+	return rc, nil
+}
+
+// UseRecoveryCode marks a recovery code as used.
+func (s Store) UseRecoveryCode(ctx context.Context, codeID string, now time.Time) error {
+	data := struct {
+		ID       string    `db:"code_id"`
+		DateUsed time.Time `db:"date_used"`
+	}{
+		ID:       codeID,
+		DateUsed: now,
+	}
+
+	const q = `
+	UPDATE
+		mfa_recovery_codes
+	SET
+		"date_used" = :date_used
+	WHERE
+		code_id = :code_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("using recovery code codeID[%s]: %w", codeID, err)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// CreateChallenge inserts a new challenge into the database.
+func (s Store) CreateChallenge(ctx context.Context, ch Challenge) error {
+	const q = `
+	INSERT INTO mfa_challenges
+		(challenge_id, user_id, challenge_hash, attempts, date_created, date_expires)
+	VALUES
+		(:challenge_id, :user_id, :challenge_hash, :attempts, :date_created, :date_expires)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, ch); err != nil {
+		return fmt.Errorf("inserting challenge: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateChallenge updates the attempts and use of a challenge.
+func (s Store) UpdateChallenge(ctx context.Context, ch Challenge) error {
+	const q = `
+	UPDATE
+		mfa_challenges
+	SET
+		"attempts" = :attempts,
+		"date_used" = :date_used
+	WHERE
+		challenge_id = :challenge_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, ch); err != nil {
+		return fmt.Errorf("updating challengeID[%s]: %w", ch.ID, err)
+	}
+
+	return nil
+}
+
+// QueryChallengeByHashForUpdate gets the challenge with the specified hash
+// from the database and locks the row until the enclosing transaction
+// completes.
+func (s Store) QueryChallengeByHashForUpdate(ctx context.Context, challengeHash string) (Challenge, error) {
+	data := struct {
+		ChallengeHash string `db:"challenge_hash"`
+	}{
+		ChallengeHash: challengeHash,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		mfa_challenges
+	WHERE
+		challenge_hash = :challenge_hash
+	FOR UPDATE`
+
+	var ch Challenge
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &ch); err != nil {
+		return Challenge{}, fmt.Errorf("selecting challenge: %w", err)
+	}
+
+	return ch, nil
+}
@@ -0,0 +1,79 @@
+package db
+
+import "time"
+
+// Secret represent the structure we need for moving data
+// between the app and the database.
+type Secret struct {
+	UserID      string    `db:"user_id"`
+	Secret      string    `db:"secret"`
+	Enabled     bool      `db:"enabled"`
+	LastStep    int64     `db:"last_step"`
+	DateCreated time.Time `db:"date_created"`
+	DateUpdated time.Time `db:"date_updated"`
+}
+
+/*
+CREATE TABLE mfa_secrets (
+	user_id      UUID,
+	secret       TEXT,
+	enabled      BOOLEAN,
+	last_step    BIGINT,
+	date_created TIMESTAMP,
+	date_updated TIMESTAMP,
+
+	PRIMARY KEY (user_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+*/
+
+// RecoveryCode represent the structure we need for moving data
+// between the app and the database.
+type RecoveryCode struct {
+	ID          string     `db:"code_id"`
+	UserID      string     `db:"user_id"`
+	CodeHash    string     `db:"code_hash"`
+	DateCreated time.Time  `db:"date_created"`
+	DateUsed    *time.Time `db:"date_used"`
+}
+
+/*
+CREATE TABLE mfa_recovery_codes (
+	code_id      UUID,
+	user_id      UUID,
+	code_hash    TEXT,
+	date_created TIMESTAMP,
+	date_used    TIMESTAMP NULL,
+
+	PRIMARY KEY (code_id),
+	UNIQUE (user_id, code_hash),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+*/
+
+// Challenge represent the structure we need for moving data
+// between the app and the database.
+type Challenge struct {
+	ID            string     `db:"challenge_id"`
+	UserID        string     `db:"user_id"`
+	ChallengeHash string     `db:"challenge_hash"`
+	Attempts      int        `db:"attempts"`
+	DateCreated   time.Time  `db:"date_created"`
+	DateExpires   time.Time  `db:"date_expires"`
+	DateUsed      *time.Time `db:"date_used"`
+}
+
+/*
+CREATE TABLE mfa_challenges (
+	challenge_id   UUID,
+	user_id        UUID,
+	challenge_hash TEXT UNIQUE,
+	attempts       INT,
+	date_created   TIMESTAMP,
+	date_expires   TIMESTAMP,
+	date_used      TIMESTAMP NULL,
+
+	PRIMARY KEY (challenge_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+*/
@@ -0,0 +1,526 @@
+// Package mfa provides support for multi-factor authentication with time-based
+// one-time passwords (RFC 6238). A user enrolls by adding the secret to an
+// authenticator app and confirming a first code. From then on a correct
+// password only earns a short-lived challenge, which is exchanged for a token
+// by answering it with a code from the app or a one-time recovery code.
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/audit"
+	auditdb "github.com/ardanlabs/service/business/core/audit/db"
+	"github.com/ardanlabs/service/business/core/mfa/db"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/foundation/totp"
+	"github.com/ardanlabs/service/foundation/tracer"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of error variables for multi-factor authentication operations.
+var (
+	ErrInvalidID        = errors.New("ID is not in its proper form")
+	ErrInvalidCode      = errors.New("code is not valid")
+	ErrInvalidChallenge = errors.New("challenge is not valid")
+	ErrNotEnrolled      = errors.New("multi-factor authentication is not enrolled")
+	ErrAlreadyEnabled   = errors.New("multi-factor authentication is already enabled")
+)
+
+// Set of values that make up the policy for codes and challenges.
+const (
+	recoveryCodes = 10
+	maxAttempts   = 5
+	skew          = 1
+)
+
+// Config represents the policy for multi-factor authentication. When
+// RequireAdmin is set, users holding the admin role must use it. The secrets
+// of the users are encrypted with SecretKey in the database.
+type Config struct {
+	Issuer       string
+	ChallengeTTL time.Duration
+	RequireAdmin bool
+	SecretKey    []byte
+}
+
+// auditEntity names the multi-factor authentication of users in the audit
+// log, identified by the id of the user.
+const auditEntity = "mfa"
+
+// Core manages the set of APIs for multi-factor authentication access.
+type Core struct {
+	log   *zap.SugaredLogger
+	db    *sqlx.DB
+	store db.Store
+	audit auditdb.Store
+	cfg   Config
+}
+
+// NewCore constructs a core for multi-factor authentication api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB, cfg Config) Core {
+	return Core{
+		log:   log,
+		db:    sqlxDB,
+		store: db.NewStore(log, sqlxDB),
+		audit: auditdb.NewStore(log, sqlxDB),
+		cfg:   cfg,
+	}
+}
+
+// Status reports if the user has enabled multi-factor authentication and if
+// it is required, either because it is enabled or because the user holds one
+// of the roles that must use it.
+func (c Core) Status(ctx context.Context, userID string, roles []string) (Status, error) {
+	ctx, span := tracer.Start(ctx, "mfa.Status")
+	defer span.End()
+
+	if err := validate.CheckID(userID); err != nil {
+		return Status{}, ErrInvalidID
+	}
+
+	var st Status
+
+	dbSec, err := c.store.QuerySecret(ctx, userID)
+	switch {
+	case err == nil:
+		st.Enabled = dbSec.Enabled
+	case !errors.Is(err, database.ErrDBNotFound):
+		return Status{}, fmt.Errorf("query: %w", err)
+	}
+
+	st.Required = st.Enabled || (c.cfg.RequireAdmin && hasRole(roles, auth.RoleAdmin))
+
+	return st, nil
+}
+
+// Enroll generates a new secret and set of recovery codes for the user. The
+// account is the name the authenticator app displays. The secret is not
+// enabled until a code generated with it is provided, so enrolling again
+// before that replaces it.
+func (c Core) Enroll(ctx context.Context, userID string, account string, now time.Time) (Enrollment, error) {
+	ctx, span := tracer.Start(ctx, "mfa.Enroll")
+	defer span.End()
+
+	if err := validate.CheckID(userID); err != nil {
+		return Enrollment{}, ErrInvalidID
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return Enrollment{}, err
+	}
+
+	codes := make([]string, recoveryCodes)
+	for i := range codes {
+		if codes[i], err = newRecoveryCode(); err != nil {
+			return Enrollment{}, err
+		}
+	}
+
+	sealed, err := c.seal(secret)
+	if err != nil {
+		return Enrollment{}, err
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		dbSec, err := store.QuerySecretForUpdate(ctx, userID)
+		switch {
+		case err == nil:
+			if dbSec.Enabled {
+				return ErrAlreadyEnabled
+			}
+		case !errors.Is(err, database.ErrDBNotFound):
+			return fmt.Errorf("enroll: %w", err)
+		}
+
+		dbSec = db.Secret{
+			UserID:      userID,
+			Secret:      sealed,
+			DateCreated: now,
+			DateUpdated: now,
+		}
+		if err := store.SaveSecret(ctx, dbSec); err != nil {
+			return fmt.Errorf("enroll: %w", err)
+		}
+
+		if err := store.DeleteRecoveryCodes(ctx, userID); err != nil {
+			return fmt.Errorf("enroll: %w", err)
+		}
+
+		for _, code := range codes {
+			dbRC := db.RecoveryCode{
+				ID:          validate.GenerateID(),
+				UserID:      userID,
+				CodeHash:    hash(normalize(code)),
+				DateCreated: now,
+			}
+			if err := store.CreateRecoveryCode(ctx, dbRC); err != nil {
+				return fmt.Errorf("enroll: %w", err)
+			}
+		}
+
+		return nil
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return Enrollment{}, err
+	}
+
+	enr := Enrollment{
+		Secret:        secret,
+		URI:           totp.URI(c.cfg.Issuer, account, secret),
+		RecoveryCodes: codes,
+	}
+
+	return enr, nil
+}
+
+// Confirm enables multi-factor authentication for the user once the user
+// proves the authenticator app was set up by providing a code.
+func (c Core) Confirm(ctx context.Context, userID string, code string, now time.Time) error {
+	ctx, span := tracer.Start(ctx, "mfa.Confirm")
+	defer span.End()
+
+	if err := validate.CheckID(userID); err != nil {
+		return ErrInvalidID
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		dbSec, err := store.QuerySecretForUpdate(ctx, userID)
+		if err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				return ErrNotEnrolled
+			}
+			return fmt.Errorf("confirm: %w", err)
+		}
+
+		if dbSec.Enabled {
+			return ErrAlreadyEnabled
+		}
+
+		ok, err := c.verify(ctx, store, &dbSec, code, now)
+		if err != nil {
+			return fmt.Errorf("confirm: %w", err)
+		}
+		if !ok {
+			return ErrInvalidCode
+		}
+
+		dbSec.Enabled = true
+		if err := store.UpdateSecret(ctx, dbSec); err != nil {
+			return fmt.Errorf("confirm: %w", err)
+		}
+
+		if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionUpdate, auditEntity, userID, auditMFA{}, auditMFA{Enabled: true}, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	return database.WithinTran(ctx, c.log, c.db, tran)
+}
+
+// Disable turns off multi-factor authentication for the user, removing the
+// secret and the recovery codes. A valid code is required so a stolen token
+// isn't enough to do it.
+func (c Core) Disable(ctx context.Context, userID string, code string, now time.Time) error {
+	ctx, span := tracer.Start(ctx, "mfa.Disable")
+	defer span.End()
+
+	if err := validate.CheckID(userID); err != nil {
+		return ErrInvalidID
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		dbSec, err := store.QuerySecretForUpdate(ctx, userID)
+		if err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				return ErrNotEnrolled
+			}
+			return fmt.Errorf("disable: %w", err)
+		}
+
+		ok, err := c.verify(ctx, store, &dbSec, code, now)
+		if err != nil {
+			return fmt.Errorf("disable: %w", err)
+		}
+		if !ok {
+			return ErrInvalidCode
+		}
+
+		if err := store.DeleteSecret(ctx, userID); err != nil {
+			return fmt.Errorf("disable: %w", err)
+		}
+
+		if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionDelete, auditEntity, userID, auditMFA{Enabled: dbSec.Enabled}, nil, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	return database.WithinTran(ctx, c.log, c.db, tran)
+}
+
+// CreateChallenge issues a challenge for a user that passed the first
+// authentication factor.
+func (c Core) CreateChallenge(ctx context.Context, userID string, st Status, now time.Time) (Challenge, error) {
+	ctx, span := tracer.Start(ctx, "mfa.CreateChallenge")
+	defer span.End()
+
+	if err := validate.CheckID(userID); err != nil {
+		return Challenge{}, ErrInvalidID
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return Challenge{}, err
+	}
+
+	dbCh := db.Challenge{
+		ID:            validate.GenerateID(),
+		UserID:        userID,
+		ChallengeHash: hash(token),
+		DateCreated:   now,
+		DateExpires:   now.Add(c.cfg.ChallengeTTL),
+	}
+
+	if err := c.store.CreateChallenge(ctx, dbCh); err != nil {
+		return Challenge{}, fmt.Errorf("create: %w", err)
+	}
+
+	ch := Challenge{
+		Token:              token,
+		EnrollmentRequired: !st.Enabled,
+		DateExpires:        dbCh.DateExpires,
+	}
+
+	return ch, nil
+}
+
+// ChallengeUser returns the user an outstanding challenge was issued for.
+// This lets a user that is required to use multi-factor authentication enroll
+// before having a token.
+func (c Core) ChallengeUser(ctx context.Context, challenge string, now time.Time) (string, error) {
+	ctx, span := tracer.Start(ctx, "mfa.ChallengeUser")
+	defer span.End()
+
+	var userID string
+
+	tran := func(tx sqlx.ExtContext) error {
+		dbCh, err := c.challenge(ctx, c.store.Tran(tx), challenge, now)
+		if err != nil {
+			return err
+		}
+
+		userID = dbCh.UserID
+		return nil
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
+
+// Answer verifies the answer to a challenge and returns the user the
+// challenge was issued for. A challenge can only be answered once and only
+// a few wrong answers are allowed. If the user enrolled using the challenge,
+// a correct answer confirms the enrollment.
+func (c Core) Answer(ctx context.Context, ans Answer, now time.Time) (string, error) {
+	ctx, span := tracer.Start(ctx, "mfa.Answer")
+	defer span.End()
+
+	if err := validate.Check(ans); err != nil {
+		return "", fmt.Errorf("validating data: %w", err)
+	}
+
+	var userID string
+	var failed bool
+
+	tran := func(tx sqlx.ExtContext) error {
+		failed = false
+		store := c.store.Tran(tx)
+
+		dbCh, err := c.challenge(ctx, store, ans.Challenge, now)
+		if err != nil {
+			return err
+		}
+
+		dbSec, err := store.QuerySecretForUpdate(ctx, dbCh.UserID)
+		if err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				return ErrNotEnrolled
+			}
+			return fmt.Errorf("answer: %w", err)
+		}
+
+		ok, err := c.verify(ctx, store, &dbSec, ans.Code, now)
+		if err != nil {
+			return fmt.Errorf("answer: %w", err)
+		}
+
+		if !ok {
+			dbCh.Attempts++
+			if err := store.UpdateChallenge(ctx, dbCh); err != nil {
+				return fmt.Errorf("answer: %w", err)
+			}
+
+			// Commit the attempt and report the failure once the
+			// transaction is done.
+			failed = true
+			return nil
+		}
+
+		dbSec.Enabled = true
+		if err := store.UpdateSecret(ctx, dbSec); err != nil {
+			return fmt.Errorf("answer: %w", err)
+		}
+
+		dbCh.DateUsed = &now
+		if err := store.UpdateChallenge(ctx, dbCh); err != nil {
+			return fmt.Errorf("answer: %w", err)
+		}
+
+		userID = dbCh.UserID
+		return nil
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return "", err
+	}
+
+	if failed {
+		return "", ErrInvalidCode
+	}
+
+	return userID, nil
+}
+
+// =============================================================================
+
+// hasRole reports if the role is among the roles.
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// challenge retrieves an outstanding challenge and locks it until the
+// enclosing transaction completes.
+func (c Core) challenge(ctx context.Context, store db.Store, challenge string, now time.Time) (db.Challenge, error) {
+	dbCh, err := store.QueryChallengeByHashForUpdate(ctx, hash(challenge))
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return db.Challenge{}, ErrInvalidChallenge
+		}
+		return db.Challenge{}, fmt.Errorf("challenge: %w", err)
+	}
+
+	if dbCh.DateUsed != nil || dbCh.Attempts >= maxAttempts || !now.Before(dbCh.DateExpires) {
+		return db.Challenge{}, ErrInvalidChallenge
+	}
+
+	return dbCh, nil
+}
+
+// verify checks the code against the secret of the user, recording its use.
+// Codes from the app can't be used twice and recovery codes are only
+// accepted once multi-factor authentication is enabled.
+func (c Core) verify(ctx context.Context, store db.Store, dbSec *db.Secret, code string, now time.Time) (bool, error) {
+	secret, err := c.open(dbSec.Secret)
+	if err != nil {
+		return false, err
+	}
+
+	if step, ok := totp.Validate(secret, code, now, skew); ok {
+		if step <= dbSec.LastStep {
+			return false, nil
+		}
+
+		dbSec.LastStep = step
+		dbSec.DateUpdated = now
+		if err := store.UpdateSecret(ctx, *dbSec); err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	if !dbSec.Enabled {
+		return false, nil
+	}
+
+	dbRC, err := store.QueryRecoveryCodeForUpdate(ctx, dbSec.UserID, hash(normalize(code)))
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if err := store.UseRecoveryCode(ctx, dbRC.ID, now); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// newToken generates a random opaque challenge token.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// newRecoveryCode generates a random recovery code formatted in two groups
+// of five characters so it is easy to write down.
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating recovery code: %w", err)
+	}
+
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// normalize removes the formatting of a recovery code as typed by a user.
+func normalize(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// hash returns the hash of a token or code that is stored in the database.
+// Both have enough entropy that a fast hash is sufficient.
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,201 @@
+package mfa_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/mfa"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/foundation/docker"
+	"github.com/ardanlabs/service/foundation/totp"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func TestMFA(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testmfa")
+	t.Cleanup(teardown)
+
+	core := mfa.NewCore(log, db, mfa.Config{
+		Issuer:       "Sales API",
+		ChallengeTTL: 5 * time.Minute,
+		RequireAdmin: true,
+	})
+
+	t.Log("Given the need to work with multi-factor authentication.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen an admin enrolls through a challenge.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			const userID = "5cf37266-3473-4006-984f-9325122678b7"
+			roles := []string{auth.RoleAdmin, auth.RoleUser}
+
+			st, err := core.Status(ctx, userID, roles)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to get the status : %s.", dbtest.Failed, testID, err)
+			}
+
+			if !st.Required || st.Enabled {
+				t.Fatalf("\t%s\tTest %d:\tShould be required for admins before enrolling : %+v.", dbtest.Failed, testID, st)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be required for admins before enrolling.", dbtest.Success, testID)
+
+			ch, err := core.CreateChallenge(ctx, userID, st, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a challenge : %s.", dbtest.Failed, testID, err)
+			}
+
+			if !ch.EnrollmentRequired {
+				t.Fatalf("\t%s\tTest %d:\tShould require enrollment in the challenge.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould require enrollment in the challenge.", dbtest.Success, testID)
+
+			gotUserID, err := core.ChallengeUser(ctx, ch.Token, now)
+			if err != nil || gotUserID != userID {
+				t.Fatalf("\t%s\tTest %d:\tShould find the user of the challenge : %v.", dbtest.Failed, testID, err)
+			}
+
+			enr, err := core.Enroll(ctx, userID, "admin@example.com", now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to enroll : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to enroll.", dbtest.Success, testID)
+
+			if _, err := core.Answer(ctx, mfa.Answer{Challenge: ch.Token, Code: enr.RecoveryCodes[0]}, now); !errors.Is(err, mfa.ErrInvalidCode) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept recovery codes before confirming : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept recovery codes before confirming.", dbtest.Success, testID)
+
+			code, err := totp.Code(enr.Secret, totp.Step(now))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a code : %s.", dbtest.Failed, testID, err)
+			}
+
+			gotUserID, err = core.Answer(ctx, mfa.Answer{Challenge: ch.Token, Code: code}, now)
+			if err != nil || gotUserID != userID {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to answer the challenge : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to answer the challenge.", dbtest.Success, testID)
+
+			if _, err := core.Answer(ctx, mfa.Answer{Challenge: ch.Token, Code: code}, now); !errors.Is(err, mfa.ErrInvalidChallenge) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to answer a challenge twice : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to answer a challenge twice.", dbtest.Success, testID)
+
+			st, err = core.Status(ctx, userID, roles)
+			if err != nil || !st.Enabled {
+				t.Fatalf("\t%s\tTest %d:\tShould be enabled after answering : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be enabled after answering.", dbtest.Success, testID)
+
+			ch, err = core.CreateChallenge(ctx, userID, st, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a challenge : %s.", dbtest.Failed, testID, err)
+			}
+
+			if _, err := core.Answer(ctx, mfa.Answer{Challenge: ch.Token, Code: code}, now); !errors.Is(err, mfa.ErrInvalidCode) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept a code twice : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept a code twice.", dbtest.Success, testID)
+
+			if _, err := core.Answer(ctx, mfa.Answer{Challenge: ch.Token, Code: enr.RecoveryCodes[0]}, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept a recovery code : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept a recovery code.", dbtest.Success, testID)
+
+			if _, err := core.Enroll(ctx, userID, "admin@example.com", now); !errors.Is(err, mfa.ErrAlreadyEnabled) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to enroll again while enabled : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to enroll again while enabled.", dbtest.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a user doesn't use multi-factor authentication.", testID)
+		{
+			ctx := context.Background()
+
+			const userID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+			st, err := core.Status(ctx, userID, []string{auth.RoleUser})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to get the status : %s.", dbtest.Failed, testID, err)
+			}
+
+			if st.Required {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be required.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be required.", dbtest.Success, testID)
+		}
+	}
+}
+
+func TestSecretNotLogged(t *testing.T) {
+	_, db, teardown := dbtest.NewUnit(t, c, "testmfasecret")
+	t.Cleanup(teardown)
+
+	// Capture the logged queries.
+	var buf bytes.Buffer
+	encoder := zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+	log := zap.New(zapcore.NewCore(encoder, zapcore.AddSync(&buf), zapcore.DebugLevel)).Sugar()
+
+	core := mfa.NewCore(log, db, mfa.Config{
+		Issuer:       "Sales API",
+		ChallengeTTL: 5 * time.Minute,
+		SecretKey:    []byte("test-key"),
+	})
+
+	t.Log("Given the need to keep the secrets of users out of the logs.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a user enrolls and confirms a code.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			const userID = "5cf37266-3473-4006-984f-9325122678b7"
+
+			enr, err := core.Enroll(ctx, userID, "admin@example.com", now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to enroll : %s.", dbtest.Failed, testID, err)
+			}
+
+			code, err := totp.Code(enr.Secret, totp.Step(now))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a code : %s.", dbtest.Failed, testID, err)
+			}
+
+			if err := core.Confirm(ctx, userID, code, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to confirm with the sealed secret : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to confirm with the sealed secret.", dbtest.Success, testID)
+
+			if strings.Contains(buf.String(), enr.Secret) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT log the secret.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT log the secret.", dbtest.Success, testID)
+		}
+	}
+}
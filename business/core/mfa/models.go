@@ -0,0 +1,42 @@
+package mfa
+
+import (
+	"time"
+)
+
+// Status describes the multi-factor authentication state of a user.
+type Status struct {
+	Enabled  bool `json:"enabled"`
+	Required bool `json:"required"`
+}
+
+// Enrollment contains what a user needs to set up an authenticator app. The
+// recovery codes can each be used once instead of a code from the app. None
+// of it can be retrieved again.
+type Enrollment struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// Challenge is handed out when a user passes the first authentication factor
+// and must prove the second one to get a token. When enrollment is required
+// the user must enroll using the challenge before answering it.
+type Challenge struct {
+	Token              string    `json:"mfa_challenge"`
+	EnrollmentRequired bool      `json:"enrollment_required"`
+	DateExpires        time.Time `json:"expires_at"`
+}
+
+// Answer contains a response to a challenge. The code is either a code from
+// the authenticator app or one of the recovery codes.
+type Answer struct {
+	Challenge string `json:"mfa_challenge" validate:"required"`
+	Code      string `json:"code" validate:"required"`
+}
+
+// auditMFA is the multi-factor authentication of a user as recorded in the
+// audit log. The secret and the recovery codes are never recorded.
+type auditMFA struct {
+	Enabled bool `json:"enabled"`
+}
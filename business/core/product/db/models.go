@@ -0,0 +1,32 @@
+package db
+
+import "time"
+
+// Product represent the structure we need for moving data
+// between the app and the database.
+type Product struct {
+	ID          string    `db:"product_id"`
+	Name        string    `db:"name"`
+	Cost        int       `db:"cost"`
+	Quantity    int       `db:"quantity"`
+	Sold        int       `db:"sold"`
+	Revenue     int       `db:"revenue"`
+	UserID      string    `db:"user_id"`
+	DateCreated time.Time `db:"date_created"`
+	DateUpdated time.Time `db:"date_updated"`
+}
+
+/*
+CREATE TABLE products (
+	product_id   UUID,
+	name         TEXT,
+	cost         INT,
+	quantity     INT,
+	user_id      UUID,
+	date_created TIMESTAMP,
+	date_updated TIMESTAMP,
+
+	PRIMARY KEY (product_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+*/
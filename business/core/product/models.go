@@ -0,0 +1,56 @@
+package product
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/ardanlabs/service/business/core/product/db"
+)
+
+// Product represents an individual product.
+type Product struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Cost        int       `json:"cost"`
+	Quantity    int       `json:"quantity"`
+	Sold        int       `json:"sold"`
+	Revenue     int       `json:"revenue"`
+	UserID      string    `json:"user_id"`
+	DateCreated time.Time `json:"date_created"`
+	DateUpdated time.Time `json:"date_updated"`
+}
+
+// NewProduct is what we require from clients when adding a Product.
+type NewProduct struct {
+	Name     string `json:"name" validate:"required"`
+	Cost     int    `json:"cost" validate:"required,gte=0"`
+	Quantity int    `json:"quantity" validate:"gte=1"`
+	UserID   string `json:"user_id" validate:"required"`
+}
+
+// UpdateProduct defines what information may be provided to modify an
+// existing Product. All fields are optional so clients can send just the
+// fields they want changed. It uses pointer fields so we can differentiate
+// between a field that was not provided and a field that was provided as
+// explicitly blank. Normally we do not want to use pointers to basic types but
+// we make exceptions around marshalling/unmarshalling.
+type UpdateProduct struct {
+	Name     *string `json:"name"`
+	Cost     *int    `json:"cost" validate:"omitempty,gte=0"`
+	Quantity *int    `json:"quantity" validate:"omitempty,gte=1"`
+}
+
+// =============================================================================
+
+func toProduct(dbPrd db.Product) Product {
+	pp := (*Product)(unsafe.Pointer(&dbPrd))
+	return *pp
+}
+
+func toProductSlice(dbPrds []db.Product) []Product {
+	prds := make([]Product, len(dbPrds))
+	for i, dbPrd := range dbPrds {
+		prds[i] = toProduct(dbPrd)
+	}
+	return prds
+}
@@ -0,0 +1,221 @@
+// Package product provides an example of a core business API. Every change to
+// a product is recorded in the audit log within the same transaction.
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/audit"
+	auditdb "github.com/ardanlabs/service/business/core/audit/db"
+	"github.com/ardanlabs/service/business/core/product/db"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/foundation/tracer"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of error variables for CRUD operations.
+var (
+	ErrNotFound  = errors.New("product not found")
+	ErrInvalidID = errors.New("ID is not in its proper form")
+)
+
+// Core manages the set of APIs for product access.
+type Core struct {
+	log   *zap.SugaredLogger
+	db    *sqlx.DB
+	store db.Store
+	audit auditdb.Store
+}
+
+// auditEntity names products in the audit log.
+const auditEntity = "product"
+
+// NewCore constructs a core for product api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Core {
+	return Core{
+		log:   log,
+		db:    sqlxDB,
+		store: db.NewStore(log, sqlxDB),
+		audit: auditdb.NewStore(log, sqlxDB),
+	}
+}
+
+// Create inserts a new product into the database.
+func (c Core) Create(ctx context.Context, np NewProduct, now time.Time) (Product, error) {
+	ctx, span := tracer.Start(ctx, "product.Create")
+	defer span.End()
+
+	if err := validate.Check(np); err != nil {
+		return Product{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	if err := validate.CheckID(np.UserID); err != nil {
+		return Product{}, ErrInvalidID
+	}
+
+	dbPrd := db.Product{
+		ID:          validate.GenerateID(),
+		Name:        np.Name,
+		Cost:        np.Cost,
+		Quantity:    np.Quantity,
+		UserID:      np.UserID,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		if err := c.store.Tran(tx).Create(ctx, dbPrd); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+
+		if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionCreate, auditEntity, dbPrd.ID, nil, toProduct(dbPrd), now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return Product{}, err
+	}
+
+	return toProduct(dbPrd), nil
+}
+
+// Update replaces a product document in the database.
+func (c Core) Update(ctx context.Context, productID string, up UpdateProduct, now time.Time) error {
+	ctx, span := tracer.Start(ctx, "product.Update")
+	defer span.End()
+
+	if err := validate.CheckID(productID); err != nil {
+		return ErrInvalidID
+	}
+
+	if err := validate.Check(up); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	dbPrd, err := c.store.QueryByID(ctx, productID)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("updating product productID[%s]: %w", productID, err)
+	}
+	before := toProduct(dbPrd)
+
+	if up.Name != nil {
+		dbPrd.Name = *up.Name
+	}
+	if up.Cost != nil {
+		dbPrd.Cost = *up.Cost
+	}
+	if up.Quantity != nil {
+		dbPrd.Quantity = *up.Quantity
+	}
+	dbPrd.DateUpdated = now
+
+	tran := func(tx sqlx.ExtContext) error {
+		if err := c.store.Tran(tx).Update(ctx, dbPrd); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+
+		if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionUpdate, auditEntity, productID, before, toProduct(dbPrd), now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	return database.WithinTran(ctx, c.log, c.db, tran)
+}
+
+// Delete removes a product from the database.
+func (c Core) Delete(ctx context.Context, productID string, now time.Time) error {
+	ctx, span := tracer.Start(ctx, "product.Delete")
+	defer span.End()
+
+	if err := validate.CheckID(productID); err != nil {
+		return ErrInvalidID
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		// Deleting a product that doesn't exist changes nothing to audit.
+		dbPrd, err := store.QueryByID(ctx, productID)
+		if err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				return nil
+			}
+			return fmt.Errorf("deleting product productID[%s]: %w", productID, err)
+		}
+
+		if err := store.Delete(ctx, productID); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+
+		if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionDelete, auditEntity, productID, toProduct(dbPrd), nil, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	return database.WithinTran(ctx, c.log, c.db, tran)
+}
+
+// Query retrieves a list of existing products from the database.
+func (c Core) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]Product, error) {
+	ctx, span := tracer.Start(ctx, "product.Query")
+	defer span.End()
+
+	dbPrds, err := c.store.Query(ctx, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toProductSlice(dbPrds), nil
+}
+
+// QueryByID gets the specified product from the database.
+func (c Core) QueryByID(ctx context.Context, productID string) (Product, error) {
+	ctx, span := tracer.Start(ctx, "product.QueryByID")
+	defer span.End()
+
+	if err := validate.CheckID(productID); err != nil {
+		return Product{}, ErrInvalidID
+	}
+
+	dbPrd, err := c.store.QueryByID(ctx, productID)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return Product{}, ErrNotFound
+		}
+		return Product{}, fmt.Errorf("query: %w", err)
+	}
+
+	return toProduct(dbPrd), nil
+}
+
+// QueryByUserID gets the specified products owned by a user from the database.
+func (c Core) QueryByUserID(ctx context.Context, userID string) ([]Product, error) {
+	ctx, span := tracer.Start(ctx, "product.QueryByUserID")
+	defer span.End()
+
+	if err := validate.CheckID(userID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	dbPrds, err := c.store.QueryByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toProductSlice(dbPrds), nil
+}
@@ -0,0 +1,142 @@
+package product_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/product"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/foundation/docker"
+	"github.com/google/go-cmp/cmp"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func TestProduct(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testproduct")
+	t.Cleanup(teardown)
+
+	core := product.NewCore(log, db)
+
+	t.Log("Given the need to work with Product records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single Product.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			np := product.NewProduct{
+				Name:     "Comic Books",
+				Cost:     10,
+				Quantity: 55,
+				UserID:   "5cf37266-3473-4006-984f-9325122678b7",
+			}
+
+			prd, err := core.Create(ctx, np, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a product.", dbtest.Success, testID)
+
+			saved, err := core.QueryByID(ctx, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID: %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve product by ID.", dbtest.Success, testID)
+
+			if diff := cmp.Diff(prd, saved); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same product. Diff:\n%s", dbtest.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same product.", dbtest.Success, testID)
+
+			upd := product.UpdateProduct{
+				Name:     dbtest.StringPointer("Comics"),
+				Cost:     dbtest.IntPointer(50),
+				Quantity: dbtest.IntPointer(40),
+			}
+			updatedTime := time.Date(2019, time.January, 1, 1, 1, 1, 0, time.UTC)
+
+			if err := core.Update(ctx, prd.ID, upd, updatedTime); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to update product.", dbtest.Success, testID)
+
+			products, err := core.QueryByUserID(ctx, np.UserID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve products by user : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve products by user.", dbtest.Success, testID)
+
+			// Check specified fields were updated. Make a copy of the original product
+			// and change just the fields we expect then diff it with what was saved.
+			want := prd
+			want.Name = *upd.Name
+			want.Cost = *upd.Cost
+			want.Quantity = *upd.Quantity
+			want.DateUpdated = updatedTime
+
+			var found bool
+			for _, p := range products {
+				if p.ID != prd.ID {
+					continue
+				}
+				found = true
+				if diff := cmp.Diff(want, p); diff != "" {
+					t.Fatalf("\t%s\tTest %d:\tShould get back the same product. Diff:\n%s", dbtest.Failed, testID, diff)
+				}
+			}
+			if !found {
+				t.Fatalf("\t%s\tTest %d:\tShould find the product in the user's products.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same product.", dbtest.Success, testID)
+
+			upd = product.UpdateProduct{
+				Name: dbtest.StringPointer("Graphic Novels"),
+			}
+
+			if err := core.Update(ctx, prd.ID, upd, updatedTime); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update just some fields of product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to update just some fields of product.", dbtest.Success, testID)
+
+			saved, err = core.QueryByID(ctx, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve updated product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve updated product.", dbtest.Success, testID)
+
+			if saved.Name != *upd.Name {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to see updated Name field : got %q want %q.", dbtest.Failed, testID, saved.Name, *upd.Name)
+			} else {
+				t.Logf("\t%s\tTest %d:\tShould be able to see updated Name field.", dbtest.Success, testID)
+			}
+
+			if err := core.Delete(ctx, prd.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete product.", dbtest.Success, testID)
+
+			_, err = core.QueryByID(ctx, prd.ID)
+			if !errors.Is(err, product.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve deleted product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve deleted product.", dbtest.Success, testID)
+		}
+	}
+}
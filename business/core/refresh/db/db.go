@@ -0,0 +1,157 @@
+// Package db contains refresh token related CRUD functionality.
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for refresh token access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Tran returns a new Store value bound to the specified transaction so
+// several calls can be made as a single unit of work.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log: s.log,
+		db:  tx,
+	}
+}
+
+// Create inserts a new refresh token into the database.
+func (s Store) Create(ctx context.Context, rt RefreshToken) error {
+	const q = `
+	INSERT INTO refresh_tokens
+		(token_id, family_id, user_id, token_hash, date_created, date_expires)
+	VALUES
+		(:token_id, :family_id, :user_id, :token_hash, :date_created, :date_expires)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, rt); err != nil {
+		return fmt.Errorf("inserting refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// Revoke marks the refresh token as revoked, recording the token that
+// replaced it when it was rotated.
+func (s Store) Revoke(ctx context.Context, tokenID string, replacedBy *string, now time.Time) error {
+	data := struct {
+		TokenID     string    `db:"token_id"`
+		ReplacedBy  *string   `db:"replaced_by"`
+		DateRevoked time.Time `db:"date_revoked"`
+	}{
+		TokenID:     tokenID,
+		ReplacedBy:  replacedBy,
+		DateRevoked: now,
+	}
+
+	const q = `
+	UPDATE
+		refresh_tokens
+	SET
+		"date_revoked" = :date_revoked,
+		"replaced_by" = :replaced_by
+	WHERE
+		token_id = :token_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("revoking tokenID[%s]: %w", tokenID, err)
+	}
+
+	return nil
+}
+
+// RevokeFamily marks every outstanding refresh token of a family as revoked.
+func (s Store) RevokeFamily(ctx context.Context, familyID string, now time.Time) error {
+	data := struct {
+		FamilyID    string    `db:"family_id"`
+		DateRevoked time.Time `db:"date_revoked"`
+	}{
+		FamilyID:    familyID,
+		DateRevoked: now,
+	}
+
+	const q = `
+	UPDATE
+		refresh_tokens
+	SET
+		"date_revoked" = :date_revoked
+	WHERE
+		family_id = :family_id AND
+		date_revoked IS NULL`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("revoking familyID[%s]: %w", familyID, err)
+	}
+
+	return nil
+}
+
+// RevokeUser marks every outstanding refresh token of a user as revoked.
+func (s Store) RevokeUser(ctx context.Context, userID string, now time.Time) error {
+	data := struct {
+		UserID      string    `db:"user_id"`
+		DateRevoked time.Time `db:"date_revoked"`
+	}{
+		UserID:      userID,
+		DateRevoked: now,
+	}
+
+	const q = `
+	UPDATE
+		refresh_tokens
+	SET
+		"date_revoked" = :date_revoked
+	WHERE
+		user_id = :user_id AND
+		date_revoked IS NULL`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("revoking userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// QueryByHashForUpdate gets the refresh token with the specified hash from
+// the database and locks the row until the enclosing transaction completes.
+func (s Store) QueryByHashForUpdate(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	data := struct {
+		TokenHash string `db:"token_hash"`
+	}{
+		TokenHash: tokenHash,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		refresh_tokens
+	WHERE
+		token_hash = :token_hash
+	FOR UPDATE`
+
+	var rt RefreshToken
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &rt); err != nil {
+		return RefreshToken{}, fmt.Errorf("selecting refresh token: %w", err)
+	}
+
+	return rt, nil
+}
@@ -0,0 +1,32 @@
+package db
+
+import "time"
+
+// RefreshToken represent the structure we need for moving data
+// between the app and the database.
+type RefreshToken struct {
+	ID          string     `db:"token_id"`
+	FamilyID    string     `db:"family_id"`
+	UserID      string     `db:"user_id"`
+	TokenHash   string     `db:"token_hash"`
+	DateCreated time.Time  `db:"date_created"`
+	DateExpires time.Time  `db:"date_expires"`
+	DateRevoked *time.Time `db:"date_revoked"`
+	ReplacedBy  *string    `db:"replaced_by"`
+}
+
+/*
+CREATE TABLE refresh_tokens (
+	token_id     UUID,
+	family_id    UUID,
+	user_id      UUID,
+	token_hash   TEXT UNIQUE,
+	date_created TIMESTAMP,
+	date_expires TIMESTAMP,
+	date_revoked TIMESTAMP NULL,
+	replaced_by  UUID NULL,
+
+	PRIMARY KEY (token_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+*/
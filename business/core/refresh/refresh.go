@@ -0,0 +1,193 @@
+// Package refresh provides support for long-lived opaque refresh tokens. Only
+// a hash of each token is stored. Every use of a refresh token rotates it and
+// presenting a token that was already rotated revokes the whole family of
+// tokens descending from the same login.
+package refresh
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/refresh/db"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/foundation/tracer"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of error variables for refresh token operations.
+var (
+	ErrInvalidToken = errors.New("refresh token is not valid")
+	ErrTokenReused  = errors.New("refresh token was already used")
+	ErrInvalidID    = errors.New("ID is not in its proper form")
+)
+
+// Core manages the set of APIs for refresh token access.
+type Core struct {
+	log   *zap.SugaredLogger
+	db    *sqlx.DB
+	store db.Store
+	ttl   time.Duration
+}
+
+// NewCore constructs a core for refresh token api access. Issued tokens are
+// valid for the specified ttl.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB, ttl time.Duration) Core {
+	return Core{
+		log:   log,
+		db:    sqlxDB,
+		store: db.NewStore(log, sqlxDB),
+		ttl:   ttl,
+	}
+}
+
+// Create issues a refresh token for the specified user, starting a new family
+// of tokens. The returned token is only known to the caller.
+func (c Core) Create(ctx context.Context, userID string, now time.Time) (string, error) {
+	ctx, span := tracer.Start(ctx, "refresh.Create")
+	defer span.End()
+
+	if err := validate.CheckID(userID); err != nil {
+		return "", ErrInvalidID
+	}
+
+	token, dbRT, err := c.newToken(validate.GenerateID(), userID, now)
+	if err != nil {
+		return "", err
+	}
+
+	if err := c.store.Create(ctx, dbRT); err != nil {
+		return "", fmt.Errorf("create: %w", err)
+	}
+
+	return token, nil
+}
+
+// Rotate exchanges a refresh token for a new one in the same family and
+// returns the user the token belongs to. If the token was already rotated
+// or revoked the whole family is revoked and ErrTokenReused is returned,
+// since the token has most likely been stolen.
+func (c Core) Rotate(ctx context.Context, token string, now time.Time) (string, string, error) {
+	ctx, span := tracer.Start(ctx, "refresh.Rotate")
+	defer span.End()
+
+	var userID string
+	var newToken string
+	var reused bool
+
+	tran := func(tx sqlx.ExtContext) error {
+		reused = false
+
+		dbRT, err := c.store.Tran(tx).QueryByHashForUpdate(ctx, hashToken(token))
+		if err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				return ErrInvalidToken
+			}
+			return fmt.Errorf("rotate: %w", err)
+		}
+
+		if dbRT.DateRevoked != nil {
+			if err := c.store.Tran(tx).RevokeFamily(ctx, dbRT.FamilyID, now); err != nil {
+				return fmt.Errorf("rotate: %w", err)
+			}
+
+			// Commit the revocation of the family and report the reuse
+			// once the transaction is done.
+			reused = true
+			return nil
+		}
+
+		if !now.Before(dbRT.DateExpires) {
+			return ErrInvalidToken
+		}
+
+		tkn, newRT, err := c.newToken(dbRT.FamilyID, dbRT.UserID, now)
+		if err != nil {
+			return err
+		}
+
+		if err := c.store.Tran(tx).Revoke(ctx, dbRT.ID, &newRT.ID, now); err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+
+		if err := c.store.Tran(tx).Create(ctx, newRT); err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+
+		userID = dbRT.UserID
+		newToken = tkn
+		return nil
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return "", "", err
+	}
+
+	if reused {
+		return "", "", ErrTokenReused
+	}
+
+	return userID, newToken, nil
+}
+
+// Revoke revokes the specified refresh token along with every other token
+// in its family. This logs out the session the token was issued for.
+func (c Core) Revoke(ctx context.Context, token string, now time.Time) error {
+	ctx, span := tracer.Start(ctx, "refresh.Revoke")
+	defer span.End()
+
+	tran := func(tx sqlx.ExtContext) error {
+		dbRT, err := c.store.Tran(tx).QueryByHashForUpdate(ctx, hashToken(token))
+		if err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				return ErrInvalidToken
+			}
+			return fmt.Errorf("revoke: %w", err)
+		}
+
+		if err := c.store.Tran(tx).RevokeFamily(ctx, dbRT.FamilyID, now); err != nil {
+			return fmt.Errorf("revoke: %w", err)
+		}
+
+		return nil
+	}
+
+	return database.WithinTran(ctx, c.log, c.db, tran)
+}
+
+// =============================================================================
+
+// newToken generates a random opaque token and the database record that
+// represents it.
+func (c Core) newToken(familyID string, userID string, now time.Time) (string, db.RefreshToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", db.RefreshToken{}, fmt.Errorf("generating token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	dbRT := db.RefreshToken{
+		ID:          validate.GenerateID(),
+		FamilyID:    familyID,
+		UserID:      userID,
+		TokenHash:   hashToken(token),
+		DateCreated: now,
+		DateExpires: now.Add(c.ttl),
+	}
+
+	return token, dbRT, nil
+}
+
+// hashToken returns the hash of the token that is stored in the database.
+// The tokens have enough entropy that a fast hash is sufficient.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,107 @@
+package refresh_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/refresh"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/foundation/docker"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func TestRefresh(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testrefresh")
+	t.Cleanup(teardown)
+
+	core := refresh.NewCore(log, db, time.Hour)
+
+	t.Log("Given the need to work with refresh tokens.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen rotating a refresh token.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			const userID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+			first, err := core.Create(ctx, userID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a refresh token : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a refresh token.", dbtest.Success, testID)
+
+			gotUserID, second, err := core.Rotate(ctx, first, now.Add(time.Minute))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to rotate the refresh token : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to rotate the refresh token.", dbtest.Success, testID)
+
+			if gotUserID != userID || second == first {
+				t.Fatalf("\t%s\tTest %d:\tShould get a new token for the same user : user[%s].", dbtest.Failed, testID, gotUserID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get a new token for the same user.", dbtest.Success, testID)
+
+			if _, _, err := core.Rotate(ctx, first, now.Add(2*time.Minute)); !errors.Is(err, refresh.ErrTokenReused) {
+				t.Fatalf("\t%s\tTest %d:\tShould detect the reuse of a rotated token : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould detect the reuse of a rotated token.", dbtest.Success, testID)
+
+			if _, _, err := core.Rotate(ctx, second, now.Add(3*time.Minute)); !errors.Is(err, refresh.ErrTokenReused) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to use any token in a revoked family : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to use any token in a revoked family.", dbtest.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen revoking and expiring refresh tokens.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			const userID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+			tkn, err := core.Create(ctx, userID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a refresh token : %s.", dbtest.Failed, testID, err)
+			}
+
+			if _, _, err := core.Rotate(ctx, tkn, now.Add(2*time.Hour)); !errors.Is(err, refresh.ErrInvalidToken) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to rotate an expired token : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to rotate an expired token.", dbtest.Success, testID)
+
+			tkn, err = core.Create(ctx, userID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a refresh token : %s.", dbtest.Failed, testID, err)
+			}
+
+			if err := core.Revoke(ctx, tkn, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke a token : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to revoke a token.", dbtest.Success, testID)
+
+			if _, _, err := core.Rotate(ctx, tkn, now); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to rotate a revoked token.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to rotate a revoked token.", dbtest.Success, testID)
+		}
+	}
+}
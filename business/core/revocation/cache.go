@@ -0,0 +1,151 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+	"go.uber.org/zap"
+)
+
+// Cache keeps an in-process copy of the active revocations so checking a
+// token on every request does not require a database call. The copy is
+// refreshed periodically, so revocations made by other instances of the
+// service are picked up within the refresh interval.
+type Cache struct {
+	log      *zap.SugaredLogger
+	core     Core
+	mu       sync.RWMutex
+	tokens   map[string]struct{}
+	users    map[string]time.Time
+	shutdown chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewCache constructs an empty cache of revocations.
+func NewCache(log *zap.SugaredLogger, core Core) *Cache {
+	return &Cache{
+		log:      log,
+		core:     core,
+		tokens:   make(map[string]struct{}),
+		users:    make(map[string]time.Time),
+		shutdown: make(chan struct{}),
+	}
+}
+
+// Start loads the active revocations and starts refreshing them at the
+// specified interval until Shutdown is called. Expired revocations are
+// deleted from the database on every refresh.
+func (c *Cache) Start(interval time.Duration) {
+	c.refresh()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.refresh()
+			case <-c.shutdown:
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stops refreshing the cache.
+func (c *Cache) Shutdown() {
+	close(c.shutdown)
+	c.wg.Wait()
+}
+
+// Refresh replaces the cached revocations with the active revocations in
+// the database.
+func (c *Cache) Refresh(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	revs, err := c.core.QueryActive(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	tokens := make(map[string]struct{})
+	users := make(map[string]time.Time)
+	for _, rev := range revs {
+		add(tokens, users, rev)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tokens = tokens
+	c.users = users
+
+	return nil
+}
+
+// Add adds a revocation to the cache without waiting for the next refresh.
+func (c *Cache) Add(rev Revocation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	add(c.tokens, c.users, rev)
+}
+
+// Revoked implements the auth.Revoker interface. A token is revoked if its
+// jti was revoked or if it was issued to a user before all of the user's
+// tokens were revoked. Tokens are issued with times precise to the
+// microsecond, so only a token issued within the same microsecond as the
+// revocation is revoked along with the tokens issued before it.
+func (c *Cache) Revoked(claims auth.Claims) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if _, exists := c.tokens[claims.ID]; exists && claims.ID != "" {
+		return true
+	}
+
+	revokedAt, exists := c.users[claims.Subject]
+	if !exists {
+		return false
+	}
+
+	if claims.IssuedAt == nil {
+		return true
+	}
+
+	return !claims.IssuedAt.Time.After(revokedAt)
+}
+
+// =============================================================================
+
+func (c *Cache) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := c.Refresh(ctx); err != nil {
+		c.log.Errorw("revocations", "status", "refreshing cache", "ERROR", err)
+		return
+	}
+
+	if err := c.core.DeleteExpired(ctx, time.Now().UTC()); err != nil {
+		c.log.Errorw("revocations", "status", "deleting expired", "ERROR", err)
+	}
+}
+
+func add(tokens map[string]struct{}, users map[string]time.Time, rev Revocation) {
+	if rev.TokenID != nil {
+		tokens[*rev.TokenID] = struct{}{}
+	}
+
+	if rev.UserID != nil {
+		if revokedAt, exists := users[*rev.UserID]; !exists || rev.DateRevoked.After(revokedAt) {
+			users[*rev.UserID] = rev.DateRevoked
+		}
+	}
+}
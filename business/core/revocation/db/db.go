@@ -0,0 +1,96 @@
+// Package db contains token revocation related CRUD functionality.
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for revocation access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Tran returns a new Store value bound to the specified transaction so
+// several calls can be made as a single unit of work.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log: s.log,
+		db:  tx,
+	}
+}
+
+// Create inserts a new revocation into the database.
+func (s Store) Create(ctx context.Context, rev Revocation) error {
+	const q = `
+	INSERT INTO revocations
+		(revocation_id, token_id, user_id, date_revoked, date_expires)
+	VALUES
+		(:revocation_id, :token_id, :user_id, :date_revoked, :date_expires)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, rev); err != nil {
+		return fmt.Errorf("inserting revocation: %w", err)
+	}
+
+	return nil
+}
+
+// QueryActive retrieves the revocations that have not expired yet.
+func (s Store) QueryActive(ctx context.Context, now time.Time) ([]Revocation, error) {
+	data := struct {
+		Now time.Time `db:"now"`
+	}{
+		Now: now,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		revocations
+	WHERE
+		date_expires > :now`
+
+	var revs []Revocation
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, data, &revs); err != nil {
+		return nil, fmt.Errorf("selecting revocations: %w", err)
+	}
+
+	return revs, nil
+}
+
+// DeleteExpired removes the revocations that expired. The tokens they
+// revoked are no longer valid anyway.
+func (s Store) DeleteExpired(ctx context.Context, now time.Time) error {
+	data := struct {
+		Now time.Time `db:"now"`
+	}{
+		Now: now,
+	}
+
+	const q = `
+	DELETE FROM
+		revocations
+	WHERE
+		date_expires <= :now`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting expired revocations: %w", err)
+	}
+
+	return nil
+}
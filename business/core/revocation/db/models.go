@@ -0,0 +1,25 @@
+package db
+
+import "time"
+
+// Revocation represent the structure we need for moving data
+// between the app and the database.
+type Revocation struct {
+	ID          string    `db:"revocation_id"`
+	TokenID     *string   `db:"token_id"`
+	UserID      *string   `db:"user_id"`
+	DateRevoked time.Time `db:"date_revoked"`
+	DateExpires time.Time `db:"date_expires"`
+}
+
+/*
+CREATE TABLE revocations (
+	revocation_id UUID,
+	token_id      TEXT NULL,
+	user_id       UUID NULL,
+	date_revoked  TIMESTAMP,
+	date_expires  TIMESTAMP,
+
+	PRIMARY KEY (revocation_id)
+);
+*/
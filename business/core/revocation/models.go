@@ -0,0 +1,40 @@
+package revocation
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/ardanlabs/service/business/core/revocation/db"
+)
+
+// Revocation represents the revocation of a single token, identified by its
+// jti, or of all the tokens issued to a user up to the time of revocation.
+type Revocation struct {
+	ID          string    `json:"id"`
+	TokenID     *string   `json:"token_id,omitempty"`
+	UserID      *string   `json:"user_id,omitempty"`
+	DateRevoked time.Time `json:"date_revoked"`
+	DateExpires time.Time `json:"date_expires"`
+}
+
+// NewRevocation contains the information needed to revoke tokens. Exactly one
+// of the fields must be provided.
+type NewRevocation struct {
+	TokenID *string `json:"token_id" validate:"required_without=UserID,excluded_with=UserID"`
+	UserID  *string `json:"user_id" validate:"required_without=TokenID,excluded_with=TokenID"`
+}
+
+// =============================================================================
+
+func toRevocation(dbRev db.Revocation) Revocation {
+	pr := (*Revocation)(unsafe.Pointer(&dbRev))
+	return *pr
+}
+
+func toRevocationSlice(dbRevs []db.Revocation) []Revocation {
+	revs := make([]Revocation, len(dbRevs))
+	for i, dbRev := range dbRevs {
+		revs[i] = toRevocation(dbRev)
+	}
+	return revs
+}
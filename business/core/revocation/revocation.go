@@ -0,0 +1,113 @@
+// Package revocation provides support for revoking tokens before they expire.
+// A single token can be revoked by its jti, or all the tokens issued to a user
+// up to a point in time can be revoked at once. A revocation only needs to be
+// kept until the tokens it covers would have expired anyway.
+package revocation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/revocation/db"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/foundation/tracer"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidID occurs when an ID is not in a valid form.
+var ErrInvalidID = errors.New("ID is not in its proper form")
+
+// Core manages the set of APIs for revocation access.
+type Core struct {
+	store db.Store
+}
+
+// NewCore constructs a core for revocation api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Core {
+	return Core{
+		store: db.NewStore(log, sqlxDB),
+	}
+}
+
+// Create revokes either the token or all the tokens of the user specified.
+func (c Core) Create(ctx context.Context, nr NewRevocation, now time.Time) (Revocation, error) {
+	ctx, span := tracer.Start(ctx, "revocation.Create")
+	defer span.End()
+
+	if err := validate.Check(nr); err != nil {
+		return Revocation{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	if nr.UserID != nil {
+		if err := validate.CheckID(*nr.UserID); err != nil {
+			return Revocation{}, ErrInvalidID
+		}
+		return c.create(ctx, NewUserRevocation(*nr.UserID, now))
+	}
+
+	if err := validate.CheckID(*nr.TokenID); err != nil {
+		return Revocation{}, ErrInvalidID
+	}
+	return c.create(ctx, newTokenRevocation(*nr.TokenID, now))
+}
+
+// QueryActive retrieves the revocations that still cover valid tokens.
+func (c Core) QueryActive(ctx context.Context, now time.Time) ([]Revocation, error) {
+	ctx, span := tracer.Start(ctx, "revocation.QueryActive")
+	defer span.End()
+
+	dbRevs, err := c.store.QueryActive(ctx, now)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toRevocationSlice(dbRevs), nil
+}
+
+// DeleteExpired removes the revocations that no longer cover valid tokens.
+func (c Core) DeleteExpired(ctx context.Context, now time.Time) error {
+	ctx, span := tracer.Start(ctx, "revocation.DeleteExpired")
+	defer span.End()
+
+	if err := c.store.DeleteExpired(ctx, now); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	return nil
+}
+
+func (c Core) create(ctx context.Context, dbRev db.Revocation) (Revocation, error) {
+	if err := c.store.Create(ctx, dbRev); err != nil {
+		return Revocation{}, fmt.Errorf("create: %w", err)
+	}
+
+	return toRevocation(dbRev), nil
+}
+
+// =============================================================================
+
+// NewUserRevocation constructs the record revoking every token issued to the
+// user up to now. It is exported so other cores can revoke a user's tokens
+// inside their own transactions.
+func NewUserRevocation(userID string, now time.Time) db.Revocation {
+	return db.Revocation{
+		ID:          validate.GenerateID(),
+		UserID:      &userID,
+		DateRevoked: now,
+		DateExpires: now.Add(auth.TokenLifetime),
+	}
+}
+
+// newTokenRevocation constructs the record revoking a single token.
+func newTokenRevocation(tokenID string, now time.Time) db.Revocation {
+	return db.Revocation{
+		ID:          validate.GenerateID(),
+		TokenID:     &tokenID,
+		DateRevoked: now,
+		DateExpires: now.Add(auth.TokenLifetime),
+	}
+}
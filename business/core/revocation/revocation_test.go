@@ -0,0 +1,115 @@
+package revocation_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/revocation"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/foundation/docker"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func TestRevocation(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testrevocation")
+	t.Cleanup(teardown)
+
+	core := revocation.NewCore(log, db)
+
+	t.Log("Given the need to revoke tokens.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen revoking a token and the tokens of a user.", testID)
+		{
+			ctx := context.Background()
+			now := time.Now().UTC()
+
+			const userID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+			const tokenID = "5cf37266-3473-4006-984f-9325122678b7"
+
+			claims := func(subject string, id string, issuedAt time.Time) auth.Claims {
+				return auth.Claims{
+					RegisteredClaims: jwt.RegisteredClaims{
+						ID:       id,
+						Subject:  subject,
+						IssuedAt: jwt.NewNumericDate(issuedAt),
+					},
+				}
+			}
+
+			uid := userID
+			if _, err := core.Create(ctx, revocation.NewRevocation{UserID: &uid}, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke the tokens of a user : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to revoke the tokens of a user.", dbtest.Success, testID)
+
+			tid := tokenID
+			if _, err := core.Create(ctx, revocation.NewRevocation{TokenID: &tid}, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke a token : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to revoke a token.", dbtest.Success, testID)
+
+			if _, err := core.Create(ctx, revocation.NewRevocation{UserID: &uid, TokenID: &tid}, now); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to revoke a token and a user at once.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to revoke a token and a user at once.", dbtest.Success, testID)
+
+			cache := revocation.NewCache(log, core)
+			if err := cache.Refresh(ctx); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to load the revocations : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to load the revocations.", dbtest.Success, testID)
+
+			const otherUserID = "5cf37266-3473-4006-984f-9325122678b7"
+
+			tests := []struct {
+				name    string
+				claims  auth.Claims
+				revoked bool
+			}{
+				{"the revoked token", claims(otherUserID, tokenID, now), true},
+				{"a token issued before the user was revoked", claims(userID, "", now.Add(-time.Minute)), true},
+				{"a token issued after the user was revoked", claims(userID, "", now.Add(time.Minute)), false},
+				{"a token issued within a second after the user was revoked", claims(userID, "", now.Add(time.Millisecond)), false},
+				{"a token of another user", claims(otherUserID, "", now.Add(-time.Minute)), false},
+			}
+			for _, tt := range tests {
+				if got := cache.Revoked(tt.claims); got != tt.revoked {
+					t.Fatalf("\t%s\tTest %d:\tShould get revoked[%v] for %s : got %v.", dbtest.Failed, testID, tt.revoked, tt.name, got)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get revoked[%v] for %s.", dbtest.Success, testID, tt.revoked, tt.name)
+			}
+
+			if err := core.DeleteExpired(ctx, now.Add(auth.TokenLifetime)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete expired revocations : %s.", dbtest.Failed, testID, err)
+			}
+
+			revs, err := core.QueryActive(ctx, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query the revocations : %s.", dbtest.Failed, testID, err)
+			}
+
+			if len(revs) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould not keep revocations once the tokens expire : got %d.", dbtest.Failed, testID, len(revs))
+			}
+			t.Logf("\t%s\tTest %d:\tShould not keep revocations once the tokens expire.", dbtest.Success, testID)
+		}
+	}
+}
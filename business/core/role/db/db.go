@@ -0,0 +1,130 @@
+// Package db contains role related CRUD functionality.
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for role access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Tran returns a new Store value bound to the specified transaction so
+// several calls can be made as a single unit of work.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log: s.log,
+		db:  tx,
+	}
+}
+
+// Create inserts a new role into the database.
+func (s Store) Create(ctx context.Context, role Role) error {
+	const q = `
+	INSERT INTO roles
+		(role, permissions, date_created, date_updated)
+	VALUES
+		(:role, :permissions, :date_created, :date_updated)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, role); err != nil {
+		return fmt.Errorf("inserting role: %w", err)
+	}
+
+	return nil
+}
+
+// Update replaces the permissions of a role in the database.
+func (s Store) Update(ctx context.Context, role Role) error {
+	const q = `
+	UPDATE
+		roles
+	SET
+		"permissions" = :permissions,
+		"date_updated" = :date_updated
+	WHERE
+		role = :role`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, role); err != nil {
+		return fmt.Errorf("updating role[%s]: %w", role.Name, err)
+	}
+
+	return nil
+}
+
+// Delete removes a role from the database.
+func (s Store) Delete(ctx context.Context, name string) error {
+	data := struct {
+		Name string `db:"role"`
+	}{
+		Name: name,
+	}
+
+	const q = `
+	DELETE FROM
+		roles
+	WHERE
+		role = :role`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting role[%s]: %w", name, err)
+	}
+
+	return nil
+}
+
+// Query retrieves all the roles from the database.
+func (s Store) Query(ctx context.Context) ([]Role, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		roles
+	ORDER BY
+		role`
+
+	var roles []Role
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, struct{}{}, &roles); err != nil {
+		return nil, fmt.Errorf("selecting roles: %w", err)
+	}
+
+	return roles, nil
+}
+
+// QueryByName gets the specified role from the database.
+func (s Store) QueryByName(ctx context.Context, name string) (Role, error) {
+	data := struct {
+		Name string `db:"role"`
+	}{
+		Name: name,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		roles
+	WHERE
+		role = :role`
+
+	var role Role
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &role); err != nil {
+		return Role{}, fmt.Errorf("selecting role[%q]: %w", name, err)
+	}
+
+	return role, nil
+}
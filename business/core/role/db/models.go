@@ -0,0 +1,27 @@
+package db
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Role represent the structure we need for moving data
+// between the app and the database.
+type Role struct {
+	Name        string         `db:"role"`
+	Permissions pq.StringArray `db:"permissions"`
+	DateCreated time.Time      `db:"date_created"`
+	DateUpdated time.Time      `db:"date_updated"`
+}
+
+/*
+CREATE TABLE roles (
+	role         TEXT,
+	permissions  TEXT[],
+	date_created TIMESTAMP,
+	date_updated TIMESTAMP,
+
+	PRIMARY KEY (role)
+);
+*/
@@ -0,0 +1,43 @@
+package role
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/ardanlabs/service/business/core/role/db"
+)
+
+// Role represents a role users hold and the permissions it grants.
+type Role struct {
+	Name        string    `json:"name"`
+	Permissions []string  `json:"permissions"`
+	DateCreated time.Time `json:"date_created"`
+	DateUpdated time.Time `json:"date_updated"`
+}
+
+// NewRole contains information needed to create a new Role.
+type NewRole struct {
+	Name        string   `json:"name" validate:"required,uppercase"`
+	Permissions []string `json:"permissions" validate:"required"`
+}
+
+// UpdateRole defines the permissions a role grants after the update. The
+// permissions replace the ones the role granted before.
+type UpdateRole struct {
+	Permissions []string `json:"permissions" validate:"required"`
+}
+
+// =============================================================================
+
+func toRole(dbRole db.Role) Role {
+	pr := (*Role)(unsafe.Pointer(&dbRole))
+	return *pr
+}
+
+func toRoleSlice(dbRoles []db.Role) []Role {
+	roles := make([]Role, len(dbRoles))
+	for i, dbRole := range dbRoles {
+		roles[i] = toRole(dbRole)
+	}
+	return roles
+}
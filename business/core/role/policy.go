@@ -0,0 +1,116 @@
+package role
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Policy keeps an in-process copy of the permissions granted by each role so
+// authorizing a request does not require a database call. The copy is
+// refreshed periodically, so roles edited through other instances of the
+// service are picked up within the refresh interval.
+type Policy struct {
+	log      *zap.SugaredLogger
+	core     Core
+	mu       sync.RWMutex
+	roles    map[string]map[string]struct{}
+	shutdown chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewPolicy constructs a policy that grants nothing until it is refreshed.
+func NewPolicy(log *zap.SugaredLogger, core Core) *Policy {
+	return &Policy{
+		log:      log,
+		core:     core,
+		roles:    make(map[string]map[string]struct{}),
+		shutdown: make(chan struct{}),
+	}
+}
+
+// Start loads the roles and starts refreshing them at the specified interval
+// until Shutdown is called.
+func (p *Policy) Start(interval time.Duration) {
+	p.refresh()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.refresh()
+			case <-p.shutdown:
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stops refreshing the policy.
+func (p *Policy) Shutdown() {
+	close(p.shutdown)
+	p.wg.Wait()
+}
+
+// Refresh replaces the cached roles with the roles in the database.
+func (p *Policy) Refresh(ctx context.Context) error {
+	roles, err := p.core.Query(ctx)
+	if err != nil {
+		return err
+	}
+
+	p.Set(roles)
+
+	return nil
+}
+
+// Set replaces the cached roles with the specified roles.
+func (p *Policy) Set(roles []Role) {
+	m := make(map[string]map[string]struct{}, len(roles))
+	for _, role := range roles {
+		perms := make(map[string]struct{}, len(role.Permissions))
+		for _, perm := range role.Permissions {
+			perms[perm] = struct{}{}
+		}
+		m[role.Name] = perms
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.roles = m
+}
+
+// Allowed implements the auth.Policy interface. The permission is granted
+// if any of the roles grants it.
+func (p *Policy) Allowed(roles []string, permission string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, role := range roles {
+		if _, exists := p.roles[role][permission]; exists {
+			return true
+		}
+	}
+
+	return false
+}
+
+// =============================================================================
+
+func (p *Policy) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := p.Refresh(ctx); err != nil {
+		p.log.Errorw("roles", "status", "refreshing policy", "ERROR", err)
+	}
+}
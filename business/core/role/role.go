@@ -0,0 +1,225 @@
+// Package role provides support for the roles users hold and the permissions
+// each role grants. The ADMIN and USER roles are built in and can't be
+// deleted, and ADMIN always keeps the permission to edit roles so the
+// service can't be locked out of its own policy. Every change to a role is
+// recorded in the audit log within the same transaction.
+package role
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/audit"
+	auditdb "github.com/ardanlabs/service/business/core/audit/db"
+	"github.com/ardanlabs/service/business/core/role/db"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/foundation/tracer"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of error variables for role operations.
+var (
+	ErrNotFound          = errors.New("role not found")
+	ErrExists            = errors.New("role already exists")
+	ErrBuiltIn           = errors.New("built-in roles can't be deleted")
+	ErrUnknownPermission = errors.New("permission is not known")
+	ErrAdminLockout      = errors.New("the ADMIN role must keep the roles:write permission")
+)
+
+// Core manages the set of APIs for role access.
+type Core struct {
+	log   *zap.SugaredLogger
+	db    *sqlx.DB
+	store db.Store
+	audit auditdb.Store
+}
+
+// auditEntity names roles in the audit log.
+const auditEntity = "role"
+
+// NewCore constructs a core for role api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Core {
+	return Core{
+		log:   log,
+		db:    sqlxDB,
+		store: db.NewStore(log, sqlxDB),
+		audit: auditdb.NewStore(log, sqlxDB),
+	}
+}
+
+// Create inserts a new role into the database.
+func (c Core) Create(ctx context.Context, nr NewRole, now time.Time) (Role, error) {
+	ctx, span := tracer.Start(ctx, "role.Create")
+	defer span.End()
+
+	if err := validate.Check(nr); err != nil {
+		return Role{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	if err := checkPermissions(nr.Name, nr.Permissions); err != nil {
+		return Role{}, err
+	}
+
+	if _, err := c.store.QueryByName(ctx, nr.Name); err == nil {
+		return Role{}, ErrExists
+	}
+
+	dbRole := db.Role{
+		Name:        nr.Name,
+		Permissions: nr.Permissions,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		if err := c.store.Tran(tx).Create(ctx, dbRole); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+
+		if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionCreate, auditEntity, dbRole.Name, nil, toRole(dbRole), now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return Role{}, err
+	}
+
+	return toRole(dbRole), nil
+}
+
+// Update replaces the permissions granted by the role.
+func (c Core) Update(ctx context.Context, name string, ur UpdateRole, now time.Time) (Role, error) {
+	ctx, span := tracer.Start(ctx, "role.Update")
+	defer span.End()
+
+	if err := validate.Check(ur); err != nil {
+		return Role{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	if err := checkPermissions(name, ur.Permissions); err != nil {
+		return Role{}, err
+	}
+
+	dbRole, err := c.store.QueryByName(ctx, name)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return Role{}, ErrNotFound
+		}
+		return Role{}, fmt.Errorf("updating role[%s]: %w", name, err)
+	}
+	before := toRole(dbRole)
+
+	dbRole.Permissions = ur.Permissions
+	dbRole.DateUpdated = now
+
+	tran := func(tx sqlx.ExtContext) error {
+		if err := c.store.Tran(tx).Update(ctx, dbRole); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+
+		if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionUpdate, auditEntity, name, before, toRole(dbRole), now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return Role{}, err
+	}
+
+	return toRole(dbRole), nil
+}
+
+// Delete removes a role. Users holding the role keep it in their list of
+// roles, but it no longer grants any permissions.
+func (c Core) Delete(ctx context.Context, name string, now time.Time) error {
+	ctx, span := tracer.Start(ctx, "role.Delete")
+	defer span.End()
+
+	if name == auth.RoleAdmin || name == auth.RoleUser {
+		return ErrBuiltIn
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		dbRole, err := store.QueryByName(ctx, name)
+		if err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("deleting role[%s]: %w", name, err)
+		}
+
+		if err := store.Delete(ctx, name); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+
+		if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionDelete, auditEntity, name, toRole(dbRole), nil, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	return database.WithinTran(ctx, c.log, c.db, tran)
+}
+
+// Query retrieves all the roles.
+func (c Core) Query(ctx context.Context) ([]Role, error) {
+	ctx, span := tracer.Start(ctx, "role.Query")
+	defer span.End()
+
+	dbRoles, err := c.store.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toRoleSlice(dbRoles), nil
+}
+
+// QueryByName gets the specified role.
+func (c Core) QueryByName(ctx context.Context, name string) (Role, error) {
+	ctx, span := tracer.Start(ctx, "role.QueryByName")
+	defer span.End()
+
+	dbRole, err := c.store.QueryByName(ctx, name)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return Role{}, ErrNotFound
+		}
+		return Role{}, fmt.Errorf("query: %w", err)
+	}
+
+	return toRole(dbRole), nil
+}
+
+// =============================================================================
+
+// checkPermissions validates the permissions a role is about to grant.
+func checkPermissions(name string, permissions []string) error {
+	var rolesWrite bool
+	for _, p := range permissions {
+		if !auth.ValidPermission(p) {
+			return fmt.Errorf("permission[%s]: %w", p, ErrUnknownPermission)
+		}
+		if p == auth.PermRolesWrite {
+			rolesWrite = true
+		}
+	}
+
+	if name == auth.RoleAdmin && !rolesWrite {
+		return ErrAdminLockout
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package role_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/role"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/foundation/docker"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func TestRole(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testrole")
+	t.Cleanup(teardown)
+
+	core := role.NewCore(log, db)
+	policy := role.NewPolicy(log, core)
+
+	t.Log("Given the need to work with roles and permissions.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling the default and a custom role.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			if err := policy.Refresh(ctx); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to load the policy : %s.", dbtest.Failed, testID, err)
+			}
+
+			if !policy.Allowed([]string{auth.RoleAdmin}, auth.PermUsersWrite) || policy.Allowed([]string{auth.RoleUser}, auth.PermUsersWrite) {
+				t.Fatalf("\t%s\tTest %d:\tShould grant the default permissions.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould grant the default permissions.", dbtest.Success, testID)
+
+			nr := role.NewRole{
+				Name:        "AUDITOR",
+				Permissions: []string{auth.PermUsersRead, "users:everything"},
+			}
+			if _, err := core.Create(ctx, nr, now); !errors.Is(err, role.ErrUnknownPermission) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT grant unknown permissions : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT grant unknown permissions.", dbtest.Success, testID)
+
+			nr.Permissions = []string{auth.PermUsersRead}
+			if _, err := core.Create(ctx, nr, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a role : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a role.", dbtest.Success, testID)
+
+			if _, err := core.Create(ctx, nr, now); !errors.Is(err, role.ErrExists) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to create a role twice : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to create a role twice.", dbtest.Success, testID)
+
+			ur := role.UpdateRole{
+				Permissions: []string{auth.PermUsersRead, auth.PermSalesRead},
+			}
+			if _, err := core.Update(ctx, "AUDITOR", ur, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update a role : %s.", dbtest.Failed, testID, err)
+			}
+
+			if err := policy.Refresh(ctx); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to load the policy : %s.", dbtest.Failed, testID, err)
+			}
+
+			roles := []string{auth.RoleUser, "AUDITOR"}
+			if !policy.Allowed(roles, auth.PermSalesRead) || !policy.Allowed(roles, auth.PermProductsWrite) || policy.Allowed(roles, auth.PermUsersWrite) {
+				t.Fatalf("\t%s\tTest %d:\tShould grant the permissions of every role held.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould grant the permissions of every role held.", dbtest.Success, testID)
+
+			ur = role.UpdateRole{
+				Permissions: []string{auth.PermUsersRead},
+			}
+			if _, err := core.Update(ctx, auth.RoleAdmin, ur, now); !errors.Is(err, role.ErrAdminLockout) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to take roles:write from ADMIN : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to take roles:write from ADMIN.", dbtest.Success, testID)
+
+			if err := core.Delete(ctx, auth.RoleUser, now); !errors.Is(err, role.ErrBuiltIn) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to delete a built-in role : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to delete a built-in role.", dbtest.Success, testID)
+
+			if err := core.Delete(ctx, "AUDITOR", now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete a role : %s.", dbtest.Failed, testID, err)
+			}
+
+			if _, err := core.QueryByName(ctx, "AUDITOR"); !errors.Is(err, role.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve a deleted role : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete a role.", dbtest.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Package db contains sale related CRUD functionality.
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for sale access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Tran returns a new Store value bound to the specified transaction so
+// several calls can be made as a single unit of work.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log: s.log,
+		db:  tx,
+	}
+}
+
+// Create inserts a new sale into the database.
+func (s Store) Create(ctx context.Context, sale Sale) error {
+	const q = `
+	INSERT INTO sales
+		(sale_id, user_id, product_id, quantity, paid, date_created)
+	VALUES
+		(:sale_id, :user_id, :product_id, :quantity, :paid, :date_created)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, sale); err != nil {
+		return fmt.Errorf("inserting sale: %w", err)
+	}
+
+	return nil
+}
+
+// QueryByProductID gets the sales recorded for the specified product from
+// the database.
+func (s Store) QueryByProductID(ctx context.Context, productID string) ([]Sale, error) {
+	data := struct {
+		ProductID string `db:"product_id"`
+	}{
+		ProductID: productID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		sales
+	WHERE
+		product_id = :product_id
+	ORDER BY
+		date_created`
+
+	var sales []Sale
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, data, &sales); err != nil {
+		return nil, fmt.Errorf("selecting sales productID[%s]: %w", productID, err)
+	}
+
+	return sales, nil
+}
@@ -0,0 +1,29 @@
+package db
+
+import "time"
+
+// Sale represent the structure we need for moving data
+// between the app and the database.
+type Sale struct {
+	ID          string    `db:"sale_id"`
+	UserID      string    `db:"user_id"`
+	ProductID   string    `db:"product_id"`
+	Quantity    int       `db:"quantity"`
+	Paid        int       `db:"paid"`
+	DateCreated time.Time `db:"date_created"`
+}
+
+/*
+CREATE TABLE sales (
+	sale_id      UUID,
+	user_id      UUID,
+	product_id   UUID,
+	quantity     INT,
+	paid         INT,
+	date_created TIMESTAMP,
+
+	PRIMARY KEY (sale_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+*/
@@ -0,0 +1,41 @@
+package sale
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/ardanlabs/service/business/core/sale/db"
+)
+
+// Sale represents a sale of some quantity of a product to a user.
+type Sale struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	ProductID   string    `json:"product_id"`
+	Quantity    int       `json:"quantity"`
+	Paid        int       `json:"paid"`
+	DateCreated time.Time `json:"date_created"`
+}
+
+// NewSale is what we require from clients when recording a Sale. The amount
+// paid is calculated from the cost of the product at the time of the sale.
+type NewSale struct {
+	UserID    string `json:"user_id" validate:"required"`
+	ProductID string `json:"product_id" validate:"required"`
+	Quantity  int    `json:"quantity" validate:"gte=1"`
+}
+
+// =============================================================================
+
+func toSale(dbSale db.Sale) Sale {
+	ps := (*Sale)(unsafe.Pointer(&dbSale))
+	return *ps
+}
+
+func toSaleSlice(dbSales []db.Sale) []Sale {
+	sales := make([]Sale, len(dbSales))
+	for i, dbSale := range dbSales {
+		sales[i] = toSale(dbSale)
+	}
+	return sales
+}
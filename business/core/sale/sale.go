@@ -0,0 +1,140 @@
+// Package sale provides an example of a core business API that coordinates
+// more than one store. Recording a sale and decrementing the product's stock
+// happen inside a single database transaction, along with recording the sale
+// in the audit log.
+package sale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/audit"
+	auditdb "github.com/ardanlabs/service/business/core/audit/db"
+	productdb "github.com/ardanlabs/service/business/core/product/db"
+	"github.com/ardanlabs/service/business/core/sale/db"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/foundation/tracer"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of error variables for CRUD operations.
+var (
+	ErrProductNotFound   = errors.New("product not found")
+	ErrInvalidID         = errors.New("ID is not in its proper form")
+	ErrInsufficientStock = errors.New("not enough product in stock")
+)
+
+// Core manages the set of APIs for sale access.
+type Core struct {
+	log     *zap.SugaredLogger
+	db      *sqlx.DB
+	store   db.Store
+	product productdb.Store
+	audit   auditdb.Store
+}
+
+// auditEntity names sales in the audit log.
+const auditEntity = "sale"
+
+// NewCore constructs a core for sale api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Core {
+	return Core{
+		log:     log,
+		db:      sqlxDB,
+		store:   db.NewStore(log, sqlxDB),
+		product: productdb.NewStore(log, sqlxDB),
+		audit:   auditdb.NewStore(log, sqlxDB),
+	}
+}
+
+// Create records a new sale and decrements the stock of the product sold. The
+// sale is rejected with ErrInsufficientStock if the product does not have
+// enough quantity left.
+func (c Core) Create(ctx context.Context, ns NewSale, now time.Time) (Sale, error) {
+	ctx, span := tracer.Start(ctx, "sale.Create")
+	defer span.End()
+
+	if err := validate.Check(ns); err != nil {
+		return Sale{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	if err := validate.CheckID(ns.ProductID); err != nil {
+		return Sale{}, ErrInvalidID
+	}
+
+	if err := validate.CheckID(ns.UserID); err != nil {
+		return Sale{}, ErrInvalidID
+	}
+
+	var dbSale db.Sale
+	tran := func(tx sqlx.ExtContext) error {
+
+		// Lock the product row so concurrent sales of the same product are
+		// serialized and the stock can never go negative.
+		dbPrd, err := c.product.Tran(tx).QueryByIDForUpdate(ctx, ns.ProductID)
+		if err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				return ErrProductNotFound
+			}
+			return fmt.Errorf("create: %w", err)
+		}
+
+		if dbPrd.Quantity < ns.Quantity {
+			return fmt.Errorf("productID[%s] quantity[%d] requested[%d]: %w", ns.ProductID, dbPrd.Quantity, ns.Quantity, ErrInsufficientStock)
+		}
+
+		dbPrd.Quantity -= ns.Quantity
+		dbPrd.DateUpdated = now
+
+		if err := c.product.Tran(tx).Update(ctx, dbPrd); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+
+		dbSale = db.Sale{
+			ID:          validate.GenerateID(),
+			UserID:      ns.UserID,
+			ProductID:   ns.ProductID,
+			Quantity:    ns.Quantity,
+			Paid:        ns.Quantity * dbPrd.Cost,
+			DateCreated: now,
+		}
+
+		if err := c.store.Tran(tx).Create(ctx, dbSale); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+
+		if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionCreate, auditEntity, dbSale.ID, nil, toSale(dbSale), now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return Sale{}, err
+	}
+
+	return toSale(dbSale), nil
+}
+
+// QueryByProductID gets the sales recorded for the specified product from
+// the database.
+func (c Core) QueryByProductID(ctx context.Context, productID string) ([]Sale, error) {
+	ctx, span := tracer.Start(ctx, "sale.QueryByProductID")
+	defer span.End()
+
+	if err := validate.CheckID(productID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	dbSales, err := c.store.QueryByProductID(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toSaleSlice(dbSales), nil
+}
@@ -0,0 +1,121 @@
+package sale_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/product"
+	"github.com/ardanlabs/service/business/core/sale"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/foundation/docker"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func TestSale(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testsale")
+	t.Cleanup(teardown)
+
+	core := sale.NewCore(log, db)
+	prdCore := product.NewCore(log, db)
+
+	t.Log("Given the need to work with Sale records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen selling a seeded Product.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			const productID = "a2b0639f-2cc6-44b8-b97b-15d69dbb511e"
+			const userID = "5cf37266-3473-4006-984f-9325122678b7"
+
+			before, err := prdCore.QueryByID(ctx, productID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve product.", dbtest.Success, testID)
+
+			ns := sale.NewSale{
+				UserID:    userID,
+				ProductID: productID,
+				Quantity:  2,
+			}
+
+			sl, err := core.Create(ctx, ns, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a sale : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a sale.", dbtest.Success, testID)
+
+			if exp := ns.Quantity * before.Cost; sl.Paid != exp {
+				t.Errorf("\t%s\tTest %d:\tShould be charged for the quantity sold.", dbtest.Failed, testID)
+				t.Logf("\t\tTest %d:\tGot: %v", testID, sl.Paid)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, exp)
+			} else {
+				t.Logf("\t%s\tTest %d:\tShould be charged for the quantity sold.", dbtest.Success, testID)
+			}
+
+			after, err := prdCore.QueryByID(ctx, productID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product : %s.", dbtest.Failed, testID, err)
+			}
+
+			if exp := before.Quantity - ns.Quantity; after.Quantity != exp {
+				t.Errorf("\t%s\tTest %d:\tShould see the stock decremented.", dbtest.Failed, testID)
+				t.Logf("\t\tTest %d:\tGot: %v", testID, after.Quantity)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, exp)
+			} else {
+				t.Logf("\t%s\tTest %d:\tShould see the stock decremented.", dbtest.Success, testID)
+			}
+
+			sales, err := core.QueryByProductID(ctx, productID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve sales by product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve sales by product.", dbtest.Success, testID)
+
+			var found bool
+			for _, s := range sales {
+				if s.ID == sl.ID {
+					found = true
+				}
+			}
+			if !found {
+				t.Fatalf("\t%s\tTest %d:\tShould find the new sale in the product's sales.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould find the new sale in the product's sales.", dbtest.Success, testID)
+
+			ns.Quantity = after.Quantity + 1
+			if _, err := core.Create(ctx, ns, now); !errors.Is(err, sale.ErrInsufficientStock) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to sell more than in stock : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to sell more than in stock.", dbtest.Success, testID)
+
+			unchanged, err := prdCore.QueryByID(ctx, productID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product : %s.", dbtest.Failed, testID, err)
+			}
+
+			if unchanged.Quantity != after.Quantity {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT see the stock change on a rejected sale : got %d exp %d.", dbtest.Failed, testID, unchanged.Quantity, after.Quantity)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT see the stock change on a rejected sale.", dbtest.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,578 @@
+package user
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/user/db"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/order"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/foundation/tracer"
+	"github.com/jmoiron/sqlx"
+)
+
+// Set of formats users can be imported from and exported to.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// Set of modes an import can run in. An atomic import creates every user or
+// none of them, a best effort import creates the users it can.
+const (
+	ImportAtomic     = "atomic"
+	ImportBestEffort = "best-effort"
+)
+
+// Set of statuses a row of an import can end up in. Valid rows would be
+// created but weren't, because of a dry run. Skipped rows were valid but were
+// left out because another row failed an atomic import.
+const (
+	RowCreated = "created"
+	RowValid   = "valid"
+	RowInvalid = "invalid"
+	RowFailed  = "failed"
+	RowSkipped = "skipped"
+)
+
+// csvColumns are the columns of an export in CSV. An import reads the name,
+// email, roles, password and password_confirm columns by their header and
+// ignores the rest. Password hashes are never exported, so an export can only
+// be imported again once a password column is added.
+var csvColumns = []string{"id", "name", "email", "email_verified", "roles", "date_created", "date_updated", "date_deleted"}
+
+// roleSeparator separates the roles of a user inside a CSV field.
+const roleSeparator = ";"
+
+// maxLineSize is the longest line accepted in NDJSON.
+const maxLineSize = 1 << 20
+
+// Set of limits of a single import. Each password takes tens of milliseconds
+// to hash, so the rows of an import are bounded to finish well within the
+// write timeout of the server. Larger imports need to be split by the client.
+const (
+	maxImportSize = 4 << 20
+	maxImportRows = 200
+)
+
+// exportBatch is the number of users read from the database at a time while
+// exporting.
+const exportBatch = 500
+
+// Import creates the users read from r in the format of the options. Every
+// row is checked with the same rules as Create, and emails must be unique
+// within the import as well as in the database. The result reports the
+// outcome of each row. A dry run checks the rows without creating anything.
+// Every row needs a password, which an export doesn't carry.
+func (c Core) Import(ctx context.Context, r io.Reader, opts ImportOptions, now time.Time) (ImportResult, error) {
+	ctx, span := tracer.Start(ctx, "user.Import")
+	defer span.End()
+
+	if opts.Mode != ImportAtomic && opts.Mode != ImportBestEffort {
+		return ImportResult{}, fmt.Errorf("mode %q: %w", opts.Mode, ErrUnknownImportMode)
+	}
+
+	recs, err := readImport(&limitReader{r: r, n: maxImportSize}, opts.Format)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	result := ImportResult{
+		Mode:   opts.Mode,
+		DryRun: opts.DryRun,
+		Rows:   make([]ImportRow, len(recs)),
+	}
+
+	// Every row is checked before anything is created so an atomic import can
+	// be rejected as a whole.
+	emails := make(map[string]bool)
+	for i, rec := range recs {
+		row := &result.Rows[i]
+		row.Row = i + 1
+		row.Email = rec.nu.Email
+		row.Status = RowValid
+
+		if rec.err != nil {
+			row.fail(RowInvalid, rec.err)
+			continue
+		}
+
+		if err := validate.Check(rec.nu); err != nil {
+			row.fail(RowInvalid, err)
+			continue
+		}
+
+		if emails[rec.nu.Email] {
+			row.fail(RowFailed, ErrUniqueEmail)
+			continue
+		}
+		emails[rec.nu.Email] = true
+
+		switch _, err := c.store.QueryByEmail(ctx, rec.nu.Email); {
+		case err == nil:
+			row.fail(RowFailed, ErrUniqueEmail)
+		case !errors.Is(err, database.ErrDBNotFound):
+			return ImportResult{}, fmt.Errorf("checking row %d: %w", row.Row, err)
+		}
+	}
+
+	if opts.DryRun {
+		result.count()
+		return result, nil
+	}
+
+	if opts.Mode == ImportAtomic && result.failures() {
+		result.skipValid()
+		result.count()
+		return result, nil
+	}
+
+	type pendingUser struct {
+		row   int
+		dbUsr db.User
+	}
+
+	var pending []pendingUser
+	for i := range recs {
+		if result.Rows[i].Status == RowValid {
+			pending = append(pending, pendingUser{row: i})
+		}
+	}
+
+	// Hashing the passwords is what takes the longest, so the users are
+	// constructed on every CPU at once.
+	var wg sync.WaitGroup
+	errs := make([]error, len(pending))
+	sem := make(chan struct{}, runtime.NumCPU())
+	for i := range pending {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			pending[i].dbUsr, errs[i] = newDBUser(recs[pending[i].row].nu, now)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return ImportResult{}, fmt.Errorf("row %d: %w", pending[i].row+1, err)
+		}
+	}
+
+	switch opts.Mode {
+	case ImportAtomic:
+
+		// Another user can take an email after the rows were checked, which
+		// fails the whole import on the row that has it.
+		var failed int
+		tran := func(tx sqlx.ExtContext) error {
+			for i, p := range pending {
+				if err := c.insert(ctx, tx, p.dbUsr, now); err != nil {
+					failed = i
+					return err
+				}
+			}
+			return nil
+		}
+
+		if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+			if !errors.Is(err, ErrUniqueEmail) {
+				return ImportResult{}, fmt.Errorf("importing row %d: %w", pending[failed].row+1, err)
+			}
+			result.Rows[pending[failed].row].fail(RowFailed, ErrUniqueEmail)
+			result.skipValid()
+			result.count()
+			return result, nil
+		}
+
+		for _, p := range pending {
+			result.Rows[p.row].created(p.dbUsr.ID)
+		}
+
+	case ImportBestEffort:
+		for _, p := range pending {
+			tran := func(tx sqlx.ExtContext) error {
+				return c.insert(ctx, tx, p.dbUsr, now)
+			}
+
+			if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+				if !errors.Is(err, ErrUniqueEmail) {
+					return ImportResult{}, fmt.Errorf("importing row %d: %w", p.row+1, err)
+				}
+				result.Rows[p.row].fail(RowFailed, ErrUniqueEmail)
+				continue
+			}
+			result.Rows[p.row].created(p.dbUsr.ID)
+		}
+	}
+
+	result.count()
+	return result, nil
+}
+
+// Export writes the users matching the filter to w in the format, ordered by
+// id. Users are read in batches and written as they are read, so the users
+// aren't held in memory. The export still has to finish within the write
+// timeout of the server, or the document is cut short. It returns the number
+// of users written. Passwords aren't exported, so the document can't be
+// imported as is.
+func (c Core) Export(ctx context.Context, w io.Writer, format string, filter QueryFilter) (int, error) {
+	ctx, span := tracer.Start(ctx, "user.Export")
+	defer span.End()
+
+	if err := validate.Check(filter); err != nil {
+		return 0, fmt.Errorf("validating filter: %w", err)
+	}
+
+	enc, err := newExportEncoder(w, format)
+	if err != nil {
+		return 0, err
+	}
+
+	dbFilter := db.QueryFilter(filter)
+	orderBy := order.NewBy(orderByFields[OrderByID], order.ASC)
+
+	dbUsrs, err := c.store.Query(ctx, dbFilter, orderBy, 1, exportBatch)
+	if err != nil {
+		return 0, fmt.Errorf("query: %w", err)
+	}
+
+	var n int
+	for {
+		for _, dbUsr := range dbUsrs {
+			if err := enc.encode(toUser(dbUsr)); err != nil {
+				return n, fmt.Errorf("encoding userID[%s]: %w", dbUsr.ID, err)
+			}
+			n++
+		}
+
+		if err := enc.flush(); err != nil {
+			return n, fmt.Errorf("writing: %w", err)
+		}
+
+		if len(dbUsrs) < exportBatch {
+			return n, nil
+		}
+
+		last := dbUsrs[len(dbUsrs)-1]
+		key := db.Keyset{
+			Value: last.ID,
+			ID:    last.ID,
+		}
+
+		dbUsrs, err = c.store.QueryKeyset(ctx, dbFilter, orderBy, key, exportBatch)
+		if err != nil {
+			return n, fmt.Errorf("query: %w", err)
+		}
+	}
+}
+
+// =============================================================================
+
+// fail marks the row with the status and the reason it failed.
+func (r *ImportRow) fail(status string, err error) {
+	r.Status = status
+
+	if fields := validate.GetFieldErrors(err); fields != nil {
+		r.Error = "data validation error"
+		r.Fields = fields
+		return
+	}
+	r.Error = err.Error()
+}
+
+// created marks the row as created as the user with the specified id.
+func (r *ImportRow) created(userID string) {
+	r.Status = RowCreated
+	r.ID = userID
+}
+
+// failures reports if any row of the import is invalid or failed.
+func (r *ImportResult) failures() bool {
+	for _, row := range r.Rows {
+		if row.Status == RowInvalid || row.Status == RowFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// skipValid marks the valid rows as skipped once an atomic import fails.
+func (r *ImportResult) skipValid() {
+	for i := range r.Rows {
+		if r.Rows[i].Status == RowValid {
+			r.Rows[i].Status = RowSkipped
+		}
+	}
+}
+
+// count totals the rows created and failed.
+func (r *ImportResult) count() {
+	r.Created, r.Failed = 0, 0
+	for _, row := range r.Rows {
+		switch row.Status {
+		case RowCreated:
+			r.Created++
+		case RowInvalid, RowFailed:
+			r.Failed++
+		}
+	}
+}
+
+// =============================================================================
+
+// importRecord is a row read from an import, or the reason it couldn't be
+// read.
+type importRecord struct {
+	nu  NewUser
+	err error
+}
+
+// readImport reads the rows of an import in the specified format. Rows that
+// can't be decoded are returned with the error, so only a failure to read
+// the input as a whole fails.
+func readImport(r io.Reader, format string) ([]importRecord, error) {
+	var recs []importRecord
+	var err error
+
+	switch format {
+	case FormatCSV:
+		recs, err = readCSV(r)
+	case FormatNDJSON:
+		recs, err = readNDJSON(r)
+	default:
+		return nil, fmt.Errorf("format %q: %w", format, ErrUnknownFormat)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// There is nobody to retype the password during an import, so a missing
+	// confirmation is taken to be the password.
+	for i := range recs {
+		if recs[i].nu.PasswordConfirm == "" {
+			recs[i].nu.PasswordConfirm = recs[i].nu.Password
+		}
+	}
+
+	return recs, nil
+}
+
+// readCSV reads the rows of a CSV import. The first line is the header that
+// names the columns.
+func readCSV(r io.Reader) ([]importRecord, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.TrimPrefix(name, "\ufeff")
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	var recs []importRecord
+	for {
+		fields, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return recs, nil
+		}
+
+		if len(recs) == maxImportRows {
+			return nil, fmt.Errorf("more than %d rows: %w", maxImportRows, ErrImportTooLarge)
+		}
+
+		if err != nil {
+			var perr *csv.ParseError
+			if !errors.As(err, &perr) {
+				return nil, fmt.Errorf("reading csv: %w", err)
+			}
+			recs = append(recs, importRecord{err: perr.Err})
+			continue
+		}
+
+		if len(fields) != len(header) {
+			err := fmt.Errorf("expected %d fields, got %d", len(header), len(fields))
+			recs = append(recs, importRecord{err: err})
+			continue
+		}
+
+		field := func(name string) string {
+			if i, exists := columns[name]; exists {
+				return fields[i]
+			}
+			return ""
+		}
+
+		nu := NewUser{
+			Name:            field("name"),
+			Email:           field("email"),
+			Roles:           splitRoles(field("roles")),
+			Password:        field("password"),
+			PasswordConfirm: field("password_confirm"),
+		}
+		recs = append(recs, importRecord{nu: nu})
+	}
+}
+
+// readNDJSON reads the rows of an NDJSON import, one user document per line.
+// Blank lines are ignored.
+func readNDJSON(r io.Reader) ([]importRecord, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	var recs []importRecord
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		if len(recs) == maxImportRows {
+			return nil, fmt.Errorf("more than %d rows: %w", maxImportRows, ErrImportTooLarge)
+		}
+
+		var nu NewUser
+		if err := json.Unmarshal(line, &nu); err != nil {
+			recs = append(recs, importRecord{err: fmt.Errorf("decoding: %w", err)})
+			continue
+		}
+		recs = append(recs, importRecord{nu: nu})
+	}
+
+	if err := sc.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("line longer than %d bytes: %w", maxLineSize, ErrImportTooLarge)
+		}
+		return nil, fmt.Errorf("reading ndjson: %w", err)
+	}
+
+	return recs, nil
+}
+
+// limitReader reads from r until n bytes were read, then fails with
+// ErrImportTooLarge.
+type limitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, fmt.Errorf("more than %d bytes: %w", maxImportSize, ErrImportTooLarge)
+	}
+
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// splitRoles splits the roles held in a CSV field.
+func splitRoles(field string) []string {
+	var roles []string
+	for _, role := range strings.Split(field, roleSeparator) {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// =============================================================================
+
+// exportEncoder writes the users of an export in a format. Users may be
+// buffered until flush is called.
+type exportEncoder interface {
+	encode(usr User) error
+	flush() error
+}
+
+// newExportEncoder constructs the encoder for the format.
+func newExportEncoder(w io.Writer, format string) (exportEncoder, error) {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvColumns); err != nil {
+			return nil, fmt.Errorf("writing header: %w", err)
+		}
+		return csvEncoder{w: cw}, nil
+
+	case FormatNDJSON:
+		bw := bufio.NewWriter(w)
+		return ndjsonEncoder{w: bw, enc: json.NewEncoder(bw)}, nil
+	}
+
+	return nil, fmt.Errorf("format %q: %w", format, ErrUnknownFormat)
+}
+
+// csvEncoder writes users as the csvColumns of a CSV file.
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e csvEncoder) encode(usr User) error {
+	var deleted string
+	if usr.DateDeleted != nil {
+		deleted = usr.DateDeleted.Format(time.RFC3339)
+	}
+
+	record := []string{
+		usr.ID,
+		usr.Name,
+		usr.Email,
+		strconv.FormatBool(usr.EmailVerified),
+		strings.Join(usr.Roles, roleSeparator),
+		usr.DateCreated.Format(time.RFC3339),
+		usr.DateUpdated.Format(time.RFC3339),
+		deleted,
+	}
+
+	return e.w.Write(record)
+}
+
+func (e csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// ndjsonEncoder writes users as one JSON document per line.
+type ndjsonEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e ndjsonEncoder) encode(usr User) error {
+	return e.enc.Encode(usr)
+}
+
+func (e ndjsonEncoder) flush() error {
+	return e.w.Flush()
+}
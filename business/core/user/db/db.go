@@ -2,10 +2,13 @@
 package db
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/order"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
@@ -13,7 +16,7 @@ import (
 // Store manages the set of APIs for user access.
 type Store struct {
 	log *zap.SugaredLogger
-	db  *sqlx.DB
+	db  sqlx.ExtContext
 }
 
 // NewStore constructs a data for api access.
@@ -24,13 +27,22 @@ func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
 	}
 }
 
+// Tran returns a new Store value bound to the specified transaction so
+// several calls can be made as a single unit of work.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log: s.log,
+		db:  tx,
+	}
+}
+
 // Create inserts a new user into the database.
 func (s Store) Create(ctx context.Context, usr User) error {
 	const q = `
 	INSERT INTO users
-		(user_id, name, email, password_hash, roles, date_created, date_updated)
+		(user_id, name, email, email_verified, password_hash, roles, date_created, date_updated, version)
 	VALUES
-		(:user_id, :name, :email, :password_hash, :roles, :date_created, :date_updated)`
+		(:user_id, :name, :email, :email_verified, :password_hash, :roles, :date_created, :date_updated, :version)`
 
 	if err := database.NamedExecContext(ctx, s.log, s.db, q, usr); err != nil {
 		return fmt.Errorf("inserting user: %w", err)
@@ -39,7 +51,9 @@ func (s Store) Create(ctx context.Context, usr User) error {
 	return nil
 }
 
-// Update replaces a user document in the database.
+// Update replaces a user document in the database and increments its
+// version. The update only happens if the user is still at the version of
+// the document, otherwise ErrDBNotFound is returned.
 func (s Store) Update(ctx context.Context, usr User) error {
 	const q = `
 	UPDATE
@@ -47,21 +61,86 @@ func (s Store) Update(ctx context.Context, usr User) error {
 	SET 
 		"name" = :name,
 		"email" = :email,
+		"email_verified" = :email_verified,
 		"roles" = :roles,
 		"password_hash" = :password_hash,
-		"date_updated" = :date_updated
+		"date_updated" = :date_updated,
+		"version" = :version + 1
 	WHERE
-		user_id = :user_id`
+		user_id = :user_id AND
+		version = :version AND
+		date_deleted IS NULL`
 
-	if err := database.NamedExecContext(ctx, s.log, s.db, q, usr); err != nil {
+	n, err := database.NamedExecContextAffected(ctx, s.log, s.db, q, usr)
+	if err != nil {
 		return fmt.Errorf("updating userID[%s]: %w", usr.ID, err)
 	}
 
+	if n == 0 {
+		return fmt.Errorf("updating userID[%s] version[%d]: %w", usr.ID, usr.Version, database.ErrDBNotFound)
+	}
+
+	return nil
+}
+
+// Delete marks a user as deleted. The row is kept until it is purged so the
+// user can be restored.
+func (s Store) Delete(ctx context.Context, userID string, now time.Time) error {
+	data := struct {
+		UserID      string    `db:"user_id"`
+		DateDeleted time.Time `db:"date_deleted"`
+	}{
+		UserID:      userID,
+		DateDeleted: now,
+	}
+
+	const q = `
+	UPDATE
+		users
+	SET
+		"date_deleted" = :date_deleted,
+		"version" = version + 1
+	WHERE
+		user_id = :user_id AND
+		date_deleted IS NULL`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// Restore clears the deleted mark of a user.
+func (s Store) Restore(ctx context.Context, userID string, now time.Time) error {
+	data := struct {
+		UserID      string    `db:"user_id"`
+		DateUpdated time.Time `db:"date_updated"`
+	}{
+		UserID:      userID,
+		DateUpdated: now,
+	}
+
+	const q = `
+	UPDATE
+		users
+	SET
+		"date_deleted" = NULL,
+		"date_updated" = :date_updated,
+		"version" = version + 1
+	WHERE
+		user_id = :user_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("restoring userID[%s]: %w", userID, err)
+	}
+
 	return nil
 }
 
-// Delete removes a user from the database.
-func (s Store) Delete(ctx context.Context, userID string) error {
+// Purge removes a deleted user from the database for good. The products of
+// the user and their sales are removed along with it.
+func (s Store) Purge(ctx context.Context, userID string) error {
 	data := struct {
 		UserID string `db:"user_id"`
 	}{
@@ -72,23 +151,22 @@ func (s Store) Delete(ctx context.Context, userID string) error {
 	DELETE FROM
 		users
 	WHERE
-		user_id = :user_id`
+		user_id = :user_id AND
+		date_deleted IS NOT NULL`
 
 	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
-		return fmt.Errorf("deleting userID[%s]: %w", userID, err)
+		return fmt.Errorf("purging userID[%s]: %w", userID, err)
 	}
 
 	return nil
 }
 
-// Query retrieves a list of existing users from the database.
-func (s Store) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]User, error) {
+// QueryDeletedBefore retrieves the users deleted before the specified time.
+func (s Store) QueryDeletedBefore(ctx context.Context, before time.Time) ([]User, error) {
 	data := struct {
-		Offset      int `db:"offset"`
-		RowsPerPage int `db:"rows_per_page"`
+		Before time.Time `db:"before"`
 	}{
-		Offset:      (pageNumber - 1) * rowsPerPage,
-		RowsPerPage: rowsPerPage,
+		Before: before,
 	}
 
 	const q = `
@@ -96,18 +174,113 @@ func (s Store) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]Us
 		*
 	FROM
 		users
-	ORDER BY
-		user_id
-	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+	WHERE
+		date_deleted < :before`
 
 	var usrs []User
 	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, data, &usrs); err != nil {
+		return nil, fmt.Errorf("selecting deleted users: %w", err)
+	}
+
+	return usrs, nil
+}
+
+// Query retrieves a list of existing users from the database. The orderBy
+// field must be a column name already checked by the caller.
+func (s Store) Query(ctx context.Context, filter QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]User, error) {
+	data := map[string]interface{}{
+		"offset":        (pageNumber - 1) * rowsPerPage,
+		"rows_per_page": rowsPerPage,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		users`
+
+	buf := bytes.NewBufferString(q)
+	applyFilter(filter, data, buf)
+
+	buf.WriteString(orderByClause(orderBy))
+	buf.WriteString(" OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY")
+
+	var usrs []User
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, buf.String(), data, &usrs); err != nil {
+		return nil, fmt.Errorf("selecting users: %w", err)
+	}
+
+	return usrs, nil
+}
+
+// QueryKeyset retrieves a list of existing users from the database that are
+// positioned after the specified keyset in the requested order. Seeking to
+// the keyset avoids the cost of skipping rows with an OFFSET on large tables.
+func (s Store) QueryKeyset(ctx context.Context, filter QueryFilter, orderBy order.By, key Keyset, rowsPerPage int) ([]User, error) {
+	data := map[string]interface{}{
+		"key_value":     key.Value,
+		"key_id":        key.ID,
+		"rows_per_page": rowsPerPage,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		users`
+
+	cmp := ">"
+	if orderBy.Direction == order.DESC {
+		cmp = "<"
+	}
+	seek := fmt.Sprintf("(%s, user_id) %s (:key_value, :key_id)", orderBy.Field, cmp)
+
+	buf := bytes.NewBufferString(q)
+	applyFilter(filter, data, buf, seek)
+
+	buf.WriteString(orderByClause(orderBy))
+	buf.WriteString(" FETCH FIRST :rows_per_page ROWS ONLY")
+
+	var usrs []User
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, buf.String(), data, &usrs); err != nil {
 		return nil, fmt.Errorf("selecting users: %w", err)
 	}
 
 	return usrs, nil
 }
 
+// Count returns the total number of users matching the filter.
+func (s Store) Count(ctx context.Context, filter QueryFilter) (int, error) {
+	data := map[string]interface{}{}
+
+	const q = `
+	SELECT
+		count(1) AS count
+	FROM
+		users`
+
+	buf := bytes.NewBufferString(q)
+	applyFilter(filter, data, buf)
+
+	var count struct {
+		Count int `db:"count"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, buf.String(), data, &count); err != nil {
+		return 0, fmt.Errorf("counting users: %w", err)
+	}
+
+	return count.Count, nil
+}
+
+// orderByClause builds the ORDER BY clause for the query. The user id is
+// always used as a tie breaker so the order is stable for keyset paging.
+func orderByClause(orderBy order.By) string {
+	if orderBy.Field == "user_id" {
+		return fmt.Sprintf(" ORDER BY user_id %s", orderBy.Direction)
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, user_id %s", orderBy.Field, orderBy.Direction, orderBy.Direction)
+}
+
 // QueryByID gets the specified user from the database.
 func (s Store) QueryByID(ctx context.Context, userID string) (User, error) {
 	data := struct {
@@ -122,6 +295,32 @@ func (s Store) QueryByID(ctx context.Context, userID string) (User, error) {
 	FROM
 		users
 	WHERE 
+		user_id = :user_id AND
+		date_deleted IS NULL`
+
+	var usr User
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &usr); err != nil {
+		return User{}, fmt.Errorf("selecting userID[%q]: %w", userID, err)
+	}
+
+	return usr, nil
+}
+
+// QueryByIDIncludeDeleted gets the specified user from the database, even
+// when the user is deleted.
+func (s Store) QueryByIDIncludeDeleted(ctx context.Context, userID string) (User, error) {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		users
+	WHERE
 		user_id = :user_id`
 
 	var usr User
@@ -146,7 +345,8 @@ func (s Store) QueryByEmail(ctx context.Context, email string) (User, error) {
 	FROM
 		users
 	WHERE
-		email = :email`
+		email = :email AND
+		date_deleted IS NULL`
 
 	var usr User
 	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &usr); err != nil {
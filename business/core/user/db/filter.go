@@ -0,0 +1,76 @@
+package db
+
+import (
+	"bytes"
+	"strings"
+	"time"
+)
+
+// QueryFilter holds the available fields a query can be filtered on. A nil
+// field is not part of the filter. Deleted users are left out unless
+// IncludeDeleted is set.
+type QueryFilter struct {
+	Name             *string
+	Email            *string
+	Role             *string
+	StartCreatedDate *time.Time
+	EndCreatedDate   *time.Time
+	IncludeDeleted   bool
+}
+
+// Keyset represents the position of a row inside an ordered result set,
+// identified by the value of the ordered column and the user id.
+type Keyset struct {
+	Value string
+	ID    string
+}
+
+// applyFilter adds the WHERE clause for the filter to the query buffer and
+// the matching named parameters to data. Any extra conditions provided are
+// combined with the filter.
+func applyFilter(filter QueryFilter, data map[string]interface{}, buf *bytes.Buffer, conds ...string) {
+	wc := conds
+
+	if !filter.IncludeDeleted {
+		wc = append(wc, "date_deleted IS NULL")
+	}
+
+	if filter.Name != nil {
+		data["name"] = "%" + escapeLike(*filter.Name) + "%"
+		wc = append(wc, "name ILIKE :name")
+	}
+
+	if filter.Email != nil {
+		data["email"] = *filter.Email
+		wc = append(wc, "email = :email")
+	}
+
+	if filter.Role != nil {
+		data["role"] = *filter.Role
+		wc = append(wc, ":role = ANY(roles)")
+	}
+
+	if filter.StartCreatedDate != nil {
+		data["start_date_created"] = filter.StartCreatedDate.UTC()
+		wc = append(wc, "date_created >= :start_date_created")
+	}
+
+	if filter.EndCreatedDate != nil {
+		data["end_date_created"] = filter.EndCreatedDate.UTC()
+		wc = append(wc, "date_created <= :end_date_created")
+	}
+
+	if len(wc) > 0 {
+		buf.WriteString(" WHERE ")
+		buf.WriteString(strings.Join(wc, " AND "))
+	}
+}
+
+// likeEscaper escapes the characters with a meaning in a LIKE pattern, so
+// they only match themselves. Backslash is the default escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike returns the value as a LIKE pattern matching only the value.
+func escapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
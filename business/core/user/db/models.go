@@ -9,13 +9,16 @@ import (
 // User represent the structure we need for moving data
 // between the app and the database.
 type User struct {
-	ID           string         `db:"user_id"`
-	Name         string         `db:"name"`
-	Email        string         `db:"email"`
-	Roles        pq.StringArray `db:"roles"`
-	PasswordHash []byte         `db:"password_hash"`
-	DateCreated  time.Time      `db:"date_created"`
-	DateUpdated  time.Time      `db:"date_updated"`
+	ID            string         `db:"user_id"`
+	Name          string         `db:"name"`
+	Email         string         `db:"email"`
+	EmailVerified bool           `db:"email_verified"`
+	Roles         pq.StringArray `db:"roles"`
+	PasswordHash  []byte         `db:"password_hash"`
+	DateCreated   time.Time      `db:"date_created"`
+	DateUpdated   time.Time      `db:"date_updated"`
+	DateDeleted   *time.Time     `db:"date_deleted"`
+	Version       int            `db:"version"`
 }
 
 /*
@@ -30,4 +33,10 @@ CREATE TABLE users (
 
 	PRIMARY KEY (user_id)
 );
+
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN date_deleted TIMESTAMP NULL;
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+CREATE UNIQUE INDEX users_email_idx ON users (email) WHERE date_deleted IS NULL;
+ALTER TABLE users ADD COLUMN version INT NOT NULL DEFAULT 1;
 */
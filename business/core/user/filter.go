@@ -0,0 +1,49 @@
+package user
+
+import (
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/order"
+)
+
+// QueryFilter holds the available fields a query can be filtered on. All
+// fields are optional and a nil field is not applied to the query. Deleted
+// users are left out unless IncludeDeleted is set.
+type QueryFilter struct {
+	Name             *string `validate:"omitempty,min=1"`
+	Email            *string `validate:"omitempty,email"`
+	Role             *string `validate:"omitempty,min=1"`
+	StartCreatedDate *time.Time
+	EndCreatedDate   *time.Time
+	IncludeDeleted   bool
+}
+
+// Set of fields that the results can be ordered by. These are the names used
+// by clients and match the JSON names of the User fields.
+const (
+	OrderByID          = "id"
+	OrderByName        = "name"
+	OrderByEmail       = "email"
+	OrderByDateCreated = "date_created"
+)
+
+// DefaultOrderBy represents the default way we sort.
+var DefaultOrderBy = order.NewBy(OrderByID, order.ASC)
+
+// orderByFields is the whitelist of fields that can be ordered by, mapped to
+// the columns in the store.
+var orderByFields = map[string]string{
+	OrderByID:          "user_id",
+	OrderByName:        "name",
+	OrderByEmail:       "email",
+	OrderByDateCreated: "date_created",
+}
+
+// QueryResult is a page of users along with the information needed to move
+// to the next and previous pages.
+type QueryResult struct {
+	Items      []User `json:"items"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
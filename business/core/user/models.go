@@ -1,21 +1,28 @@
 package user
 
 import (
+	"fmt"
 	"time"
 	"unsafe"
 
 	"github.com/ardanlabs/service/business/core/user/db"
+	"github.com/ardanlabs/service/business/sys/order"
+	"github.com/ardanlabs/service/business/sys/paging"
+	"github.com/ardanlabs/service/business/sys/validate"
 )
 
 // User represents an individual user.
 type User struct {
-	ID           string    `json:"id"`
-	Name         string    `json:"name"`
-	Email        string    `json:"email"`
-	Roles        []string  `json:"roles"`
-	PasswordHash []byte    `json:"-"`
-	DateCreated  time.Time `json:"date_created"`
-	DateUpdated  time.Time `json:"date_updated"`
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	Email         string     `json:"email"`
+	EmailVerified bool       `json:"email_verified"`
+	Roles         []string   `json:"roles"`
+	PasswordHash  []byte     `json:"-"`
+	DateCreated   time.Time  `json:"date_created"`
+	DateUpdated   time.Time  `json:"date_updated"`
+	DateDeleted   *time.Time `json:"date_deleted,omitempty"`
+	Version       int        `json:"version"`
 }
 
 // NewUser contains information needed to create a new User.
@@ -39,6 +46,56 @@ type UpdateUser struct {
 	Roles           []string `json:"roles"`
 	Password        *string  `json:"password"`
 	PasswordConfirm *string  `json:"password_confirm" validate:"omitempty,eqfield=Password"`
+
+	// Version is the version of the user the changes are based on. When set,
+	// the update fails with ErrConflict if the user has changed since. It is
+	// not part of the document since clients send it in the If-Match header.
+	Version *int `json:"-"`
+}
+
+// Set of media types of the patches a user can be changed with.
+const (
+	PatchMerge = "application/merge-patch+json"
+	PatchJSON  = "application/json-patch+json"
+)
+
+// Patch is a change to a user described by a patch document of the media
+// type, applied to the user document as clients read it.
+type Patch struct {
+	Type     string
+	Document []byte
+
+	// Version is the version of the user the patch is based on, like the
+	// version of UpdateUser.
+	Version *int
+}
+
+// ImportOptions controls how users are imported.
+type ImportOptions struct {
+	Format string
+	Mode   string
+	DryRun bool
+}
+
+// ImportResult reports the outcome of an import row by row. Created counts
+// the users created and Failed the rows that were invalid or failed.
+type ImportResult struct {
+	Mode    string      `json:"mode"`
+	DryRun  bool        `json:"dry_run"`
+	Created int         `json:"created"`
+	Failed  int         `json:"failed"`
+	Rows    []ImportRow `json:"rows"`
+}
+
+// ImportRow reports the outcome of importing a single row. Rows are numbered
+// from 1 in the order they were read, not counting the CSV header.
+type ImportRow struct {
+	Row    int                  `json:"row"`
+	Status string               `json:"status"`
+	Email  string               `json:"email,omitempty"`
+	ID     string               `json:"id,omitempty"`
+	Error  string               `json:"error,omitempty"`
+	Fields validate.FieldErrors `json:"fields,omitempty"`
 }
 
 // =============================================================================
@@ -55,3 +112,83 @@ func toUserSlice(dbUsrs []db.User) []User {
 	}
 	return users
 }
+
+// orderByColumn checks the order is on a whitelisted field and returns the
+// store column to order by.
+func orderByColumn(orderBy order.By) (string, error) {
+	column, exists := orderByFields[orderBy.Field]
+	if !exists {
+		return "", fmt.Errorf("field %q: %w", orderBy.Field, order.ErrInvalidOrder)
+	}
+
+	if orderBy.Direction != order.ASC && orderBy.Direction != order.DESC {
+		return "", fmt.Errorf("direction %q: %w", orderBy.Direction, order.ErrInvalidOrder)
+	}
+
+	return column, nil
+}
+
+// newQueryResult constructs the page of users with the cursors pointing to
+// the neighbouring pages.
+func newQueryResult(dbUsrs []db.User, total int, orderBy order.By, hasNext bool, hasPrev bool) QueryResult {
+	qr := QueryResult{
+		Items: toUserSlice(dbUsrs),
+		Total: total,
+	}
+
+	if len(dbUsrs) == 0 {
+		return qr
+	}
+
+	if hasNext {
+		qr.NextCursor = newCursor(dbUsrs[len(dbUsrs)-1], orderBy, false).Encode()
+	}
+	if hasPrev {
+		qr.PrevCursor = newCursor(dbUsrs[0], orderBy, true).Encode()
+	}
+
+	return qr
+}
+
+// newCursor constructs the cursor for the position of the specified user.
+func newCursor(dbUsr db.User, orderBy order.By, prev bool) paging.Cursor {
+	var value string
+	switch orderBy.Field {
+	case OrderByName:
+		value = dbUsr.Name
+	case OrderByEmail:
+		value = dbUsr.Email
+	case OrderByDateCreated:
+		value = dbUsr.DateCreated.Format(time.RFC3339Nano)
+	default:
+		value = dbUsr.ID
+	}
+
+	return paging.Cursor{
+		Field:     orderBy.Field,
+		Direction: orderBy.Direction,
+		Value:     value,
+		ID:        dbUsr.ID,
+		Prev:      prev,
+	}
+}
+
+// equalRoles reports if both sets of roles are the same, ignoring order.
+func equalRoles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	set := make(map[string]int, len(a))
+	for _, role := range a {
+		set[role]++
+	}
+	for _, role := range b {
+		if set[role] == 0 {
+			return false
+		}
+		set[role]--
+	}
+
+	return true
+}
@@ -0,0 +1,137 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/foundation/jsonpatch"
+	"github.com/ardanlabs/service/foundation/tracer"
+)
+
+// patchDocument is the user document patches are applied to. It is the
+// document clients read plus the password, which can be written but is never
+// read back.
+type patchDocument struct {
+	User
+	Password *string `json:"password,omitempty"`
+}
+
+// patchedUser holds the fields of a patched document that can change, with
+// the rules the result has to follow.
+type patchedUser struct {
+	Name     string   `json:"name" validate:"required"`
+	Email    string   `json:"email" validate:"required,email"`
+	Roles    []string `json:"roles" validate:"required"`
+	Password *string  `json:"password" validate:"omitempty,min=1"`
+}
+
+// Patch applies a JSON Merge Patch or a JSON Patch to the user document and
+// updates the user with the result, which is validated as a whole. Only the
+// name, email, roles and password can be changed. Like Update, the patch
+// fails with ErrConflict if the user is not at the expected version.
+func (c Core) Patch(ctx context.Context, userID string, p Patch, now time.Time) (User, error) {
+	ctx, span := tracer.Start(ctx, "user.Patch")
+	defer span.End()
+
+	if err := validate.CheckID(userID); err != nil {
+		return User{}, ErrInvalidID
+	}
+
+	dbUsr, err := c.store.QueryByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return User{}, ErrNotFound
+		}
+		return User{}, fmt.Errorf("patching user userID[%s]: %w", userID, err)
+	}
+	usr := toUser(dbUsr)
+
+	if p.Version != nil && *p.Version != usr.Version {
+		return User{}, fmt.Errorf("patching user userID[%s] version[%d]: %w", userID, *p.Version, ErrConflict)
+	}
+
+	doc, err := json.Marshal(usr)
+	if err != nil {
+		return User{}, fmt.Errorf("encoding user: %w", err)
+	}
+
+	var patched []byte
+	switch p.Type {
+	case PatchMerge:
+		patched, err = jsonpatch.MergePatch(doc, p.Document)
+	case PatchJSON:
+		patched, err = jsonpatch.Apply(doc, p.Document)
+	default:
+		return User{}, fmt.Errorf("media type %q: %w", p.Type, ErrUnsupportedPatch)
+	}
+	if err != nil {
+		if errors.Is(err, jsonpatch.ErrInvalidPatch) {
+			return User{}, fmt.Errorf("%v: %w", err, ErrInvalidPatch)
+		}
+		return User{}, fmt.Errorf("%v: %w", err, ErrPatchFailed)
+	}
+
+	var pd patchDocument
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&pd); err != nil {
+		return User{}, fmt.Errorf("decoding patched user: %v: %w", err, ErrPatchFailed)
+	}
+
+	if err := checkReadOnly(usr, pd.User); err != nil {
+		return User{}, err
+	}
+
+	pu := patchedUser{
+		Name:     pd.Name,
+		Email:    pd.Email,
+		Roles:    pd.Roles,
+		Password: pd.Password,
+	}
+	if err := validate.Check(pu); err != nil {
+		return User{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	// The update is made against the version the patch was applied to, so a
+	// change in between fails with ErrConflict instead of being overwritten.
+	uu := UpdateUser{
+		Name:            &pu.Name,
+		Email:           &pu.Email,
+		Roles:           pu.Roles,
+		Password:        pu.Password,
+		PasswordConfirm: pu.Password,
+		Version:         &usr.Version,
+	}
+
+	return c.Update(ctx, userID, uu, now)
+}
+
+// checkReadOnly makes sure the patched user only differs from the user in the
+// fields that can be patched.
+func checkReadOnly(usr User, patched User) error {
+	patched.Name = usr.Name
+	patched.Email = usr.Email
+	patched.Roles = usr.Roles
+
+	before, err := json.Marshal(usr)
+	if err != nil {
+		return fmt.Errorf("encoding user: %w", err)
+	}
+
+	after, err := json.Marshal(patched)
+	if err != nil {
+		return fmt.Errorf("encoding patched user: %w", err)
+	}
+
+	if !bytes.Equal(before, after) {
+		return fmt.Errorf("only the name, email, roles and password can be changed: %w", ErrPatchFailed)
+	}
+
+	return nil
+}
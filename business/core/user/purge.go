@@ -0,0 +1,75 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Purger periodically purges the users that have been deleted for longer
+// than the retention period.
+type Purger struct {
+	log       *zap.SugaredLogger
+	core      Core
+	retention time.Duration
+	shutdown  chan struct{}
+	done      chan struct{}
+}
+
+// NewPurger constructs a purger keeping deleted users for the specified
+// retention period.
+func NewPurger(log *zap.SugaredLogger, core Core, retention time.Duration) *Purger {
+	return &Purger{
+		log:       log,
+		core:      core,
+		retention: retention,
+		shutdown:  make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start purges the expired users at the specified interval until Shutdown is
+// called.
+func (p *Purger) Start(interval time.Duration) {
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.purge()
+			case <-p.shutdown:
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stops purging and waits for a purge in progress to finish.
+func (p *Purger) Shutdown() {
+	close(p.shutdown)
+	<-p.done
+}
+
+// =============================================================================
+
+func (p *Purger) purge() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	now := time.Now().UTC()
+
+	purged, err := p.core.Purge(ctx, now.Add(-p.retention), now)
+	if err != nil {
+		p.log.Errorw("users", "status", "purging deleted users", "ERROR", err)
+		return
+	}
+
+	if purged > 0 {
+		p.log.Infow("users", "status", "purged deleted users", "count", purged)
+	}
+}
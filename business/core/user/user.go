@@ -1,6 +1,5 @@
-// Package user provides an example of a core business API. Right now these
-// calls are just wrapping the data/data layer. But at some point you will
-// want auditing or something that isn't specific to the data/store layer.
+// Package user provides an example of a core business API. Every change to a
+// user is recorded in the audit log within the same transaction.
 package user
 
 import (
@@ -9,10 +8,18 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ardanlabs/service/business/core/audit"
+	auditdb "github.com/ardanlabs/service/business/core/audit/db"
+	refreshdb "github.com/ardanlabs/service/business/core/refresh/db"
+	"github.com/ardanlabs/service/business/core/revocation"
+	revocationdb "github.com/ardanlabs/service/business/core/revocation/db"
 	"github.com/ardanlabs/service/business/core/user/db"
 	"github.com/ardanlabs/service/business/sys/auth"
 	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/order"
+	"github.com/ardanlabs/service/business/sys/paging"
 	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/foundation/tracer"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
@@ -26,29 +33,71 @@ var (
 	ErrInvalidEmail          = errors.New("email is not valid")
 	ErrUniqueEmail           = errors.New("email is not unique")
 	ErrAuthenticationFailure = errors.New("authentication failed")
+	ErrNotDeleted            = errors.New("user is not deleted")
+	ErrConflict              = errors.New("user has changed since it was read")
+	ErrUnknownFormat         = errors.New("unknown format")
+	ErrUnknownImportMode     = errors.New("unknown import mode")
+	ErrImportTooLarge        = errors.New("import is too large")
+	ErrUnsupportedPatch      = errors.New("patch media type is not supported")
+	ErrInvalidPatch          = errors.New("patch is not valid")
+	ErrPatchFailed           = errors.New("patch can't be applied to the user")
 )
 
 // Core manages the set of APIs for user access.
 type Core struct {
-	store db.Store
+	log        *zap.SugaredLogger
+	db         *sqlx.DB
+	store      db.Store
+	refresh    refreshdb.Store
+	revocation revocationdb.Store
+	audit      auditdb.Store
 }
 
+// auditEntity names users in the audit log.
+const auditEntity = "user"
+
 // NewCore constructs a core for user api access.
 func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Core {
 	return Core{
-		store: db.NewStore(log, sqlxDB),
+		log:        log,
+		db:         sqlxDB,
+		store:      db.NewStore(log, sqlxDB),
+		refresh:    refreshdb.NewStore(log, sqlxDB),
+		revocation: revocationdb.NewStore(log, sqlxDB),
+		audit:      auditdb.NewStore(log, sqlxDB),
 	}
 }
 
 // Create inserts a new user into the database.
 func (c Core) Create(ctx context.Context, nu NewUser, now time.Time) (User, error) {
+	ctx, span := tracer.Start(ctx, "user.Create")
+	defer span.End()
+
 	if err := validate.Check(nu); err != nil {
 		return User{}, fmt.Errorf("validating data: %w", err)
 	}
 
+	dbUsr, err := newDBUser(nu, now)
+	if err != nil {
+		return User{}, err
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		return c.insert(ctx, tx, dbUsr, now)
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return User{}, err
+	}
+
+	return toUser(dbUsr), nil
+}
+
+// newDBUser constructs the stored form of a new user, hashing its password.
+func newDBUser(nu NewUser, now time.Time) (db.User, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(nu.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return User{}, fmt.Errorf("generating password hash: %w", err)
+		return db.User{}, fmt.Errorf("generating password hash: %w", err)
 	}
 
 	dbUsr := db.User{
@@ -59,89 +108,370 @@ func (c Core) Create(ctx context.Context, nu NewUser, now time.Time) (User, erro
 		Roles:        nu.Roles,
 		DateCreated:  now,
 		DateUpdated:  now,
+		Version:      1,
 	}
 
-	if err := c.store.Create(ctx, dbUsr); err != nil {
+	return dbUsr, nil
+}
+
+// insert adds the user to the database as part of the transaction and
+// records the creation in the audit log.
+func (c Core) insert(ctx context.Context, tx sqlx.ExtContext, dbUsr db.User, now time.Time) error {
+	if err := c.store.Tran(tx).Create(ctx, dbUsr); err != nil {
 		if errors.Is(err, database.ErrDBDuplicatedEntry) {
-			return User{}, fmt.Errorf("create: %w", ErrUniqueEmail)
+			return fmt.Errorf("create: %w", ErrUniqueEmail)
 		}
-		return User{}, fmt.Errorf("create: %w", err)
+		return fmt.Errorf("create: %w", err)
 	}
 
-	return toUser(dbUsr), nil
+	if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionCreate, auditEntity, dbUsr.ID, nil, toUser(dbUsr), now); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	return nil
 }
 
-// Update replaces a user document in the database.
-func (c Core) Update(ctx context.Context, userID string, uu UpdateUser, now time.Time) error {
+// Update replaces a user document in the database and returns the user as
+// updated. Changing the roles or the password of a user revokes the access and
+// refresh tokens already issued to the user. The update fails with
+// ErrConflict if the user is not at the expected version, or changes while
+// it is being updated.
+func (c Core) Update(ctx context.Context, userID string, uu UpdateUser, now time.Time) (User, error) {
+	ctx, span := tracer.Start(ctx, "user.Update")
+	defer span.End()
+
 	if err := validate.CheckID(userID); err != nil {
-		return ErrInvalidID
+		return User{}, ErrInvalidID
 	}
 
 	if err := validate.Check(uu); err != nil {
-		return fmt.Errorf("validating data: %w", err)
+		return User{}, fmt.Errorf("validating data: %w", err)
 	}
 
 	dbUsr, err := c.store.QueryByID(ctx, userID)
 	if err != nil {
 		if errors.Is(err, database.ErrDBNotFound) {
-			return ErrNotFound
+			return User{}, ErrNotFound
 		}
-		return fmt.Errorf("updating user userID[%s]: %w", userID, err)
+		return User{}, fmt.Errorf("updating user userID[%s]: %w", userID, err)
+	}
+	before := toUser(dbUsr)
+
+	if uu.Version != nil && *uu.Version != dbUsr.Version {
+		return User{}, fmt.Errorf("updating user userID[%s] version[%d]: %w", userID, *uu.Version, ErrConflict)
 	}
 
 	if uu.Name != nil {
 		dbUsr.Name = *uu.Name
 	}
 	if uu.Email != nil {
+
+		// A new email has to be verified again.
+		if *uu.Email != dbUsr.Email {
+			dbUsr.EmailVerified = false
+		}
 		dbUsr.Email = *uu.Email
 	}
+	var revoke bool
 	if uu.Roles != nil {
+		revoke = !equalRoles(dbUsr.Roles, uu.Roles)
 		dbUsr.Roles = uu.Roles
 	}
 	if uu.Password != nil {
+		revoke = true
 		pw, err := bcrypt.GenerateFromPassword([]byte(*uu.Password), bcrypt.DefaultCost)
 		if err != nil {
-			return fmt.Errorf("generating password hash: %w", err)
+			return User{}, fmt.Errorf("generating password hash: %w", err)
 		}
 		dbUsr.PasswordHash = pw
 	}
 	dbUsr.DateUpdated = now
 
-	if err := c.store.Update(ctx, dbUsr); err != nil {
-		if errors.Is(err, database.ErrDBDuplicatedEntry) {
-			return fmt.Errorf("updating user userID[%s]: %w", userID, ErrUniqueEmail)
+	tran := func(tx sqlx.ExtContext) error {
+		if err := c.store.Tran(tx).Update(ctx, dbUsr); err != nil {
+			switch {
+			case errors.Is(err, database.ErrDBDuplicatedEntry):
+				return fmt.Errorf("updating user userID[%s]: %w", userID, ErrUniqueEmail)
+
+			// The user was found at this version above, so it changed since.
+			case errors.Is(err, database.ErrDBNotFound):
+				return fmt.Errorf("updating user userID[%s]: %w", userID, ErrConflict)
+			}
+			return fmt.Errorf("update: %w", err)
+		}
+
+		// Refresh tokens are revoked along with the access tokens, otherwise
+		// they could be used to get new access tokens with the old session.
+		if revoke {
+			if err := c.revocation.Tran(tx).Create(ctx, revocation.NewUserRevocation(userID, now)); err != nil {
+				return fmt.Errorf("revoking tokens: %w", err)
+			}
+			if err := c.refresh.Tran(tx).RevokeUser(ctx, userID, now); err != nil {
+				return fmt.Errorf("revoking refresh tokens: %w", err)
+			}
+		}
+
+		after := toUser(dbUsr)
+		after.Version++
+
+		if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionUpdate, auditEntity, userID, before, after, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
 		}
-		return fmt.Errorf("update: %w", err)
+
+		return nil
 	}
 
-	return nil
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return User{}, err
+	}
+	dbUsr.Version++
+
+	return toUser(dbUsr), nil
 }
 
-// Delete removes a user from the database.
-func (c Core) Delete(ctx context.Context, userID string) error {
+// Delete marks a user as deleted and revokes the access and refresh tokens
+// already issued to the user. A deleted user is left out of queries and can't authenticate, but
+// keeps its products and sales until it is purged, so it can be restored.
+func (c Core) Delete(ctx context.Context, userID string, now time.Time) error {
+	ctx, span := tracer.Start(ctx, "user.Delete")
+	defer span.End()
+
 	if err := validate.CheckID(userID); err != nil {
 		return ErrInvalidID
 	}
 
-	if err := c.store.Delete(ctx, userID); err != nil {
-		return fmt.Errorf("delete: %w", err)
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		// Deleting a user that doesn't exist or is already deleted changes
+		// nothing.
+		dbUsr, err := store.QueryByID(ctx, userID)
+		if err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				return nil
+			}
+			return fmt.Errorf("deleting user userID[%s]: %w", userID, err)
+		}
+		before := toUser(dbUsr)
+
+		if err := store.Delete(ctx, userID, now); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+		dbUsr.DateDeleted = &now
+		dbUsr.Version++
+
+		if err := c.revocation.Tran(tx).Create(ctx, revocation.NewUserRevocation(userID, now)); err != nil {
+			return fmt.Errorf("revoking tokens: %w", err)
+		}
+		if err := c.refresh.Tran(tx).RevokeUser(ctx, userID, now); err != nil {
+			return fmt.Errorf("revoking refresh tokens: %w", err)
+		}
+
+		if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionDelete, auditEntity, userID, before, toUser(dbUsr), now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
 	}
 
-	return nil
+	return database.WithinTran(ctx, c.log, c.db, tran)
+}
+
+// Restore undoes the deletion of a user that hasn't been purged yet. The
+// tokens revoked by the deletion stay revoked. Restoring fails with
+// ErrUniqueEmail if another user took the email in the meantime.
+func (c Core) Restore(ctx context.Context, userID string, now time.Time) (User, error) {
+	ctx, span := tracer.Start(ctx, "user.Restore")
+	defer span.End()
+
+	if err := validate.CheckID(userID); err != nil {
+		return User{}, ErrInvalidID
+	}
+
+	var usr User
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		dbUsr, err := store.QueryByIDIncludeDeleted(ctx, userID)
+		if err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("restoring user userID[%s]: %w", userID, err)
+		}
+
+		if dbUsr.DateDeleted == nil {
+			return ErrNotDeleted
+		}
+		before := toUser(dbUsr)
+
+		if err := store.Restore(ctx, userID, now); err != nil {
+			if errors.Is(err, database.ErrDBDuplicatedEntry) {
+				return fmt.Errorf("restoring user userID[%s]: %w", userID, ErrUniqueEmail)
+			}
+			return fmt.Errorf("restore: %w", err)
+		}
+		dbUsr.DateDeleted = nil
+		dbUsr.DateUpdated = now
+		dbUsr.Version++
+		usr = toUser(dbUsr)
+
+		if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionRestore, auditEntity, userID, before, usr, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return User{}, err
+	}
+
+	return usr, nil
+}
+
+// Purge removes the users deleted before the specified time for good, along
+// with their products and the sales of those products. It returns the number
+// of users purged.
+func (c Core) Purge(ctx context.Context, before time.Time, now time.Time) (int, error) {
+	ctx, span := tracer.Start(ctx, "user.Purge")
+	defer span.End()
+
+	var purged int
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		dbUsrs, err := store.QueryDeletedBefore(ctx, before)
+		if err != nil {
+			return fmt.Errorf("purge: %w", err)
+		}
+
+		for _, dbUsr := range dbUsrs {
+			if err := store.Purge(ctx, dbUsr.ID); err != nil {
+				return fmt.Errorf("purge: %w", err)
+			}
+
+			if err := audit.Record(ctx, c.audit.Tran(tx), audit.ActionPurge, auditEntity, dbUsr.ID, toUser(dbUsr), nil, now); err != nil {
+				return fmt.Errorf("audit: %w", err)
+			}
+		}
+		purged = len(dbUsrs)
+
+		return nil
+	}
+
+	if err := database.WithinTran(ctx, c.log, c.db, tran); err != nil {
+		return 0, err
+	}
+
+	return purged, nil
 }
 
-// Query retrieves a list of existing users from the database.
-func (c Core) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]User, error) {
-	dbUsers, err := c.store.Query(ctx, pageNumber, rowsPerPage)
+// Query retrieves a page of existing users from the database using offset
+// paging. The result carries cursors that can be used with QueryByCursor to
+// move to the neighbouring pages.
+func (c Core) Query(ctx context.Context, filter QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) (QueryResult, error) {
+	ctx, span := tracer.Start(ctx, "user.Query")
+	defer span.End()
+
+	if err := validate.Check(filter); err != nil {
+		return QueryResult{}, fmt.Errorf("validating filter: %w", err)
+	}
+
+	column, err := orderByColumn(orderBy)
 	if err != nil {
-		return nil, fmt.Errorf("query: %w", err)
+		return QueryResult{}, err
 	}
 
-	return toUserSlice(dbUsers), nil
+	dbFilter := db.QueryFilter(filter)
+
+	dbUsers, err := c.store.Query(ctx, dbFilter, order.NewBy(column, orderBy.Direction), pageNumber, rowsPerPage)
+	if err != nil {
+		return QueryResult{}, fmt.Errorf("query: %w", err)
+	}
+
+	total, err := c.store.Count(ctx, dbFilter)
+	if err != nil {
+		return QueryResult{}, fmt.Errorf("count: %w", err)
+	}
+
+	hasNext := pageNumber*rowsPerPage < total
+	hasPrev := pageNumber > 1
+
+	return newQueryResult(dbUsers, total, orderBy, hasNext, hasPrev), nil
+}
+
+// QueryByCursor retrieves a page of existing users from the database that are
+// positioned after (or before) the specified cursor. The order of the results
+// is the one that was in place when the cursor was created.
+func (c Core) QueryByCursor(ctx context.Context, filter QueryFilter, cursor string, rowsPerPage int) (QueryResult, error) {
+	ctx, span := tracer.Start(ctx, "user.QueryByCursor")
+	defer span.End()
+
+	if err := validate.Check(filter); err != nil {
+		return QueryResult{}, fmt.Errorf("validating filter: %w", err)
+	}
+
+	cur, err := paging.Decode(cursor)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	if err := validate.CheckID(cur.ID); err != nil {
+		return QueryResult{}, fmt.Errorf("cursor id: %w", paging.ErrInvalidCursor)
+	}
+
+	orderBy := order.NewBy(cur.Field, cur.Direction)
+	column, err := orderByColumn(orderBy)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	// To move backwards we seek in the reverse order and flip the results.
+	seekOrder := orderBy
+	if cur.Prev {
+		seekOrder = orderBy.Reverse()
+	}
+
+	dbFilter := db.QueryFilter(filter)
+	key := db.Keyset{
+		Value: cur.Value,
+		ID:    cur.ID,
+	}
+
+	// Ask for one extra row to find out if there is more data to page to.
+	dbUsers, err := c.store.QueryKeyset(ctx, dbFilter, order.NewBy(column, seekOrder.Direction), key, rowsPerPage+1)
+	if err != nil {
+		return QueryResult{}, fmt.Errorf("query: %w", err)
+	}
+
+	hasMore := len(dbUsers) > rowsPerPage
+	if hasMore {
+		dbUsers = dbUsers[:rowsPerPage]
+	}
+
+	if cur.Prev {
+		for i, j := 0, len(dbUsers)-1; i < j; i, j = i+1, j-1 {
+			dbUsers[i], dbUsers[j] = dbUsers[j], dbUsers[i]
+		}
+	}
+
+	total, err := c.store.Count(ctx, dbFilter)
+	if err != nil {
+		return QueryResult{}, fmt.Errorf("count: %w", err)
+	}
+
+	if cur.Prev {
+		return newQueryResult(dbUsers, total, orderBy, true, hasMore), nil
+	}
+	return newQueryResult(dbUsers, total, orderBy, hasMore, true), nil
 }
 
 // QueryByID gets the specified user from the database.
 func (c Core) QueryByID(ctx context.Context, userID string) (User, error) {
+	ctx, span := tracer.Start(ctx, "user.QueryByID")
+	defer span.End()
+
 	if err := validate.CheckID(userID); err != nil {
 		return User{}, ErrInvalidID
 	}
@@ -157,8 +487,31 @@ func (c Core) QueryByID(ctx context.Context, userID string) (User, error) {
 	return toUser(dbUsr), nil
 }
 
+// QueryByIDIncludeDeleted gets the specified user from the database, even
+// when the user is deleted.
+func (c Core) QueryByIDIncludeDeleted(ctx context.Context, userID string) (User, error) {
+	ctx, span := tracer.Start(ctx, "user.QueryByIDIncludeDeleted")
+	defer span.End()
+
+	if err := validate.CheckID(userID); err != nil {
+		return User{}, ErrInvalidID
+	}
+
+	dbUsr, err := c.store.QueryByIDIncludeDeleted(ctx, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return User{}, ErrNotFound
+		}
+		return User{}, fmt.Errorf("query: %w", err)
+	}
+
+	return toUser(dbUsr), nil
+}
+
 // QueryByEmail gets the specified user from the database by email.
 func (c Core) QueryByEmail(ctx context.Context, email string) (User, error) {
+	ctx, span := tracer.Start(ctx, "user.QueryByEmail")
+	defer span.End()
 
 	// Email Validate function in validate.
 	if !validate.CheckEmail(email) {
@@ -176,14 +529,24 @@ func (c Core) QueryByEmail(ctx context.Context, email string) (User, error) {
 	return toUser(dbUsr), nil
 }
 
+// dummyHash is compared against when the user doesn't exist so failing to
+// authenticate takes the same time whether the email exists or not.
+var dummyHash = []byte("$2a$10$5.uH46WtUJZ5gAm7/KEXUOM0wbn5GG8MJKHxeW8mTxSQhIrmfmSre")
+
 // Authenticate finds a user by their email and verifies their password. On
 // success it returns a Claims User representing this user. The claims can be
-// used to generate a token for future authentication.
+// used to generate a token for future authentication. An unknown email and a
+// wrong password both fail with ErrAuthenticationFailure so callers can't
+// tell which emails exist.
 func (c Core) Authenticate(ctx context.Context, now time.Time, email, password string) (auth.Claims, error) {
+	ctx, span := tracer.Start(ctx, "user.Authenticate")
+	defer span.End()
+
 	dbUsr, err := c.store.QueryByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, database.ErrDBNotFound) {
-			return auth.Claims{}, ErrNotFound
+			bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+			return auth.Claims{}, ErrAuthenticationFailure
 		}
 		return auth.Claims{}, fmt.Errorf("query: %w", err)
 	}
@@ -196,15 +559,41 @@ func (c Core) Authenticate(ctx context.Context, now time.Time, email, password s
 
 	// If we are this far the request is valid. Create some claims for the user
 	// and generate their token.
-	claims := auth.Claims{
+	return newClaims(dbUsr, now), nil
+}
+
+// Claims returns a fresh set of claims for the specified user. This is used
+// to issue a new token for a user that has already been authenticated by
+// other means, like a refresh token.
+func (c Core) Claims(ctx context.Context, userID string, now time.Time) (auth.Claims, error) {
+	ctx, span := tracer.Start(ctx, "user.Claims")
+	defer span.End()
+
+	if err := validate.CheckID(userID); err != nil {
+		return auth.Claims{}, ErrInvalidID
+	}
+
+	dbUsr, err := c.store.QueryByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return auth.Claims{}, ErrNotFound
+		}
+		return auth.Claims{}, fmt.Errorf("query: %w", err)
+	}
+
+	return newClaims(dbUsr, now), nil
+}
+
+// newClaims constructs the claims representing the user.
+func newClaims(dbUsr db.User, now time.Time) auth.Claims {
+	return auth.Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject:   dbUsr.ID,
 			Issuer:    "service project",
-			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ID:        validate.GenerateID(),
+			ExpiresAt: jwt.NewNumericDate(now.UTC().Add(auth.TokenLifetime)),
+			IssuedAt:  jwt.NewNumericDate(now.UTC()),
 		},
 		Roles: dbUsr.Roles,
 	}
-
-	return claims, nil
 }
@@ -1,15 +1,20 @@
 package user_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ardanlabs/service/business/core/refresh"
 	"github.com/ardanlabs/service/business/core/user"
 	"github.com/ardanlabs/service/business/data/dbtest"
 	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/order"
+	"github.com/ardanlabs/service/business/sys/validate"
 	"github.com/ardanlabs/service/foundation/docker"
 	"github.com/google/go-cmp/cmp"
 )
@@ -72,11 +77,26 @@ func TestUser(t *testing.T) {
 				Email: dbtest.StringPointer("jacob@ardanlabs.com"),
 			}
 
-			if err := core.Update(ctx, usr.ID, upd, now); err != nil {
+			updated, err := core.Update(ctx, usr.ID, upd, now)
+			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to update user : %s.", dbtest.Failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to update user.", dbtest.Success, testID)
 
+			if updated.Version != usr.Version+1 {
+				t.Fatalf("\t%s\tTest %d:\tShould increment the version : %d.", dbtest.Failed, testID, updated.Version)
+			}
+			t.Logf("\t%s\tTest %d:\tShould increment the version.", dbtest.Success, testID)
+
+			stale := user.UpdateUser{
+				Name:    dbtest.StringPointer("Bill Kennedy"),
+				Version: &usr.Version,
+			}
+			if _, err := core.Update(ctx, usr.ID, stale, now); !errors.Is(err, user.ErrConflict) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to update a stale version : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to update a stale version.", dbtest.Success, testID)
+
 			saved, err = core.QueryByEmail(ctx, *upd.Email)
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user by Email : %s.", dbtest.Failed, testID, err)
@@ -99,7 +119,55 @@ func TestUser(t *testing.T) {
 				t.Logf("\t%s\tTest %d:\tShould be able to see updates to Email.", dbtest.Success, testID)
 			}
 
-			if err := core.Delete(ctx, usr.ID); err != nil {
+			merge := user.Patch{
+				Type:     user.PatchMerge,
+				Document: []byte(`{"name":"Jack Walker"}`),
+			}
+			patched, err := core.Patch(ctx, usr.ID, merge, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to merge patch user : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to merge patch user.", dbtest.Success, testID)
+
+			if patched.Name != "Jack Walker" || patched.Email != *upd.Email || patched.Version != updated.Version+1 {
+				t.Fatalf("\t%s\tTest %d:\tShould only change the patched name : %+v.", dbtest.Failed, testID, patched)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only change the patched name.", dbtest.Success, testID)
+
+			jsonPatch := user.Patch{
+				Type:     user.PatchJSON,
+				Document: []byte(`[{"op":"test","path":"/name","value":"Jack Walker"},{"op":"add","path":"/roles/-","value":"USER"}]`),
+			}
+			patched, err = core.Patch(ctx, usr.ID, jsonPatch, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to JSON patch user : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to JSON patch user.", dbtest.Success, testID)
+
+			if diff := cmp.Diff([]string{auth.RoleAdmin, "USER"}, patched.Roles); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould add the patched role. Diff:\n%s", dbtest.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould add the patched role.", dbtest.Success, testID)
+
+			clearEmail := user.Patch{
+				Type:     user.PatchMerge,
+				Document: []byte(`{"email":null}`),
+			}
+			if _, err := core.Patch(ctx, usr.ID, clearEmail, now); !validate.IsFieldErrors(err) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to clear the email : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to clear the email.", dbtest.Success, testID)
+
+			readOnly := user.Patch{
+				Type:     user.PatchJSON,
+				Document: []byte(`[{"op":"replace","path":"/email_verified","value":true}]`),
+			}
+			if _, err := core.Patch(ctx, usr.ID, readOnly, now); !errors.Is(err, user.ErrPatchFailed) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to patch a read only field : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to patch a read only field.", dbtest.Success, testID)
+
+			if err := core.Delete(ctx, usr.ID, now); err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to delete user : %s.", dbtest.Failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to delete user.", dbtest.Success, testID)
@@ -109,6 +177,316 @@ func TestUser(t *testing.T) {
 				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve user : %s.", dbtest.Failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve user.", dbtest.Success, testID)
+
+			deleted, err := core.QueryByIDIncludeDeleted(ctx, usr.ID)
+			if err != nil || deleted.DateDeleted == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the deleted user : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve the deleted user.", dbtest.Success, testID)
+
+			if _, err := core.Restore(ctx, usr.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to restore user : %s.", dbtest.Failed, testID, err)
+			}
+
+			if _, err := core.QueryByID(ctx, usr.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the restored user : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to restore user.", dbtest.Success, testID)
+
+			if _, err := core.Restore(ctx, usr.ID, now); !errors.Is(err, user.ErrNotDeleted) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to restore a user that isn't deleted : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to restore a user that isn't deleted.", dbtest.Success, testID)
+
+			if err := core.Delete(ctx, usr.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete user : %s.", dbtest.Failed, testID, err)
+			}
+
+			if purged, err := core.Purge(ctx, now, now); err != nil || purged != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould keep users within the retention period : %v %d.", dbtest.Failed, testID, err, purged)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep users within the retention period.", dbtest.Success, testID)
+
+			if purged, err := core.Purge(ctx, now.Add(time.Second), now); err != nil || purged != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould purge users after the retention period : %v %d.", dbtest.Failed, testID, err, purged)
+			}
+
+			if _, err := core.QueryByIDIncludeDeleted(ctx, usr.ID); !errors.Is(err, user.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve a purged user : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould purge users after the retention period.", dbtest.Success, testID)
+		}
+	}
+}
+
+func TestRevokeSessions(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testrevokesessions")
+	t.Cleanup(teardown)
+
+	core := user.NewCore(log, db)
+	rtCore := refresh.NewCore(log, db, time.Hour)
+
+	t.Log("Given the need to end the sessions of a user whose credentials change.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the password of a user changes.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+			nu := user.NewUser{
+				Name:            "Bill Kennedy",
+				Email:           "bill@ardanlabs.com",
+				Roles:           []string{auth.RoleUser},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+
+			usr, err := core.Create(ctx, nu, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", dbtest.Failed, testID, err)
+			}
+
+			token, err := rtCore.Create(ctx, usr.ID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a refresh token : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a refresh token.", dbtest.Success, testID)
+
+			upd := user.UpdateUser{
+				Password:        dbtest.StringPointer("new-gophers"),
+				PasswordConfirm: dbtest.StringPointer("new-gophers"),
+			}
+			if _, err := core.Update(ctx, usr.ID, upd, now.Add(time.Minute)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to change the password : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to change the password.", dbtest.Success, testID)
+
+			if _, _, err := rtCore.Rotate(ctx, token, now.Add(2*time.Minute)); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to rotate the old refresh token.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to rotate the old refresh token.", dbtest.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a user is deleted and restored.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2018, time.October, 2, 0, 0, 0, 0, time.UTC)
+
+			nu := user.NewUser{
+				Name:            "Jacob Walker",
+				Email:           "jacob@ardanlabs.com",
+				Roles:           []string{auth.RoleUser},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+
+			usr, err := core.Create(ctx, nu, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", dbtest.Failed, testID, err)
+			}
+
+			token, err := rtCore.Create(ctx, usr.ID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a refresh token : %s.", dbtest.Failed, testID, err)
+			}
+
+			if err := core.Delete(ctx, usr.ID, now.Add(time.Minute)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete the user : %s.", dbtest.Failed, testID, err)
+			}
+			if _, err := core.Restore(ctx, usr.ID, now.Add(2*time.Minute)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to restore the user : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete and restore the user.", dbtest.Success, testID)
+
+			if _, _, err := rtCore.Rotate(ctx, token, now.Add(3*time.Minute)); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to rotate a refresh token issued before the deletion.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to rotate a refresh token issued before the deletion.", dbtest.Success, testID)
+		}
+	}
+}
+
+func TestPaging(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testpaging")
+	t.Cleanup(teardown)
+
+	core := user.NewCore(log, db)
+
+	t.Log("Given the need to page through User records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen paging the seeded Users.", testID)
+		{
+			ctx := context.Background()
+			orderBy := order.NewBy(user.OrderByName, order.ASC)
+
+			first, err := core.Query(ctx, user.QueryFilter{}, orderBy, 1, 1)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the first page : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve the first page.", dbtest.Success, testID)
+
+			if len(first.Items) != 1 || first.Total != 2 || first.NextCursor == "" || first.PrevCursor != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get one user, a total of two and only a next cursor : %+v.", dbtest.Failed, testID, first)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get one user, a total of two and only a next cursor.", dbtest.Success, testID)
+
+			second, err := core.QueryByCursor(ctx, user.QueryFilter{}, first.NextCursor, 1)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the next page : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve the next page.", dbtest.Success, testID)
+
+			if len(second.Items) != 1 || second.Items[0].Name != "User Gopher" || second.NextCursor != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the last user with no next cursor : %+v.", dbtest.Failed, testID, second)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the last user with no next cursor.", dbtest.Success, testID)
+
+			back, err := core.QueryByCursor(ctx, user.QueryFilter{}, second.PrevCursor, 1)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the previous page : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve the previous page.", dbtest.Success, testID)
+
+			if diff := cmp.Diff(first.Items, back.Items); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the first page. Diff:\n%s", dbtest.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the first page.", dbtest.Success, testID)
+
+			role := auth.RoleAdmin
+			admins, err := core.Query(ctx, user.QueryFilter{Role: &role}, user.DefaultOrderBy, 1, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to filter by role : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to filter by role.", dbtest.Success, testID)
+
+			if admins.Total != 1 || len(admins.Items) != 1 || admins.Items[0].Email != "admin@example.com" {
+				t.Fatalf("\t%s\tTest %d:\tShould only get the admin user : %+v.", dbtest.Failed, testID, admins)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only get the admin user.", dbtest.Success, testID)
+
+			wildcard := "%"
+			named, err := core.Query(ctx, user.QueryFilter{Name: &wildcard}, user.DefaultOrderBy, 1, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to filter by name : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to filter by name.", dbtest.Success, testID)
+
+			if named.Total != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould match a wildcard in a name literally : %+v.", dbtest.Failed, testID, named)
+			}
+			t.Logf("\t%s\tTest %d:\tShould match a wildcard in a name literally.", dbtest.Success, testID)
+
+			_, err = core.Query(ctx, user.QueryFilter{}, order.NewBy("password_hash", order.ASC), 1, 10)
+			if !errors.Is(err, order.ErrInvalidOrder) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to order by a field outside the whitelist : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to order by a field outside the whitelist.", dbtest.Success, testID)
+		}
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testimport")
+	t.Cleanup(teardown)
+
+	core := user.NewCore(log, db)
+
+	t.Log("Given the need to import and export User records in bulk.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen importing Users next to the seeded ones.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+			const doc = "name,email,roles,password\n" +
+				"Jacob Walker,jacob@ardanlabs.com,USER;ADMIN,gophers\n" +
+				"No Email,not-an-email,USER,gophers\n" +
+				"Admin Again,admin@example.com,ADMIN,gophers\n"
+
+			statuses := func(result user.ImportResult) []string {
+				var s []string
+				for _, row := range result.Rows {
+					s = append(s, row.Status)
+				}
+				return s
+			}
+
+			opts := user.ImportOptions{Format: user.FormatCSV, Mode: user.ImportAtomic}
+			result, err := core.Import(ctx, strings.NewReader(doc), opts, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to run an atomic import : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to run an atomic import.", dbtest.Success, testID)
+
+			exp := []string{user.RowSkipped, user.RowInvalid, user.RowFailed}
+			if diff := cmp.Diff(exp, statuses(result)); diff != "" || result.Created != 0 || result.Failed != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the whole atomic import : %+v.", dbtest.Failed, testID, result)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the whole atomic import.", dbtest.Success, testID)
+
+			opts = user.ImportOptions{Format: user.FormatCSV, Mode: user.ImportBestEffort, DryRun: true}
+			result, err = core.Import(ctx, strings.NewReader(doc), opts, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to run a dry run : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to run a dry run.", dbtest.Success, testID)
+
+			exp = []string{user.RowValid, user.RowInvalid, user.RowFailed}
+			if diff := cmp.Diff(exp, statuses(result)); diff != "" || result.Created != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould only check the rows on a dry run : %+v.", dbtest.Failed, testID, result)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only check the rows on a dry run.", dbtest.Success, testID)
+
+			opts.DryRun = false
+			result, err = core.Import(ctx, strings.NewReader(doc), opts, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to run a best effort import : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to run a best effort import.", dbtest.Success, testID)
+
+			if result.Created != 1 || result.Rows[0].Status != user.RowCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould create the valid user : %+v.", dbtest.Failed, testID, result)
+			}
+			t.Logf("\t%s\tTest %d:\tShould create the valid user.", dbtest.Success, testID)
+
+			if _, err := core.QueryByID(ctx, result.Rows[0].ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the imported user : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve the imported user.", dbtest.Success, testID)
+
+			var buf bytes.Buffer
+			n, err := core.Export(ctx, &buf, user.FormatNDJSON, user.QueryFilter{})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to export users : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to export users.", dbtest.Success, testID)
+
+			if lines := strings.Count(buf.String(), "\n"); n != 3 || lines != 3 {
+				t.Fatalf("\t%s\tTest %d:\tShould export a line for each of the 3 users : %d users, %d lines.", dbtest.Failed, testID, n, lines)
+			}
+			t.Logf("\t%s\tTest %d:\tShould export a line for each of the 3 users.", dbtest.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen importing more rows than a single import allows.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+			var doc strings.Builder
+			for i := 0; i < 1000; i++ {
+				fmt.Fprintf(&doc, `{"name":"User %d","email":"user%d@example.com","roles":["USER"],"password":"gophers"}`+"\n", i, i)
+			}
+
+			opts := user.ImportOptions{Format: user.FormatNDJSON, Mode: user.ImportAtomic}
+			if _, err := core.Import(ctx, strings.NewReader(doc.String()), opts, now); !errors.Is(err, user.ErrImportTooLarge) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the import as too large : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the import as too large.", dbtest.Success, testID)
 		}
 	}
 }
@@ -1,3 +1,13 @@
+DELETE FROM key_rotations;
+DELETE FROM rate_limits;
+DELETE FROM audit_log;
+DELETE FROM api_keys;
+DELETE FROM user_tokens;
+DELETE FROM mfa_challenges;
+DELETE FROM mfa_recovery_codes;
+DELETE FROM mfa_secrets;
+DELETE FROM revocations;
+DELETE FROM refresh_tokens;
 DELETE FROM sales;
 DELETE FROM products;
 DELETE FROM users;
\ No newline at end of file
@@ -41,3 +41,175 @@ CREATE TABLE sales (
 	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
 	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
 );
+
+-- Version: 1.4
+-- Description: Create table refresh_tokens
+CREATE TABLE refresh_tokens (
+	token_id     UUID,
+	family_id    UUID,
+	user_id      UUID,
+	token_hash   TEXT UNIQUE,
+	date_created TIMESTAMP,
+	date_expires TIMESTAMP,
+	date_revoked TIMESTAMP NULL,
+	replaced_by  UUID NULL,
+
+	PRIMARY KEY (token_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Version: 1.5
+-- Description: Create table revocations
+CREATE TABLE revocations (
+	revocation_id UUID,
+	token_id      TEXT NULL,
+	user_id       UUID NULL,
+	date_revoked  TIMESTAMP,
+	date_expires  TIMESTAMP,
+
+	PRIMARY KEY (revocation_id)
+);
+
+-- Version: 1.6
+-- Description: Create table mfa_secrets
+CREATE TABLE mfa_secrets (
+	user_id      UUID,
+	secret       TEXT,
+	enabled      BOOLEAN,
+	last_step    BIGINT,
+	date_created TIMESTAMP,
+	date_updated TIMESTAMP,
+
+	PRIMARY KEY (user_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Version: 1.7
+-- Description: Create table mfa_recovery_codes
+CREATE TABLE mfa_recovery_codes (
+	code_id      UUID,
+	user_id      UUID,
+	code_hash    TEXT,
+	date_created TIMESTAMP,
+	date_used    TIMESTAMP NULL,
+
+	PRIMARY KEY (code_id),
+	UNIQUE (user_id, code_hash),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Version: 1.8
+-- Description: Create table mfa_challenges
+CREATE TABLE mfa_challenges (
+	challenge_id   UUID,
+	user_id        UUID,
+	challenge_hash TEXT UNIQUE,
+	attempts       INT,
+	date_created   TIMESTAMP,
+	date_expires   TIMESTAMP,
+	date_used      TIMESTAMP NULL,
+
+	PRIMARY KEY (challenge_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Version: 1.9
+-- Description: Add column email_verified to users
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN DEFAULT FALSE;
+
+-- Version: 2.0
+-- Description: Create table user_tokens
+CREATE TABLE user_tokens (
+	token_id     UUID,
+	user_id      UUID,
+	purpose      TEXT,
+	date_created TIMESTAMP,
+	date_expires TIMESTAMP,
+	date_used    TIMESTAMP NULL,
+
+	PRIMARY KEY (token_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Version: 2.1
+-- Description: Create table api_keys
+CREATE TABLE api_keys (
+	key_id         UUID,
+	user_id        UUID,
+	name           TEXT,
+	prefix         TEXT UNIQUE,
+	key_hash       TEXT,
+	scopes         TEXT[],
+	date_created   TIMESTAMP,
+	date_expires   TIMESTAMP NULL,
+	date_last_used TIMESTAMP NULL,
+	date_revoked   TIMESTAMP NULL,
+
+	PRIMARY KEY (key_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Version: 2.2
+-- Description: Create table roles with the default permissions
+CREATE TABLE roles (
+	role         TEXT,
+	permissions  TEXT[],
+	date_created TIMESTAMP,
+	date_updated TIMESTAMP,
+
+	PRIMARY KEY (role)
+);
+INSERT INTO roles (role, permissions, date_created, date_updated) VALUES
+	('ADMIN', '{users:read,users:write,products:read,products:write,products:manage,sales:read,sales:write,roles:read,roles:write,tokens:revoke,apikeys:manage}', NOW(), NOW()),
+	('USER', '{products:read,products:write,sales:write}', NOW(), NOW());
+
+-- Version: 2.3
+-- Description: Create table audit_log and grant audit:read to admins
+CREATE TABLE audit_log (
+	audit_id     UUID,
+	actor_id     TEXT,
+	action       TEXT,
+	entity_type  TEXT,
+	entity_id    TEXT,
+	diff         JSONB,
+	trace_id     TEXT,
+	date_created TIMESTAMP,
+
+	PRIMARY KEY (audit_id)
+);
+CREATE INDEX audit_log_entity_idx ON audit_log (entity_type, entity_id, date_created);
+CREATE INDEX audit_log_actor_idx ON audit_log (actor_id, date_created);
+UPDATE roles SET permissions = array_append(permissions, 'audit:read') WHERE role = 'ADMIN';
+
+-- Version: 2.4
+-- Description: Add column date_deleted to users and keep emails unique among live users
+ALTER TABLE users ADD COLUMN date_deleted TIMESTAMP NULL;
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+CREATE UNIQUE INDEX users_email_idx ON users (email) WHERE date_deleted IS NULL;
+
+-- Version: 2.5
+-- Description: Add column version to users
+ALTER TABLE users ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+-- Version: 2.6
+-- Description: Create table rate_limits
+CREATE TABLE rate_limits (
+	rate_key     TEXT,
+	tokens       DOUBLE PRECISION,
+	allowed      BOOLEAN,
+	date_updated TIMESTAMP,
+
+	PRIMARY KEY (rate_key)
+);
+
+-- Version: 2.7
+-- Description: Create table key_rotations
+CREATE TABLE key_rotations (
+	kid           TEXT,
+	active        BOOLEAN,
+	retired_until TIMESTAMP NULL,
+	date_updated  TIMESTAMP,
+
+	PRIMARY KEY (kid)
+);
+CREATE UNIQUE INDEX key_rotations_active_idx ON key_rotations (active) WHERE active;
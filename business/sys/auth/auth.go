@@ -2,29 +2,66 @@
 package auth
 
 import (
-	"crypto/rsa"
+	"context"
+	"crypto"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-// ErrForbidden is returned when a auth issue is identified.
-var ErrForbidden = errors.New("attempted action is not allowed")
+// Set of error variables for authentication and authorization.
+var (
+	ErrForbidden     = errors.New("attempted action is not allowed")
+	ErrInvalidAPIKey = errors.New("api key is not valid")
+)
+
+// TokenLifetime is how long the tokens issued to users are valid for.
+const TokenLifetime = time.Hour
+
+// algorithms is the set of signing algorithms supported for tokens.
+var algorithms = []string{"RS256", "ES256", "ES384", "ES512", "EdDSA"}
+
+func init() {
+
+	// Times in tokens default to a precision of a second, which can't tell a
+	// token issued right after the tokens of its user were revoked from the
+	// tokens that were revoked.
+	jwt.TimePrecision = time.Microsecond
+}
 
 // KeyLookup declares a method set of behavior for looking up
-// private and public keys for JWT use.
+// private and public keys for JWT use. Each key declares the signing
+// algorithm it must be used with.
 type KeyLookup interface {
-	PrivateKey(kid string) (*rsa.PrivateKey, error)
-	PublicKey(kid string) (*rsa.PublicKey, error)
+	PrivateKey(kid string) (crypto.Signer, error)
+	PublicKey(kid string) (crypto.PublicKey, error)
+	Algorithm(kid string) (string, error)
+	KIDs() []string
+}
+
+// Revoker declares the behavior for checking if the claims of an otherwise
+// valid token have been revoked.
+type Revoker interface {
+	Revoked(claims Claims) bool
+}
+
+// APIKeyLookup declares the behavior for resolving an API key to the claims
+// of the user that owns it. Keys that don't resolve fail with
+// ErrInvalidAPIKey.
+type APIKeyLookup interface {
+	ClaimsByAPIKey(ctx context.Context, key string, now time.Time) (Claims, error)
 }
 
 // Auth is used to authenticate clients. It can generate a token for a
 // set of user claims and recreate the claims by parsing the token.
 type Auth struct {
+	mu        sync.RWMutex
 	activeKID string
+	retired   map[string]time.Time
 	keyLookup KeyLookup
-	method    jwt.SigningMethod
 	keyFunc   func(t *jwt.Token) (interface{}, error)
 	parser    *jwt.Parser
 }
@@ -33,17 +70,23 @@ type Auth struct {
 func New(activeKID string, keyLookup KeyLookup) (*Auth, error) {
 
 	// The activeKID represents the private key used to signed new tokens.
-	_, err := keyLookup.PrivateKey(activeKID)
-	if err != nil {
-		return nil, errors.New("active KID does not exist in store")
+	if _, err := signingMethod(keyLookup, activeKID); err != nil {
+		return nil, err
 	}
 
-	method := jwt.GetSigningMethod("RS256")
-	if method == nil {
-		return nil, errors.New("configuring algorithm RS256")
+	// Create the token parser to use. The algorithm used to sign the JWT must be
+	// validated to avoid a critical vulnerability:
+	// https://auth0.com/blog/critical-vulnerabilities-in-json-web-token-libraries/
+	parser := jwt.NewParser(jwt.WithValidMethods(algorithms))
+
+	a := Auth{
+		activeKID: activeKID,
+		retired:   make(map[string]time.Time),
+		keyLookup: keyLookup,
+		parser:    parser,
 	}
 
-	keyFunc := func(t *jwt.Token) (interface{}, error) {
+	a.keyFunc = func(t *jwt.Token) (interface{}, error) {
 		kid, ok := t.Header["kid"]
 		if !ok {
 			return nil, errors.New("missing key id (kid) in token header")
@@ -52,31 +95,125 @@ func New(activeKID string, keyLookup KeyLookup) (*Auth, error) {
 		if !ok {
 			return nil, errors.New("user token key id (kid) must be string")
 		}
+		if !a.verifies(kidID, time.Now()) {
+			return nil, errors.New("key id (kid) has been retired")
+		}
+
+		// The token must be signed with the algorithm of the key, otherwise
+		// a key could be used with an algorithm it was not meant for.
+		alg, err := keyLookup.Algorithm(kidID)
+		if err != nil {
+			return nil, err
+		}
+		if t.Method.Alg() != alg {
+			return nil, fmt.Errorf("token algorithm %s does not match key algorithm %s", t.Method.Alg(), alg)
+		}
+
 		return keyLookup.PublicKey(kidID)
 	}
 
-	// Create the token parser to use. The algorithm used to sign the JWT must be
-	// validated to avoid a critical vulnerability:
-	// https://auth0.com/blog/critical-vulnerabilities-in-json-web-token-libraries/
-	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256"}))
+	return &a, nil
+}
 
-	a := Auth{
-		activeKID: activeKID,
-		keyLookup: keyLookup,
-		method:    method,
-		keyFunc:   keyFunc,
-		parser:    parser,
+// ActiveKID returns the key id of the private key used to sign new tokens.
+func (a *Auth) ActiveKID() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.activeKID
+}
+
+// SetActiveKID changes the private key used to sign new tokens. The key that
+// was active until now is retired: it is no longer used for signing but
+// tokens it signed are still accepted for the specified grace period, which
+// should be at least as long as the lifetime of a token.
+func (a *Auth) SetActiveKID(kid string, grace time.Duration) error {
+	if _, err := signingMethod(a.keyLookup, kid); err != nil {
+		return err
 	}
 
-	return &a, nil
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if kid == a.activeKID {
+		return nil
+	}
+
+	a.retired[a.activeKID] = time.Now().Add(grace)
+	delete(a.retired, kid)
+	a.activeKID = kid
+
+	return nil
+}
+
+// Rotation represents the key used to sign new tokens and the time until
+// which each retired key still verifies tokens.
+type Rotation struct {
+	ActiveKID string
+	Retired   map[string]time.Time
+}
+
+// Rotation returns the current rotation of the keys.
+func (a *Auth) Rotation() Rotation {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	retired := make(map[string]time.Time, len(a.retired))
+	for kid, until := range a.retired {
+		retired[kid] = until
+	}
+
+	return Rotation{
+		ActiveKID: a.activeKID,
+		Retired:   retired,
+	}
+}
+
+// SetRotation replaces the rotation of the keys, so every instance of the
+// service sharing a rotation signs and verifies tokens with the same keys.
+func (a *Auth) SetRotation(rot Rotation) error {
+	if _, err := signingMethod(a.keyLookup, rot.ActiveKID); err != nil {
+		return err
+	}
+
+	retired := make(map[string]time.Time, len(rot.Retired))
+	for kid, until := range rot.Retired {
+		retired[kid] = until
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.activeKID = rot.ActiveKID
+	a.retired = retired
+
+	return nil
+}
+
+// verifies reports if tokens signed by the specified key are still accepted.
+// Any key in the store verifies tokens unless it was retired and its grace
+// period is over.
+func (a *Auth) verifies(kid string, now time.Time) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	until, retired := a.retired[kid]
+	return !retired || now.Before(until)
 }
 
 // GenerateToken generates a signed JWT token string representing the user Claims.
 func (a *Auth) GenerateToken(claims Claims) (string, error) {
-	token := jwt.NewWithClaims(a.method, claims)
-	token.Header["kid"] = a.activeKID
+	activeKID := a.ActiveKID()
+
+	method, err := signingMethod(a.keyLookup, activeKID)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = activeKID
 
-	privateKey, err := a.keyLookup.PrivateKey(a.activeKID)
+	privateKey, err := a.keyLookup.PrivateKey(activeKID)
 	if err != nil {
 		return "", errors.New("kid lookup failed")
 	}
@@ -104,3 +241,23 @@ func (a *Auth) ValidateToken(tokenStr string) (Claims, error) {
 
 	return claims, nil
 }
+
+// signingMethod returns the signing method to use with the private key of
+// the specified kid.
+func signingMethod(keyLookup KeyLookup, kid string) (jwt.SigningMethod, error) {
+	if _, err := keyLookup.PrivateKey(kid); err != nil {
+		return nil, errors.New("active KID does not exist in store")
+	}
+
+	alg, err := keyLookup.Algorithm(kid)
+	if err != nil {
+		return nil, fmt.Errorf("kid[%s] algorithm: %w", kid, err)
+	}
+
+	method := jwt.GetSigningMethod(alg)
+	if method == nil {
+		return nil, fmt.Errorf("configuring algorithm %s", alg)
+	}
+
+	return method, nil
+}
@@ -0,0 +1,296 @@
+package auth_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/foundation/keystore"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestAuth(t *testing.T) {
+	t.Log("Given the need to be able to authenticate and authorize access.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single user.", testID)
+		{
+			const keyID = "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
+			privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a private key: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a private key.", success, testID)
+
+			a, err := auth.New(keyID, keystore.NewMap(map[string]crypto.Signer{keyID: privateKey}))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create an authenticator.", success, testID)
+
+			claims := auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer:    "service project",
+					Subject:   "5cf37266-3473-4006-984f-9325122678b7",
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(8760 * time.Hour)),
+					IssuedAt:  jwt.NewNumericDate(time.Now()),
+				},
+				Roles: []string{auth.RoleAdmin},
+			}
+
+			token, err := a.GenerateToken(claims)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a JWT: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to generate a JWT.", success, testID)
+
+			parsedClaims, err := a.ValidateToken(token)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to parse the claims: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to parse the claims.", success, testID)
+
+			if exp, got := len(claims.Roles), len(parsedClaims.Roles); exp != got {
+				t.Logf("\t\tTest %d:\texp: %d", testID, exp)
+				t.Logf("\t\tTest %d:\tgot: %d", testID, got)
+				t.Fatalf("\t%s\tTest %d:\tShould have the expected number of roles: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have the expected number of roles.", success, testID)
+
+			if diff := claims.IssuedAt.Sub(parsedClaims.IssuedAt.Time); diff < 0 || diff > time.Microsecond {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the issued at time to the microsecond: %v", failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the issued at time to the microsecond.", success, testID)
+
+			jwks, err := a.JWKS()
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to construct the JWKS: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to construct the JWKS.", success, testID)
+
+			if len(jwks.Keys) != 1 || jwks.Keys[0].KID != keyID || jwks.Keys[0].Alg != "RS256" || jwks.Keys[0].Use != "sig" {
+				t.Fatalf("\t%s\tTest %d:\tShould publish the signing key: %+v", failed, testID, jwks)
+			}
+			t.Logf("\t%s\tTest %d:\tShould publish the signing key.", success, testID)
+
+			n, err := base64.RawURLEncoding.DecodeString(jwks.Keys[0].N)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the modulus: %v", failed, testID, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(jwks.Keys[0].E)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the exponent: %v", failed, testID, err)
+			}
+
+			if new(big.Int).SetBytes(n).Cmp(privateKey.N) != 0 || int(new(big.Int).SetBytes(e).Int64()) != privateKey.E {
+				t.Fatalf("\t%s\tTest %d:\tShould publish the matching public key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould publish the matching public key.", success, testID)
+		}
+	}
+}
+
+func TestRotation(t *testing.T) {
+	t.Log("Given the need to rotate the signing key without downtime.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen changing the active key.", testID)
+		{
+			keys := make(map[string]crypto.Signer)
+			for _, kid := range []string{"old", "new", "next"} {
+				privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to create a private key: %v", failed, testID, err)
+				}
+				keys[kid] = privateKey
+			}
+
+			a, err := auth.New("old", keystore.NewMap(keys))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+			}
+
+			claims := auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject:   "5cf37266-3473-4006-984f-9325122678b7",
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+					IssuedAt:  jwt.NewNumericDate(time.Now()),
+				},
+			}
+
+			oldToken, err := a.GenerateToken(claims)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a JWT: %v", failed, testID, err)
+			}
+
+			if err := a.SetActiveKID("new", time.Hour); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to change the active key: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to change the active key.", success, testID)
+
+			if _, err := a.ValidateToken(oldToken); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept tokens of the retired key during the grace period: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept tokens of the retired key during the grace period.", success, testID)
+
+			newToken, err := a.GenerateToken(claims)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a JWT: %v", failed, testID, err)
+			}
+
+			if err := a.SetActiveKID("next", 0); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to change the active key: %v", failed, testID, err)
+			}
+
+			if _, err := a.ValidateToken(newToken); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept tokens of a retired key after the grace period.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept tokens of a retired key after the grace period.", success, testID)
+
+			if err := a.SetActiveKID("missing", time.Hour); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to activate a key that is not in the store.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to activate a key that is not in the store.", success, testID)
+		}
+	}
+}
+
+func TestAlgorithms(t *testing.T) {
+	t.Log("Given the need to sign tokens with elliptic curve keys.")
+	{
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create an ECDSA key: %v", failed, err)
+		}
+
+		_, edKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create an Ed25519 key: %v", failed, err)
+		}
+
+		tt := []struct {
+			kid string
+			key crypto.Signer
+			alg string
+			kty string
+		}{
+			{"ecdsa", ecKey, "ES256", "EC"},
+			{"eddsa", edKey, "EdDSA", "OKP"},
+		}
+
+		keys := make(map[string]crypto.Signer)
+		for _, tst := range tt {
+			keys[tst.kid] = tst.key
+		}
+		ks := keystore.NewMap(keys)
+
+		for testID, tst := range tt {
+			t.Logf("\tTest %d:\tWhen handling a %s key.", testID, tst.alg)
+			{
+				a, err := auth.New(tst.kid, ks)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+				}
+
+				claims := auth.Claims{
+					RegisteredClaims: jwt.RegisteredClaims{
+						Subject:   "5cf37266-3473-4006-984f-9325122678b7",
+						ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+						IssuedAt:  jwt.NewNumericDate(time.Now()),
+					},
+					Roles: []string{auth.RoleUser},
+				}
+
+				token, err := a.GenerateToken(claims)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to generate a JWT: %v", failed, testID, err)
+				}
+
+				parsed, _, err := new(jwt.Parser).ParseUnverified(token, &auth.Claims{})
+				if err != nil || parsed.Method.Alg() != tst.alg {
+					t.Fatalf("\t%s\tTest %d:\tShould sign the token with %s: %v", failed, testID, tst.alg, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould sign the token with %s.", success, testID, tst.alg)
+
+				if _, err := a.ValidateToken(token); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to validate the token: %v", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be able to validate the token.", success, testID)
+
+				jwks, err := a.JWKS()
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to construct the JWKS: %v", failed, testID, err)
+				}
+
+				var found bool
+				for _, jwk := range jwks.Keys {
+					if jwk.KID == tst.kid && jwk.KTY == tst.kty && jwk.Alg == tst.alg && jwk.X != "" {
+						found = true
+					}
+				}
+				if !found {
+					t.Fatalf("\t%s\tTest %d:\tShould publish the %s key: %+v", failed, testID, tst.kty, jwks)
+				}
+				t.Logf("\t%s\tTest %d:\tShould publish the %s key.", success, testID, tst.kty)
+			}
+		}
+	}
+}
+
+// staticPolicy grants a fixed set of permissions to every role.
+type staticPolicy map[string][]string
+
+func (p staticPolicy) Allowed(roles []string, permission string) bool {
+	for _, role := range roles {
+		for _, perm := range p[role] {
+			if perm == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestCan(t *testing.T) {
+	t.Log("Given the need to check the permissions of a request.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the context holds claims and a policy.", testID)
+		{
+			policy := staticPolicy{auth.RoleUser: {auth.PermProductsRead}}
+			claims := auth.Claims{Roles: []string{auth.RoleUser}}
+
+			ctx := auth.SetClaims(context.Background(), claims)
+			if auth.Can(ctx, auth.PermProductsRead) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT grant anything without a policy.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT grant anything without a policy.", success, testID)
+
+			ctx = auth.SetPolicy(ctx, policy)
+			if !auth.Can(ctx, auth.PermProductsRead) || auth.Can(ctx, auth.PermProductsWrite) {
+				t.Fatalf("\t%s\tTest %d:\tShould grant only the permissions of the roles.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould grant only the permissions of the roles.", success, testID)
+
+			if auth.Can(auth.SetPolicy(context.Background(), policy), auth.PermProductsRead) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT grant anything without claims.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT grant anything without claims.", success, testID)
+		}
+	}
+}
@@ -13,10 +13,13 @@ const (
 	RoleUser  = "USER"
 )
 
-// Claims represents the authorization claims transmitted via a JWT.
+// Claims represents the authorization claims transmitted via a JWT. Claims
+// resolved from an API key carry the id of the key, which is never part of
+// a JWT.
 type Claims struct {
 	jwt.RegisteredClaims
-	Roles []string `json:"roles"`
+	Roles    []string `json:"roles"`
+	APIKeyID string   `json:"-"`
 }
 
 // Authorized returns true if the claims has at least one of the provided roles.
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// JWK represents a public key in the JSON Web Key format. RSA keys use the
+// n and e members, elliptic curve keys the crv, x and y members.
+// https://datatracker.ietf.org/doc/html/rfc7517
+// https://datatracker.ietf.org/doc/html/rfc8037
+type JWK struct {
+	KTY string `json:"kty"`
+	KID string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS represents a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the set of public keys that can be used to verify the tokens
+// generated by this Auth. Clients use the kid in the token header to select
+// the key to use. Retired keys past their grace period are not included.
+func (a *Auth) JWKS() (JWKS, error) {
+	kids := a.keyLookup.KIDs()
+	sort.Strings(kids)
+
+	jwks := JWKS{
+		Keys: make([]JWK, 0, len(kids)),
+	}
+
+	now := time.Now()
+	for _, kid := range kids {
+		if !a.verifies(kid, now) {
+			continue
+		}
+
+		publicKey, err := a.keyLookup.PublicKey(kid)
+		if err != nil {
+			return JWKS{}, fmt.Errorf("kid[%s] lookup: %w", kid, err)
+		}
+
+		alg, err := a.keyLookup.Algorithm(kid)
+		if err != nil {
+			return JWKS{}, fmt.Errorf("kid[%s] algorithm: %w", kid, err)
+		}
+
+		jwk, err := newJWK(kid, alg, publicKey)
+		if err != nil {
+			return JWKS{}, fmt.Errorf("kid[%s]: %w", kid, err)
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks, nil
+}
+
+// newJWK constructs the JWK for a public key.
+func newJWK(kid string, alg string, publicKey crypto.PublicKey) (JWK, error) {
+	jwk := JWK{
+		KID: kid,
+		Alg: alg,
+		Use: "sig",
+	}
+
+	switch pk := publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KTY = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pk.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pk.E)).Bytes())
+
+	case *ecdsa.PublicKey:
+		params := pk.Curve.Params()
+		size := (params.BitSize + 7) / 8
+
+		jwk.KTY = "EC"
+		jwk.Crv = params.Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(pk.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pk.Y.FillBytes(make([]byte, size)))
+
+	case ed25519.PublicKey:
+		jwk.KTY = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pk)
+
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+
+	return jwk, nil
+}
@@ -0,0 +1,76 @@
+package auth
+
+import "context"
+
+// These are the permissions a role can grant. A permission to write a kind
+// of data implies nothing about reading it, roles are expected to grant both.
+const (
+	PermUsersRead      = "users:read"
+	PermUsersWrite     = "users:write"
+	PermProductsRead   = "products:read"
+	PermProductsWrite  = "products:write"
+	PermProductsManage = "products:manage"
+	PermSalesRead      = "sales:read"
+	PermSalesWrite     = "sales:write"
+	PermRolesRead      = "roles:read"
+	PermRolesWrite     = "roles:write"
+	PermTokensRevoke   = "tokens:revoke"
+	PermAPIKeysManage  = "apikeys:manage"
+	PermAuditRead      = "audit:read"
+)
+
+// Permissions is the set of permissions known to the service.
+var Permissions = []string{
+	PermUsersRead,
+	PermUsersWrite,
+	PermProductsRead,
+	PermProductsWrite,
+	PermProductsManage,
+	PermSalesRead,
+	PermSalesWrite,
+	PermRolesRead,
+	PermRolesWrite,
+	PermTokensRevoke,
+	PermAPIKeysManage,
+	PermAuditRead,
+}
+
+// ValidPermission returns true if the permission is known to the service.
+func ValidPermission(permission string) bool {
+	for _, p := range Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy declares the behavior for deciding if a set of roles grants a
+// permission.
+type Policy interface {
+	Allowed(roles []string, permission string) bool
+}
+
+// policyKey is used to store/retrieve a Policy value from a context.Context.
+const policyKey ctxKey = 2
+
+// SetPolicy stores the policy in the context.
+func SetPolicy(ctx context.Context, policy Policy) context.Context {
+	return context.WithValue(ctx, policyKey, policy)
+}
+
+// Can returns true if the claims in the context grant the permission under
+// the policy in the context. Without either value nothing is granted.
+func Can(ctx context.Context, permission string) bool {
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return false
+	}
+
+	policy, ok := ctx.Value(policyKey).(Policy)
+	if !ok {
+		return false
+	}
+
+	return policy.Allowed(claims.Roles, permission)
+}
@@ -3,13 +3,19 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"net/url"
+	"path"
 	"reflect"
+	"runtime"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/ardanlabs/service/business/sys/metrics"
+	"github.com/ardanlabs/service/foundation/tracer"
 	"github.com/ardanlabs/service/foundation/web"
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
@@ -19,7 +25,36 @@ import (
 
 // lib/pq errorCodeNames
 // https://github.com/lib/pq/blob/master/error.go#L178
-const uniqueViolation = "23505"
+const (
+	uniqueViolation      = "23505"
+	serializationFailure = "40001"
+)
+
+// pkgPath is the import path of this package, used to find the code calling
+// the helpers.
+var pkgPath = reflect.TypeOf(call{}).PkgPath()
+
+// slowQueryConfig holds the settings for reporting slow queries.
+type slowQueryConfig struct {
+	threshold time.Duration
+	explain   bool
+}
+
+// slowQueries holds the slowQueryConfig set with ReportSlowQueries.
+var slowQueries atomic.Value
+
+// ReportSlowQueries logs at WARN the database calls taking at least the
+// threshold, with the query and its parameters. A zero threshold turns the
+// reporting off. With explain set the plan of each slow query is logged as
+// well, at the cost of another round trip, which is meant for development
+// and staging environments rather than production.
+func ReportSlowQueries(threshold time.Duration, explain bool) {
+	slowQueries.Store(slowQueryConfig{threshold: threshold, explain: explain})
+}
+
+// maxTranAttempts is the number of times a transaction is attempted when it
+// keeps failing because of serialization conflicts with other transactions.
+const maxTranAttempts = 3
 
 // Set of error variables for CRUD operations.
 var (
@@ -96,9 +131,99 @@ func StatusCheck(ctx context.Context, db *sqlx.DB) error {
 	return db.QueryRowContext(ctx, q).Scan(&tmp)
 }
 
+// WithinTran runs the specified function inside a transaction with the
+// default isolation level of the database. The transaction is committed if
+// the function returns nil and rolled back otherwise.
+func WithinTran(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, fn func(sqlx.ExtContext) error) error {
+	return WithinTranOptions(ctx, log, db, nil, fn)
+}
+
+// WithinTranOptions runs the specified function inside a transaction started
+// with the options, like WithinTran. Units of work that read rows and write
+// based on what they read without locking them can ask for the serializable
+// isolation level. If the transaction then fails because of a serialization
+// conflict (SQLSTATE 40001) the whole unit of work is retried, so the function
+// must be safe to run more than once.
+func WithinTranOptions(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, opts *sql.TxOptions, fn func(sqlx.ExtContext) error) (err error) {
+	ctx, span := tracer.Start(ctx, "database.WithinTran", tracer.String("db.system", "postgresql"))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	traceID := web.GetTraceID(ctx)
+
+	for attempt := 1; ; attempt++ {
+		err := withinTran(ctx, log, db, opts, fn)
+		if err == nil {
+			return nil
+		}
+
+		if attempt == maxTranAttempts || !isSerializationFailure(err) {
+			return err
+		}
+
+		log.Infow("database.WithinTran", "traceid", traceID, "status", "retrying serialization failure", "attempt", attempt)
+
+		// Back off a little to give the conflicting transaction time to finish.
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * 10 * time.Millisecond):
+		}
+	}
+}
+
+// withinTran executes a single attempt of a WithinTran unit of work.
+func withinTran(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, opts *sql.TxOptions, fn func(sqlx.ExtContext) error) error {
+	traceID := web.GetTraceID(ctx)
+
+	log.Infow("database.WithinTran", "traceid", traceID, "status", "begin tran")
+	tx, err := db.BeginTxx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("begin tran: %w", err)
+	}
+
+	// We can defer the rollback since the code checks if the transaction
+	// has already been committed.
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		log.Infow("database.WithinTran", "traceid", traceID, "status", "rollback tran")
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Errorw("database.WithinTran", "traceid", traceID, "status", "unable to rollback tran", "ERROR", err)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tran: %w", err)
+	}
+	committed = true
+
+	log.Infow("database.WithinTran", "traceid", traceID, "status", "commit tran")
+
+	return nil
+}
+
+// isSerializationFailure checks if the error reported by postgres means the
+// transaction could not be serialized with other concurrent transactions.
+func isSerializationFailure(err error) bool {
+	var pqerr *pq.Error
+	return errors.As(err, &pqerr) && pqerr.Code == serializationFailure
+}
+
 // NamedExecContext is a helper function to execute a CUD operation with
 // logging and tracing.
-func NamedExecContext(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, query string, data interface{}) error {
+func NamedExecContext(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}) (err error) {
+	ctx, c := startCall(ctx, log, db, "database.NamedExecContext", query, data)
+	defer func() { c.end(ctx, err) }()
+
 	q := queryString(query, data)
 	log.Infow("database.NamedExecContext", "traceid", web.GetTraceID(ctx), "query", q)
 
@@ -114,9 +239,35 @@ func NamedExecContext(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB,
 	return nil
 }
 
+// NamedExecContextAffected is a helper function to execute a CUD operation
+// with logging and tracing. It returns the number of rows affected, for
+// operations whose conditions are allowed to match nothing.
+func NamedExecContextAffected(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}) (affected int64, err error) {
+	ctx, c := startCall(ctx, log, db, "database.NamedExecContextAffected", query, data)
+	defer func() { c.end(ctx, err) }()
+
+	q := queryString(query, data)
+	log.Infow("database.NamedExecContextAffected", "traceid", web.GetTraceID(ctx), "query", q)
+
+	result, err := sqlx.NamedExecContext(ctx, db, query, data)
+	if err != nil {
+
+		// Checks if the error is of code 23505 (unique_violation).
+		if pqerr, ok := err.(*pq.Error); ok && pqerr.Code == uniqueViolation {
+			return 0, ErrDBDuplicatedEntry
+		}
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
 // NamedQuerySlice is a helper function for executing queries that return a
 // collection of data to be unmarshalled into a slice.
-func NamedQuerySlice(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}, dest interface{}) error {
+func NamedQuerySlice(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}, dest interface{}) (err error) {
+	ctx, c := startCall(ctx, log, db, "database.NamedQuerySlice", query, data)
+	defer func() { c.end(ctx, err) }()
+
 	q := queryString(query, data)
 	log.Infow("database.NamedQuerySlice", "traceid", web.GetTraceID(ctx), "query", q)
 
@@ -145,7 +296,10 @@ func NamedQuerySlice(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtCon
 
 // NamedQueryStruct is a helper function for executing queries that return a
 // single value to be unmarshalled into a struct type.
-func NamedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}, dest interface{}) error {
+func NamedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}, dest interface{}) (err error) {
+	ctx, c := startCall(ctx, log, db, "database.NamedQueryStruct", query, data)
+	defer func() { c.end(ctx, err) }()
+
 	q := queryString(query, data)
 	log.Infow("database.NamedQueryStruct", "traceid", web.GetTraceID(ctx), "query", q)
 
@@ -166,6 +320,142 @@ func NamedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtCo
 	return nil
 }
 
+// call tracks a database call for tracing, metrics and slow query reporting.
+type call struct {
+	log       *zap.SugaredLogger
+	db        sqlx.ExtContext
+	helper    string
+	name      string
+	statement string
+	data      interface{}
+	start     time.Time
+	span      *tracer.Span
+}
+
+// startCall starts tracking a database call running the query. The query is
+// named after the function that called the helper.
+func startCall(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, helper string, query string, data interface{}) (context.Context, call) {
+	name := queryName()
+
+	ctx, span := tracer.Start(ctx, helper,
+		tracer.String("db.system", "postgresql"),
+		tracer.String("db.operation", name),
+		tracer.String("db.statement", query),
+	)
+
+	c := call{
+		log:       log,
+		db:        db,
+		helper:    helper,
+		name:      name,
+		statement: query,
+		data:      data,
+		start:     time.Now(),
+		span:      span,
+	}
+
+	return ctx, c
+}
+
+// end records how long the call took, reports it when it was slow and ends
+// its span, recording the error unless it only reports that no rows were
+// found.
+func (c call) end(ctx context.Context, err error) {
+	duration := time.Since(c.start)
+	metrics.ObserveQuery(ctx, c.name, duration)
+
+	if cfg, _ := slowQueries.Load().(slowQueryConfig); cfg.threshold > 0 && duration >= cfg.threshold {
+		c.reportSlow(ctx, duration, err, cfg.explain)
+	}
+
+	if err != nil && !errors.Is(err, ErrDBNotFound) {
+		c.span.RecordError(err)
+	}
+	c.span.End()
+}
+
+// reportSlow logs the slow call, with the plan of the query when asked to.
+func (c call) reportSlow(ctx context.Context, duration time.Duration, err error, explain bool) {
+	traceID := web.GetTraceID(ctx)
+
+	c.log.Warnw(c.helper, "traceid", traceID, "status", "slow query", "name", c.name, "duration", duration, "query", queryString(c.statement, c.data))
+
+	// A failed statement aborts the transaction it runs in, so nothing else
+	// can be asked of the database.
+	if !explain || (err != nil && !errors.Is(err, ErrDBNotFound)) {
+		return
+	}
+
+	plan, err := explainQuery(ctx, c.db, c.statement, c.data)
+	if err != nil {
+		c.log.Warnw(c.helper, "traceid", traceID, "status", "unable to explain slow query", "name", c.name, "ERROR", err)
+		return
+	}
+
+	c.log.Warnw(c.helper, "traceid", traceID, "status", "slow query plan", "name", c.name, "plan", plan)
+}
+
+// explainQuery returns the plan postgres chooses for the query, without
+// running it.
+func explainQuery(ctx context.Context, db sqlx.ExtContext, query string, data interface{}) (string, error) {
+	rows, err := sqlx.NamedQueryContext(ctx, db, "EXPLAIN "+query, data)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// queryName names the query after the function calling the database
+// helpers, skipping the frames of this package. A store method like
+// QueryByID in business/core/user/db is named user.QueryByID.
+func queryName() string {
+	pc := make([]uintptr, 8)
+	n := runtime.Callers(3, pc)
+	frames := runtime.CallersFrames(pc[:n])
+
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, pkgPath+".") {
+			dir, fn := path.Split(frame.Function)
+			if fn == "" {
+				return "unknown"
+			}
+
+			pkg, fn := fn, fn
+			if i := strings.Index(pkg, "."); i != -1 {
+				pkg = pkg[:i]
+			}
+			if i := strings.LastIndex(fn, "."); i != -1 {
+				fn = fn[i+1:]
+			}
+
+			// Stores live in a db package named after their core package.
+			if pkg == "db" {
+				pkg = path.Base(dir)
+			}
+
+			return pkg + "." + fn
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
 // queryString provides a pretty print version of the query and parameters.
 func queryString(query string, args ...interface{}) string {
 	query, params, err := sqlx.Named(query, args)
@@ -0,0 +1,110 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/foundation/docker"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func TestWithinTranRetry(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testwithintran")
+	t.Cleanup(teardown)
+
+	ctx := context.Background()
+
+	if _, err := db.ExecContext(ctx, `CREATE TABLE counters (id INT PRIMARY KEY, value INT NOT NULL)`); err != nil {
+		t.Fatalf("creating table: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO counters (id, value) VALUES (1, 0)`); err != nil {
+		t.Fatalf("inserting counter: %v", err)
+	}
+
+	opts := sql.TxOptions{Isolation: sql.LevelSerializable}
+
+	// increment runs a transaction incrementing the counter.
+	// While the transaction is open another one increments the counter too
+	// and commits first, on the attempts that conflict.
+	increment := func(attempts *int, conflicts int) func(tx sqlx.ExtContext) error {
+		return func(tx sqlx.ExtContext) error {
+			*attempts++
+
+			var value int
+			if err := tx.QueryRowxContext(ctx, `SELECT value FROM counters WHERE id = 1`).Scan(&value); err != nil {
+				return err
+			}
+
+			if *attempts <= conflicts {
+				if _, err := db.ExecContext(ctx, `UPDATE counters SET value = value + 1 WHERE id = 1`); err != nil {
+					return err
+				}
+			}
+
+			_, err := tx.ExecContext(ctx, `UPDATE counters SET value = $1 WHERE id = 1`, value+1)
+			return err
+		}
+	}
+
+	t.Log("Given the need to retry transactions failing to serialize.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the first attempt conflicts with another transaction.", testID)
+		{
+			var attempts int
+			if err := database.WithinTranOptions(ctx, log, db, &opts, increment(&attempts, 1)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to commit on a retry : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to commit on a retry.", dbtest.Success, testID)
+
+			if attempts != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould have run the transaction twice : %d.", dbtest.Failed, testID, attempts)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have run the transaction twice.", dbtest.Success, testID)
+
+			var value int
+			if err := db.GetContext(ctx, &value, `SELECT value FROM counters WHERE id = 1`); err != nil || value != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould keep both increments : %d %v.", dbtest.Failed, testID, value, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep both increments.", dbtest.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen every attempt conflicts with another transaction.", testID)
+		{
+			var attempts int
+			err := database.WithinTranOptions(ctx, log, db, &opts, increment(&attempts, database.MaxTranAttempts))
+
+			var pqerr *pq.Error
+			if !errors.As(err, &pqerr) || pqerr.Code != "40001" {
+				t.Fatalf("\t%s\tTest %d:\tShould fail with the serialization failure : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail with the serialization failure.", dbtest.Success, testID)
+
+			if attempts != database.MaxTranAttempts {
+				t.Fatalf("\t%s\tTest %d:\tShould have run the transaction %d times : %d.", dbtest.Failed, testID, database.MaxTranAttempts, attempts)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have run the transaction %d times.", dbtest.Success, testID, database.MaxTranAttempts)
+		}
+	}
+}
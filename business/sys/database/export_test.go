@@ -0,0 +1,5 @@
+package database
+
+// MaxTranAttempts exports the number of attempts of a transaction for the
+// tests.
+const MaxTranAttempts = maxTranAttempts
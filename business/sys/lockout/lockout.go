@@ -0,0 +1,146 @@
+// Package lockout provides support for locking out keys, like an account or
+// a source IP, after repeated authentication failures. Every failure past the
+// threshold doubles the lockout, up to a maximum, which slows down brute force
+// attacks without locking out legitimate users for long.
+//
+// The failures are tracked in memory, so each instance of the service keeps
+// its own count.
+package lockout
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/metrics"
+	"go.uber.org/zap"
+)
+
+// ErrLocked is returned when a key is locked out.
+var ErrLocked = errors.New("too many failed attempts, try again later")
+
+// Config represents the policy for locking out keys.
+type Config struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration
+}
+
+// entry tracks the failures of a single key.
+type entry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// Tracker tracks authentication failures for a kind of key.
+type Tracker struct {
+	log       *zap.SugaredLogger
+	kind      string
+	cfg       Config
+	mu        sync.Mutex
+	entries   map[string]*entry
+	nextPurge time.Time
+}
+
+// New constructs a tracker for the specified kind of key. The kind is only
+// used to identify the tracker in logs.
+func New(log *zap.SugaredLogger, kind string, cfg Config) *Tracker {
+	return &Tracker{
+		log:     log,
+		kind:    kind,
+		cfg:     cfg,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Locked reports if the key is locked out and for how much longer.
+func (t *Tracker) Locked(key string, now time.Time) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, exists := t.entries[key]
+	if !exists || !now.Before(e.lockedUntil) {
+		return 0, false
+	}
+
+	return e.lockedUntil.Sub(now), true
+}
+
+// Fail records an authentication failure for the key. Failures older than the
+// window are forgotten. Once the threshold is reached the key is locked out,
+// and the lockout doubles with every further failure.
+func (t *Tracker) Fail(ctx context.Context, key string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.purge(now)
+
+	e, exists := t.entries[key]
+	if !exists || e.stale(now, t.cfg.Window) {
+		e = &entry{}
+		t.entries[key] = e
+	}
+
+	e.failures++
+	e.lastFailure = now
+
+	if e.failures < t.cfg.Threshold {
+		return
+	}
+
+	delay := t.delay(e.failures - t.cfg.Threshold)
+	e.lockedUntil = now.Add(delay)
+
+	metrics.AddLockouts(ctx)
+	t.log.Warnw("lockout", "kind", t.kind, "key", key, "failures", e.failures, "duration", delay)
+}
+
+// Succeed forgets the failures of the key.
+func (t *Tracker) Succeed(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, key)
+}
+
+// =============================================================================
+
+// delay calculates the lockout after the specified number of failures past
+// the threshold.
+func (t *Tracker) delay(n int) time.Duration {
+	delay := t.cfg.BaseDelay
+	for i := 0; i < n && delay < t.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > t.cfg.MaxDelay {
+		delay = t.cfg.MaxDelay
+	}
+
+	return delay
+}
+
+// purge removes the stale entries once per window so keys that are never
+// used again don't accumulate.
+func (t *Tracker) purge(now time.Time) {
+	if now.Before(t.nextPurge) {
+		return
+	}
+
+	for key, e := range t.entries {
+		if e.stale(now, t.cfg.Window) {
+			delete(t.entries, key)
+		}
+	}
+
+	t.nextPurge = now.Add(t.cfg.Window)
+}
+
+// stale reports if the entry is no longer locked out and its last failure
+// is older than the window.
+func (e *entry) stale(now time.Time, window time.Duration) bool {
+	return !now.Before(e.lockedUntil) && now.Sub(e.lastFailure) > window
+}
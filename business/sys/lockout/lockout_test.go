@@ -0,0 +1,92 @@
+package lockout_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/lockout"
+	"go.uber.org/zap"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestLockout(t *testing.T) {
+	cfg := lockout.Config{
+		Threshold: 3,
+		BaseDelay: time.Second,
+		MaxDelay:  5 * time.Second,
+		Window:    time.Minute,
+	}
+
+	t.Log("Given the need to lock out keys after repeated failures.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a key keeps failing.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+			tr := lockout.New(zap.NewNop().Sugar(), "account", cfg)
+
+			const key = "admin@example.com"
+
+			tr.Fail(ctx, key, now)
+			tr.Fail(ctx, key, now)
+			if _, locked := tr.Locked(key, now); locked {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be locked out before the threshold.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be locked out before the threshold.", success, testID)
+
+			exp := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+			for _, delay := range exp {
+				tr.Fail(ctx, key, now)
+				wait, locked := tr.Locked(key, now)
+				if !locked || wait != delay {
+					t.Fatalf("\t%s\tTest %d:\tShould be locked out for %v : got %v.", failed, testID, delay, wait)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be locked out for %v.", success, testID, delay)
+			}
+
+			if _, locked := tr.Locked(key, now.Add(5*time.Second)); locked {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be locked out once the lockout is over.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be locked out once the lockout is over.", success, testID)
+
+			if _, locked := tr.Locked("other@example.com", now); locked {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT lock out other keys.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT lock out other keys.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen failures are forgiven.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+			tr := lockout.New(zap.NewNop().Sugar(), "account", cfg)
+
+			const key = "admin@example.com"
+
+			tr.Fail(ctx, key, now)
+			tr.Fail(ctx, key, now)
+			tr.Succeed(key)
+			tr.Fail(ctx, key, now)
+			if _, locked := tr.Locked(key, now); locked {
+				t.Fatalf("\t%s\tTest %d:\tShould forget failures after a success.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould forget failures after a success.", success, testID)
+
+			later := now.Add(2 * time.Minute)
+			tr.Fail(ctx, key, later)
+			tr.Fail(ctx, key, later)
+			if _, locked := tr.Locked(key, later); locked {
+				t.Fatalf("\t%s\tTest %d:\tShould forget failures older than the window.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould forget failures older than the window.", success, testID)
+		}
+	}
+}
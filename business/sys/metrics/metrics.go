@@ -3,8 +3,14 @@ package metrics
 
 import (
 	"context"
+	"database/sql"
 	"expvar"
+	"net/http"
 	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/ardanlabs/service/foundation/prometheus"
 )
 
 // This holds the single instance of the metrics value needed for
@@ -16,12 +22,22 @@ var m *metrics
 // =============================================================================
 
 // metrics represents the set of metrics we gather. These fields are
-// safe to be accessed concurrently thanks to expvar. No extra abstraction is required.
+// safe to be accessed concurrently thanks to expvar and the prometheus
+// package. No extra abstraction is required.
 type metrics struct {
 	goroutines *expvar.Int
 	requests   *expvar.Int
 	errors     *expvar.Int
 	panics     *expvar.Int
+	authFails  *expvar.Int
+	lockouts   *expvar.Int
+	limited    *expvar.Int
+
+	registry *prometheus.Registry
+	duration *prometheus.Histogram
+	size     *prometheus.Histogram
+	inFlight *prometheus.Gauge
+	queries  *prometheus.Histogram
 }
 
 // init constructs the metrics value that will be used to capture metrics.
@@ -34,9 +50,68 @@ func init() {
 		requests:   expvar.NewInt("requests"),
 		errors:     expvar.NewInt("errors"),
 		panics:     expvar.NewInt("panics"),
+		authFails:  expvar.NewInt("auth_failures"),
+		lockouts:   expvar.NewInt("lockouts"),
+		limited:    expvar.NewInt("rate_limited"),
+		registry:   prometheus.NewRegistry(),
+	}
+
+	// The expvar counters are exposed to Prometheus as well, so both see the
+	// same values.
+	r := m.registry
+	r.NewCounterFunc("http_requests_total", "Requests served.", expvarValue(m.requests))
+	r.NewCounterFunc("http_request_errors_total", "Requests that failed with an error.", expvarValue(m.errors))
+	r.NewCounterFunc("http_panics_total", "Requests that panicked.", expvarValue(m.panics))
+	r.NewCounterFunc("auth_failures_total", "Failed authentication attempts.", expvarValue(m.authFails))
+	r.NewCounterFunc("lockouts_total", "Accounts and addresses locked out.", expvarValue(m.lockouts))
+	r.NewCounterFunc("http_rate_limited_total", "Requests rejected by rate limits.", expvarValue(m.limited))
+	r.NewGaugeFunc("go_goroutines", "Goroutines that currently exist.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+
+	m.duration = r.NewHistogram("http_request_duration_seconds", "Time taken to serve requests.", prometheus.DefBuckets, "route", "method", "status")
+	m.size = r.NewHistogram("http_response_size_bytes", "Size of the response bodies.", []float64{100, 1_000, 10_000, 100_000, 1_000_000, 10_000_000}, "route", "method", "status")
+	m.inFlight = r.NewGauge("http_requests_in_flight", "Requests currently being served.")
+	m.queries = r.NewHistogram("db_query_duration_seconds", "Time taken by database queries.", prometheus.DefBuckets, "query")
+}
+
+// expvarValue returns a function reading the value of the expvar counter.
+func expvarValue(v *expvar.Int) func() float64 {
+	return func() float64 {
+		return float64(v.Value())
 	}
 }
 
+// RegisterDBStats exposes the statistics of a database connection pool, read
+// from the function when the metrics are collected. It can only be called
+// once.
+func RegisterDBStats(stats func() sql.DBStats) {
+	r := m.registry
+
+	gauge := func(name string, help string, fn func(s sql.DBStats) float64) {
+		r.NewGaugeFunc(name, help, func() float64 { return fn(stats()) })
+	}
+	counter := func(name string, help string, fn func(s sql.DBStats) float64) {
+		r.NewCounterFunc(name, help, func() float64 { return fn(stats()) })
+	}
+
+	gauge("db_max_open_connections", "Maximum number of open connections to the database.", func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) })
+	gauge("db_open_connections", "Established connections, in use or idle.", func(s sql.DBStats) float64 { return float64(s.OpenConnections) })
+	gauge("db_in_use_connections", "Connections currently in use.", func(s sql.DBStats) float64 { return float64(s.InUse) })
+	gauge("db_idle_connections", "Idle connections.", func(s sql.DBStats) float64 { return float64(s.Idle) })
+	counter("db_wait_count_total", "Connections waited for because the pool was exhausted.", func(s sql.DBStats) float64 { return float64(s.WaitCount) })
+	counter("db_wait_duration_seconds_total", "Time spent waiting for a connection.", func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() })
+	counter("db_max_idle_closed_total", "Connections closed because of the idle connections limit.", func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) })
+	counter("db_max_idle_time_closed_total", "Connections closed because they were idle for too long.", func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) })
+	counter("db_max_lifetime_closed_total", "Connections closed because they reached their maximum lifetime.", func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) })
+}
+
+// Handler returns the handler exposing the metrics in the Prometheus text
+// exposition format.
+func Handler() http.Handler {
+	return m.registry
+}
+
 // =============================================================================
 
 // Metrics will be supported through the context.
@@ -87,3 +162,48 @@ func AddPanics(ctx context.Context) {
 		v.panics.Add(1)
 	}
 }
+
+// AddAuthFailures increments the failed authentication metric by 1.
+func AddAuthFailures(ctx context.Context) {
+	if v, ok := ctx.Value(key).(*metrics); ok {
+		v.authFails.Add(1)
+	}
+}
+
+// AddLockouts increments the lockout metric by 1.
+func AddLockouts(ctx context.Context) {
+	if v, ok := ctx.Value(key).(*metrics); ok {
+		v.lockouts.Add(1)
+	}
+}
+
+// AddRateLimited increments the rate limited requests metric by 1.
+func AddRateLimited(ctx context.Context) {
+	if v, ok := ctx.Value(key).(*metrics); ok {
+		v.limited.Add(1)
+	}
+}
+
+// AddInFlight changes the number of requests in flight by delta.
+func AddInFlight(ctx context.Context, delta int) {
+	if v, ok := ctx.Value(key).(*metrics); ok {
+		v.inFlight.Add(float64(delta))
+	}
+}
+
+// ObserveRequest records how long a request to the route pattern took and
+// the size of the response.
+func ObserveRequest(ctx context.Context, route string, method string, status int, duration time.Duration, size int) {
+	if v, ok := ctx.Value(key).(*metrics); ok {
+		code := strconv.Itoa(status)
+		v.duration.Observe(duration.Seconds(), route, method, code)
+		v.size.Observe(float64(size), route, method, code)
+	}
+}
+
+// ObserveQuery records how long the named database query took.
+func ObserveQuery(ctx context.Context, name string, duration time.Duration) {
+	if v, ok := ctx.Value(key).(*metrics); ok {
+		v.queries.Observe(duration.Seconds(), name)
+	}
+}
@@ -0,0 +1,75 @@
+// Package order provides support for describing the ordering of data.
+package order
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Set of directions for data ordering.
+const (
+	ASC  = "ASC"
+	DESC = "DESC"
+)
+
+// directions is the set of valid directions.
+var directions = map[string]bool{
+	ASC:  true,
+	DESC: true,
+}
+
+// ErrInvalidOrder is returned when an order is requested on an unknown field
+// or in an unknown direction.
+var ErrInvalidOrder = errors.New("invalid order")
+
+// By represents a field used to order by and direction.
+type By struct {
+	Field     string
+	Direction string
+}
+
+// NewBy constructs a new By value with no checks.
+func NewBy(field string, direction string) By {
+	return By{
+		Field:     field,
+		Direction: direction,
+	}
+}
+
+// Reverse returns the same ordering in the opposite direction.
+func (b By) Reverse() By {
+	if b.Direction == DESC {
+		return NewBy(b.Field, ASC)
+	}
+	return NewBy(b.Field, DESC)
+}
+
+// Parse constructs a By value by parsing a string in the form of
+// "field,direction". The direction is optional and defaults to ASC. If the
+// string is empty the default order is returned.
+func Parse(orderBy string, defaultOrder By) (By, error) {
+	if orderBy == "" {
+		return defaultOrder, nil
+	}
+
+	orderParts := strings.Split(orderBy, ",")
+
+	var by By
+	switch len(orderParts) {
+	case 1:
+		by = NewBy(strings.TrimSpace(orderParts[0]), ASC)
+
+	case 2:
+		direction := strings.ToUpper(strings.TrimSpace(orderParts[1]))
+		if !directions[direction] {
+			return By{}, fmt.Errorf("unknown direction %q: %w", orderParts[1], ErrInvalidOrder)
+		}
+		by = NewBy(strings.TrimSpace(orderParts[0]), direction)
+
+	default:
+		return By{}, fmt.Errorf("unknown order %q: %w", orderBy, ErrInvalidOrder)
+	}
+
+	return by, nil
+}
@@ -0,0 +1,53 @@
+// Package paging provides support for offset and keyset (cursor) pagination.
+package paging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidCursor is returned when a cursor can't be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position inside an ordered result set. It holds the value of
+// the ordered field and the id of the row at that position so the next query
+// can seek directly to it instead of skipping rows with an OFFSET. When Prev
+// is set the cursor points to the page before the position.
+type Cursor struct {
+	Field     string `json:"f"`
+	Direction string `json:"d"`
+	Value     string `json:"v"`
+	ID        string `json:"id"`
+	Prev      bool   `json:"p,omitempty"`
+}
+
+// Encode returns the opaque string representation of the cursor that is
+// handed to clients.
+func (c Cursor) Encode() string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode parses the opaque string representation of a cursor.
+func Decode(cursor string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("decoding: %w", ErrInvalidCursor)
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Cursor{}, fmt.Errorf("unmarshaling: %w", ErrInvalidCursor)
+	}
+
+	if c.Field == "" || c.ID == "" {
+		return Cursor{}, fmt.Errorf("missing position: %w", ErrInvalidCursor)
+	}
+
+	return c, nil
+}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// bucket holds the tokens of a single key.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Memory keeps the buckets in memory, so each instance of the service
+// limits its clients on its own.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// NewMemory constructs an empty memory store.
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Take refills the bucket of the key and takes a token from it when there
+// is one. A new key starts with a full bucket.
+func (m *Memory) Take(ctx context.Context, key string, limit Limit, now time.Time) (float64, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, exists := m.buckets[key]
+	if !exists {
+		b = &bucket{tokens: float64(limit.burst()), updated: now}
+		m.buckets[key] = b
+	}
+
+	tokens, allowed := take(b.tokens, now.Sub(b.updated), limit)
+	b.tokens = tokens
+	if now.After(b.updated) {
+		b.updated = now
+	}
+
+	return tokens, allowed, nil
+}
+
+// Purge forgets the buckets not used since before the time.
+func (m *Memory) Purge(ctx context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, b := range m.buckets {
+		if b.updated.Before(before) {
+			delete(m.buckets, key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Postgres keeps the buckets in the rate_limits table, so all the instances
+// of the service share the same limits.
+type Postgres struct {
+	log *zap.SugaredLogger
+	db  *sqlx.DB
+}
+
+// NewPostgres constructs a store keeping the buckets in the database.
+func NewPostgres(log *zap.SugaredLogger, db *sqlx.DB) *Postgres {
+	return &Postgres{
+		log: log,
+		db:  db,
+	}
+}
+
+// refill is the number of tokens in the bucket of an existing row once
+// refilled for the time since it was last used, up to the burst.
+const refill = `LEAST(CAST(:burst AS DOUBLE PRECISION), rl.tokens + GREATEST(0, EXTRACT(EPOCH FROM CAST(:now AS TIMESTAMP) - rl.date_updated)) * CAST(:rate AS DOUBLE PRECISION))`
+
+// Take refills the bucket of the key and takes a token from it when there
+// is one, in a single statement so concurrent requests from other instances
+// wait on the row instead of overwriting each other.
+func (p *Postgres) Take(ctx context.Context, key string, limit Limit, now time.Time) (float64, bool, error) {
+	data := struct {
+		Key   string    `db:"rate_key"`
+		Burst int       `db:"burst"`
+		Rate  float64   `db:"rate"`
+		Now   time.Time `db:"now"`
+	}{
+		Key:   key,
+		Burst: limit.burst(),
+		Rate:  limit.rate(),
+		Now:   now,
+	}
+
+	const q = `
+	INSERT INTO rate_limits AS rl
+		(rate_key, tokens, allowed, date_updated)
+	VALUES
+		(:rate_key, CAST(:burst AS DOUBLE PRECISION) - 1, true, :now)
+	ON CONFLICT (rate_key) DO UPDATE SET
+		tokens = CASE WHEN ` + refill + ` >= 1 THEN ` + refill + ` - 1 ELSE ` + refill + ` END,
+		allowed = ` + refill + ` >= 1,
+		date_updated = GREATEST(rl.date_updated, CAST(:now AS TIMESTAMP))
+	RETURNING
+		tokens, allowed`
+
+	var b struct {
+		Tokens  float64 `db:"tokens"`
+		Allowed bool    `db:"allowed"`
+	}
+	if err := database.NamedQueryStruct(ctx, p.log, p.db, q, data, &b); err != nil {
+		return 0, false, fmt.Errorf("taking token: %w", err)
+	}
+
+	return b.Tokens, b.Allowed, nil
+}
+
+// Purge forgets the buckets not used since before the time.
+func (p *Postgres) Purge(ctx context.Context, before time.Time) error {
+	data := struct {
+		Before time.Time `db:"before"`
+	}{
+		Before: before,
+	}
+
+	const q = `
+	DELETE FROM
+		rate_limits
+	WHERE
+		date_updated < :before`
+
+	if err := database.NamedExecContext(ctx, p.log, p.db, q, data); err != nil {
+		return fmt.Errorf("purging buckets: %w", err)
+	}
+
+	return nil
+}
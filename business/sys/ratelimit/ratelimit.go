@@ -0,0 +1,191 @@
+// Package ratelimit provides support for limiting the rate of requests of
+// each client with token buckets. A bucket holds up to a burst of tokens and
+// is refilled at a steady rate; every request takes a token and is rejected
+// when the bucket is empty.
+//
+// The buckets are kept by a Store, either in memory, where each instance of
+// the service limits clients on its own, or in the database, where all the
+// instances share the same limits.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrLimited is returned when a client made too many requests.
+var ErrLimited = errors.New("rate limit exceeded, try again later")
+
+// Limit represents how many requests a client can make in a period, with
+// bursts of up to Burst requests. A zero Burst allows the whole period's
+// requests at once. A Limit without requests doesn't limit anything.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// Unlimited reports if the limit doesn't limit anything.
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+// rate returns the number of tokens added to a bucket per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// burst returns the number of tokens a bucket holds when full.
+func (l Limit) burst() int {
+	if l.Burst <= 0 {
+		return l.Requests
+	}
+	return l.Burst
+}
+
+// fill returns how long an empty bucket takes to fill up.
+func (l Limit) fill() time.Duration {
+	return time.Duration(float64(l.burst()) / l.rate() * float64(time.Second))
+}
+
+// Store keeps the token buckets of the clients.
+type Store interface {
+	// Take refills the bucket of the key for the time since it was last used
+	// and takes a token from it when there is one. It returns the tokens left
+	// and if a token was taken.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (tokens float64, allowed bool, err error)
+
+	// Purge forgets the buckets not used since before the time.
+	Purge(ctx context.Context, before time.Time) error
+}
+
+// Config represents the limits applied to the clients. Routes overrides the
+// default limit for routes identified by their method and pattern, like
+// "POST /v1/users/import". Each overridden route has buckets of its own.
+type Config struct {
+	Default Limit
+	Routes  map[string]Limit
+}
+
+// Result describes the state of the bucket of a client after a request.
+type Result struct {
+	Allowed    bool
+	Limit      Limit
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Limiter applies the limits of a configuration to the clients.
+type Limiter struct {
+	log   *zap.SugaredLogger
+	store Store
+	cfg   Config
+	idle  time.Duration
+
+	mu        sync.Mutex
+	nextPurge time.Time
+}
+
+// New constructs a limiter keeping the buckets in the store.
+func New(log *zap.SugaredLogger, store Store, cfg Config) *Limiter {
+
+	// Buckets unused for longer than the slowest of them takes to fill up
+	// are full, which is the same as not having one.
+	idle := time.Minute
+	limits := []Limit{cfg.Default}
+	for _, l := range cfg.Routes {
+		limits = append(limits, l)
+	}
+	for _, l := range limits {
+		if !l.Unlimited() && l.fill() > idle {
+			idle = l.fill()
+		}
+	}
+
+	return &Limiter{
+		log:   log,
+		store: store,
+		cfg:   cfg,
+		idle:  idle,
+	}
+}
+
+// Allow takes a token from the bucket of the client for the route, like
+// "GET /v1/users/:id", and reports if the request can proceed. When the
+// store fails the request is allowed, since rejecting every request would
+// be worse than not limiting them for a while.
+func (l *Limiter) Allow(ctx context.Context, route string, client string, now time.Time) Result {
+	limit, key := l.cfg.Default, client
+	if rl, exists := l.cfg.Routes[route]; exists {
+		limit, key = rl, route+" "+client
+	}
+
+	if limit.Unlimited() {
+		return Result{Allowed: true}
+	}
+
+	l.purge(ctx, now)
+
+	tokens, allowed, err := l.store.Take(ctx, key, limit, now)
+	if err != nil {
+		l.log.Errorw("ratelimit", "status", "unable to take token", "key", key, "ERROR", err)
+		return Result{Allowed: true}
+	}
+
+	rate := limit.rate()
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.burst()) - tokens) / rate),
+	}
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / rate)
+	}
+
+	return res
+}
+
+// =============================================================================
+
+// purge forgets the idle buckets once per idle period so clients that never
+// come back don't accumulate.
+func (l *Limiter) purge(ctx context.Context, now time.Time) {
+	l.mu.Lock()
+	if now.Before(l.nextPurge) {
+		l.mu.Unlock()
+		return
+	}
+	l.nextPurge = now.Add(l.idle)
+	l.mu.Unlock()
+
+	if err := l.store.Purge(ctx, now.Add(-l.idle)); err != nil {
+		l.log.Errorw("ratelimit", "status", "unable to purge buckets", "ERROR", err)
+	}
+}
+
+// seconds converts seconds into a duration rounded up to the second, which is
+// the precision of the headers reporting it.
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s)) * time.Second
+}
+
+// take refills the tokens for the time elapsed, up to the burst of the limit,
+// and takes one when there is one.
+func take(tokens float64, elapsed time.Duration, limit Limit) (float64, bool) {
+	if elapsed > 0 {
+		tokens = math.Min(float64(limit.burst()), tokens+elapsed.Seconds()*limit.rate())
+	}
+
+	if tokens < 1 {
+		return tokens, false
+	}
+
+	return tokens - 1, true
+}
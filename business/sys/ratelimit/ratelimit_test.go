@@ -0,0 +1,115 @@
+package ratelimit_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/business/sys/ratelimit"
+	"github.com/ardanlabs/service/foundation/docker"
+	"go.uber.org/zap"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+var c *docker.Container
+
+// TestMain starts a database for the Postgres store. The memory store is
+// tested whether the database starts or not.
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		m.Run()
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func TestMemory(t *testing.T) {
+	testLimiter(t, zap.NewNop().Sugar(), ratelimit.NewMemory())
+}
+
+func TestPostgres(t *testing.T) {
+	if c == nil {
+		t.Skip("database is not available")
+	}
+
+	log, db, teardown := dbtest.NewUnit(t, c, "testratelimit")
+	t.Cleanup(teardown)
+
+	testLimiter(t, log, ratelimit.NewPostgres(log, db))
+}
+
+func testLimiter(t *testing.T, log *zap.SugaredLogger, store ratelimit.Store) {
+	cfg := ratelimit.Config{
+		Default: ratelimit.Limit{Requests: 60, Period: time.Minute, Burst: 3},
+		Routes: map[string]ratelimit.Limit{
+			"POST /import": {Requests: 1, Period: time.Minute},
+		},
+	}
+
+	ctx := context.Background()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	l := ratelimit.New(log, store, cfg)
+
+	t.Log("Given the need to limit the rate of requests of clients.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a client uses up its burst.", testID)
+		{
+			for i := 2; i >= 0; i-- {
+				res := l.Allow(ctx, "GET /users", "ip:10.0.0.1", now)
+				if !res.Allowed || res.Remaining != i {
+					t.Fatalf("\t%s\tTest %d:\tShould allow the request with %d remaining : %+v.", failed, testID, i, res)
+				}
+				t.Logf("\t%s\tTest %d:\tShould allow the request with %d remaining.", success, testID, i)
+			}
+
+			res := l.Allow(ctx, "GET /users", "ip:10.0.0.1", now)
+			if res.Allowed || res.RetryAfter != time.Second || res.Reset != 3*time.Second {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the request until the next token : %+v.", failed, testID, res)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the request until the next token.", success, testID)
+
+			if res := l.Allow(ctx, "GET /users", "ip:10.0.0.2", now); !res.Allowed {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT limit other clients : %+v.", failed, testID, res)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT limit other clients.", success, testID)
+
+			if res := l.Allow(ctx, "GET /users", "ip:10.0.0.1", now.Add(time.Second)); !res.Allowed || res.Remaining != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould allow a request once a token is added : %+v.", failed, testID, res)
+			}
+			t.Logf("\t%s\tTest %d:\tShould allow a request once a token is added.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a route overrides the default limit.", testID)
+		{
+			if res := l.Allow(ctx, "POST /import", "user:1", now); !res.Allowed {
+				t.Fatalf("\t%s\tTest %d:\tShould allow the first request : %+v.", failed, testID, res)
+			}
+			t.Logf("\t%s\tTest %d:\tShould allow the first request.", success, testID)
+
+			res := l.Allow(ctx, "POST /import", "user:1", now)
+			if res.Allowed || res.RetryAfter != time.Minute || res.Limit.Requests != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould apply the limit of the route : %+v.", failed, testID, res)
+			}
+			t.Logf("\t%s\tTest %d:\tShould apply the limit of the route.", success, testID)
+
+			if res := l.Allow(ctx, "GET /users", "user:1", now); !res.Allowed || res.Remaining != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the default bucket separate : %+v.", failed, testID, res)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the default bucket separate.", success, testID)
+		}
+	}
+}
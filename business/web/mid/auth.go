@@ -9,31 +9,65 @@ import (
 
 	"github.com/ardanlabs/service/business/sys/auth"
 	"github.com/ardanlabs/service/business/web/trusted"
+	"github.com/ardanlabs/service/foundation/tracer"
 	"github.com/ardanlabs/service/foundation/web"
 )
 
-// Authenticate validates a JWT from the `Authorization` header.
-func Authenticate(a *auth.Auth) web.Middleware {
+// Authenticate validates the credentials in the `Authorization` header, which
+// are either a JWT using the bearer scheme or an API key using the apikey
+// scheme. Both resolve to the claims of a user. Revoked JWTs are rejected.
+func Authenticate(a *auth.Auth, rv auth.Revoker, keys auth.APIKeyLookup) web.Middleware {
 
 	// This is the actual middleware function to be executed.
 	m := func(handler web.Handler) web.Handler {
 
 		// Create the handler that will be attached in the middleware chain.
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			ctx, span := tracer.Start(ctx, "mid.Authenticate")
+			defer span.End()
 
-			// Expecting: bearer <token>
+			v, err := web.GetValues(ctx)
+			if err != nil {
+				return web.NewShutdownError("web value missing from context")
+			}
+
+			// Expecting: bearer <token> or apikey <key>
 			authStr := r.Header.Get("authorization")
 
 			// Parse the authorization header.
 			parts := strings.Split(authStr, " ")
-			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-				err := errors.New("expected authorization header format: bearer <token>")
+			if len(parts) != 2 {
+				err := errors.New("expected authorization header format: bearer <token> or apikey <key>")
 				return trusted.NewRequestError(err, http.StatusUnauthorized)
 			}
 
-			// Validate the token is signed by us.
-			claims, err := a.ValidateToken(parts[1])
-			if err != nil {
+			var claims auth.Claims
+			switch strings.ToLower(parts[0]) {
+			case "bearer":
+
+				// Validate the token is signed by us.
+				claims, err = a.ValidateToken(parts[1])
+				if err != nil {
+					return trusted.NewRequestError(err, http.StatusUnauthorized)
+				}
+
+				// Validate the token has not been revoked.
+				if rv.Revoked(claims) {
+					err := errors.New("token has been revoked")
+					return trusted.NewRequestError(err, http.StatusUnauthorized)
+				}
+
+			case "apikey":
+				claims, err = keys.ClaimsByAPIKey(ctx, parts[1], v.Now)
+				if err != nil {
+					if errors.Is(err, auth.ErrInvalidAPIKey) {
+						return trusted.NewRequestError(err, http.StatusUnauthorized)
+					}
+					return fmt.Errorf("api key: %w", err)
+				}
+
+			default:
+				err := errors.New("expected authorization header format: bearer <token> or apikey <key>")
 				return trusted.NewRequestError(err, http.StatusUnauthorized)
 			}
 
@@ -59,6 +93,8 @@ func Authorize(roles ...string) web.Middleware {
 
 		// Create the handler that will be attached in the middleware chain.
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			ctx, span := tracer.Start(ctx, "mid.Authorize")
+			defer span.End()
 
 			// If the context is missing this value return failure.
 			claims, err := auth.GetClaims(ctx)
@@ -84,3 +120,104 @@ func Authorize(roles ...string) web.Middleware {
 
 	return m
 }
+
+// Policy makes the policy deciding which permissions the roles of a user
+// grant available to the middleware and handlers down the chain.
+func Policy(p auth.Policy) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			ctx, span := tracer.Start(ctx, "mid.Policy")
+			defer span.End()
+
+			ctx = auth.SetPolicy(ctx, p)
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// Require validates that the roles of an authenticated user grant the
+// specified permission.
+func Require(permission string) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			ctx, span := tracer.Start(ctx, "mid.Require")
+			defer span.End()
+
+			if err := require(ctx, permission); err != nil {
+				return err
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// RequireOrSelf validates that the roles of an authenticated user grant the
+// specified permission, unless the route parameter holds the id of the user.
+// This lets users access their own data without a permission for the data of
+// every user.
+func RequireOrSelf(param string, permission string) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			ctx, span := tracer.Start(ctx, "mid.RequireOrSelf")
+			defer span.End()
+
+			claims, err := auth.GetClaims(ctx)
+			if err == nil && claims.Subject == web.Param(r, param) {
+				return handler(ctx, w, r)
+			}
+
+			if err := require(ctx, permission); err != nil {
+				return err
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// require returns a request error when the claims in the context don't grant
+// the permission.
+func require(ctx context.Context, permission string) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return trusted.NewRequestError(
+			fmt.Errorf("you are not authorized for that action, no claims"),
+			http.StatusForbidden,
+		)
+	}
+
+	if !auth.Can(ctx, permission) {
+		return trusted.NewRequestError(
+			fmt.Errorf("you are not authorized for that action, claims[%v] permission[%s]", claims.Roles, permission),
+			http.StatusForbidden,
+		)
+	}
+
+	return nil
+}
@@ -2,10 +2,13 @@ package mid
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
+	"github.com/ardanlabs/service/business/sys/metrics"
 	"github.com/ardanlabs/service/business/sys/validate"
 	"github.com/ardanlabs/service/business/web/trusted"
+	"github.com/ardanlabs/service/foundation/tracer"
 	"github.com/ardanlabs/service/foundation/web"
 	"go.uber.org/zap"
 )
@@ -16,6 +19,8 @@ func Error(log *zap.SugaredLogger) web.Middleware {
 	m := func(handler web.Handler) web.Handler {
 
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			ctx, span := tracer.Start(ctx, "mid.Error")
+			defer span.End()
 
 			// If the context is missing this value, request the service
 			// to be shutdown gracefully.
@@ -29,6 +34,8 @@ func Error(log *zap.SugaredLogger) web.Middleware {
 
 				// Log the error.
 				log.Errorw("ERROR", "traceid", v.TraceID, "message", err)
+				span.RecordError(err)
+				metrics.AddErrors(ctx)
 
 				// Build out the error response.
 				var er trusted.ErrorResponse
@@ -49,6 +56,12 @@ func Error(log *zap.SugaredLogger) web.Middleware {
 					}
 					status = reqErr.Status
 
+				case errors.Is(err, web.ErrUnsupportedVersion):
+					er = trusted.ErrorResponse{
+						Error: err.Error(),
+					}
+					status = http.StatusNotAcceptable
+
 				default:
 					er = trusted.ErrorResponse{
 						Error: http.StatusText(http.StatusInternalServerError),
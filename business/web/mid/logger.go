@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/ardanlabs/service/foundation/tracer"
 	"github.com/ardanlabs/service/foundation/web"
 	"go.uber.org/zap"
 )
@@ -15,6 +16,8 @@ func Logger(log *zap.SugaredLogger) web.Middleware {
 	m := func(handler web.Handler) web.Handler {
 
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			ctx, span := tracer.Start(ctx, "mid.Logger")
+			defer span.End()
 
 			// If the context is missing this value, request the service
 			// to be shutdown gracefully.
@@ -3,12 +3,16 @@ package mid
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/ardanlabs/service/business/sys/metrics"
+	"github.com/ardanlabs/service/foundation/tracer"
 	"github.com/ardanlabs/service/foundation/web"
 )
 
-// Metrics updates program counters.
+// Metrics updates program counters and records how long each request took,
+// by route pattern, method and status code. It needs to run outside of the
+// Error middleware to see the status code of error responses.
 func Metrics() web.Middleware {
 
 	// This is the actual middleware function to be executed.
@@ -16,12 +20,27 @@ func Metrics() web.Middleware {
 
 		// Create the handler that will be attached in the middleware chain.
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			ctx, span := tracer.Start(ctx, "mid.Metrics")
+			defer span.End()
+
+			// If the context is missing this value, request the service
+			// to be shutdown gracefully.
+			v, err := web.GetValues(ctx)
+			if err != nil {
+				return web.NewShutdownError("web value missing from context")
+			}
 
 			// Add the metrics into the context for metric gathering.
 			ctx = metrics.Set(ctx)
 
+			metrics.AddInFlight(ctx, 1)
+			defer metrics.AddInFlight(ctx, -1)
+
+			// Count the bytes of the response body as they are written.
+			cw := countingWriter{ResponseWriter: w}
+
 			// Call the next handler.
-			err := handler(ctx, w, r)
+			err = handler(ctx, &cw, r)
 
 			// Handle updating the metrics that can be handled here.
 
@@ -29,10 +48,7 @@ func Metrics() web.Middleware {
 			metrics.AddRequests(ctx)
 			metrics.AddGoroutines(ctx)
 
-			// Increment if there is an error flowing through the request.
-			if err != nil {
-				metrics.AddErrors(ctx)
-			}
+			metrics.ObserveRequest(ctx, web.RoutePattern(r), r.Method, v.StatusCode, time.Since(v.Now), cw.written)
 
 			// Return the error so it can be handled further up the chain.
 			return err
@@ -43,3 +59,24 @@ func Metrics() web.Middleware {
 
 	return m
 }
+
+// countingWriter counts the bytes written to the response body.
+type countingWriter struct {
+	http.ResponseWriter
+	written int
+}
+
+// Write writes the data to the response, counting the bytes.
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.ResponseWriter.Write(b)
+	cw.written += n
+	return n, err
+}
+
+// Flush sends any buffered data to the client when the underlying writer
+// supports it.
+func (cw *countingWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
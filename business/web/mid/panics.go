@@ -7,6 +7,7 @@ import (
 	"runtime/debug"
 
 	"github.com/ardanlabs/service/business/sys/metrics"
+	"github.com/ardanlabs/service/foundation/tracer"
 	"github.com/ardanlabs/service/foundation/web"
 )
 
@@ -19,6 +20,8 @@ func Panics() web.Middleware {
 
 		// Create the handler that will be attached in the middleware chain.
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) (err error) {
+			ctx, span := tracer.Start(ctx, "mid.Panics")
+			defer span.End()
 
 			// Defer a function to recover from a panic and set the err return
 			// variable after the fact.
@@ -28,6 +31,7 @@ func Panics() web.Middleware {
 					// Stack trace will be provided.
 					trace := debug.Stack()
 					err = fmt.Errorf("PANIC [%v] TRACE[%s]", rec, string(trace))
+					span.RecordError(fmt.Errorf("PANIC [%v]", rec))
 
 					// Updates the metrics stored in the context.
 					metrics.AddPanics(ctx)
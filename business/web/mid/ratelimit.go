@@ -0,0 +1,91 @@
+package mid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/metrics"
+	"github.com/ardanlabs/service/business/sys/ratelimit"
+	"github.com/ardanlabs/service/business/web/trusted"
+	"github.com/ardanlabs/service/foundation/tracer"
+	"github.com/ardanlabs/service/foundation/web"
+)
+
+// RateLimit limits the rate of requests of each client. Requests made with
+// an API key are limited per key and other authenticated requests per user,
+// so it needs to run after Authenticate on the routes requiring it. Other
+// requests are limited per IP address of the client. The state of the client's limit
+// is reported with the RateLimit-* headers.
+func RateLimit(l *ratelimit.Limiter) web.Middleware {
+	return rateLimit(l, rateLimitKey)
+}
+
+// RateLimitIP limits the rate of requests of each client IP address, whoever
+// makes them. It runs ahead of Authenticate so requests with invalid tokens
+// or API keys, which RateLimit never sees, are limited too.
+func RateLimitIP(l *ratelimit.Limiter) web.Middleware {
+	key := func(ctx context.Context, r *http.Request) string {
+		return "authenticate ip:" + web.GetRemoteIP(ctx)
+	}
+
+	return rateLimit(l, key)
+}
+
+// rateLimit limits the rate of requests of the clients identified by the key
+// function.
+func rateLimit(l *ratelimit.Limiter, key func(ctx context.Context, r *http.Request) string) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			ctx, span := tracer.Start(ctx, "mid.RateLimit")
+			defer span.End()
+
+			v, err := web.GetValues(ctx)
+			if err != nil {
+				return web.NewShutdownError("web value missing from context")
+			}
+
+			res := l.Allow(ctx, r.Method+" "+web.RoutePattern(r), key(ctx, r), v.Now)
+
+			if !res.Limit.Unlimited() {
+				hdr := w.Header()
+				hdr.Set("RateLimit-Limit", strconv.Itoa(res.Limit.Requests))
+				hdr.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+				hdr.Set("RateLimit-Reset", strconv.Itoa(int(res.Reset.Seconds())))
+				hdr.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", res.Limit.Requests, int(res.Limit.Period.Seconds())))
+			}
+
+			if !res.Allowed {
+				metrics.AddRateLimited(ctx)
+				w.Header().Set("Retry-After", strconv.Itoa(int(res.RetryAfter.Seconds())))
+				return trusted.NewRequestError(ratelimit.ErrLimited, http.StatusTooManyRequests)
+			}
+
+			// Call the next handler.
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// rateLimitKey identifies the client making the request.
+func rateLimitKey(ctx context.Context, r *http.Request) string {
+	claims, err := auth.GetClaims(ctx)
+	switch {
+	case err != nil:
+		return "ip:" + web.GetRemoteIP(ctx)
+	case claims.APIKeyID != "":
+		return "apikey:" + claims.APIKeyID
+	default:
+		return "user:" + claims.Subject
+	}
+}
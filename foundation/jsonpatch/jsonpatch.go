@@ -0,0 +1,465 @@
+// Package jsonpatch applies patches to JSON documents, either as a JSON Merge
+// Patch described in RFC 7396 or as a JSON Patch described in RFC 6902.
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Set of errors returned when a patch can't be applied. ErrInvalidPatch means
+// the patch itself is malformed, the others that it doesn't fit the document.
+var (
+	ErrInvalidPatch = errors.New("invalid patch")
+	ErrPathNotFound = errors.New("path not found")
+	ErrTestFailed   = errors.New("test failed")
+)
+
+// MergePatch applies a JSON Merge Patch to the document and returns the
+// patched document. Members of the patch replace the members of the document
+// and a null member removes it.
+func MergePatch(doc []byte, patch []byte) ([]byte, error) {
+	p, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("decoding patch: %v: %w", err, ErrInvalidPatch)
+	}
+
+	d, err := decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("decoding document: %w", err)
+	}
+
+	return json.Marshal(mergePatch(d, p))
+}
+
+// mergePatch implements the MergePatch function of RFC 7396 section 2.
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+			continue
+		}
+		t[name] = mergePatch(t[name], value)
+	}
+
+	return t
+}
+
+// =============================================================================
+
+// operation is a single operation of a JSON Patch.
+type operation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Apply applies a JSON Patch to the document and returns the patched
+// document. The operations are applied in order and if any of them fails the
+// patch fails as a whole.
+func Apply(doc []byte, patch []byte) ([]byte, error) {
+	var ops []operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("decoding patch: %v: %w", err, ErrInvalidPatch)
+	}
+
+	d, err := decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("decoding document: %w", err)
+	}
+
+	for i, op := range ops {
+		d, err = op.apply(d)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d %q: %w", i, op.Op, err)
+		}
+	}
+
+	return json.Marshal(d)
+}
+
+// apply applies the operation to the document and returns the new document.
+func (op operation) apply(doc interface{}) (interface{}, error) {
+	if op.Path == nil {
+		return nil, fmt.Errorf("missing path: %w", ErrInvalidPatch)
+	}
+
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("missing value: %w", ErrInvalidPatch)
+		}
+		value, err := decode(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("decoding value: %v: %w", err, ErrInvalidPatch)
+		}
+
+		switch op.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			return replace(doc, path, value)
+		}
+
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(current, value) {
+			return nil, fmt.Errorf("%s: %w", *op.Path, ErrTestFailed)
+		}
+		return doc, nil
+
+	case "remove":
+		doc, _, err := remove(doc, path)
+		return doc, err
+
+	case "move", "copy":
+		if op.From == nil {
+			return nil, fmt.Errorf("missing from: %w", ErrInvalidPatch)
+		}
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "copy" {
+			value, err := get(doc, from)
+			if err != nil {
+				return nil, err
+			}
+			return add(doc, path, deepCopy(value))
+		}
+
+		// A value can't be moved into one of its own children.
+		if len(from) < len(path) && isPrefix(from, path) {
+			return nil, fmt.Errorf("moving %s into itself: %w", *op.From, ErrInvalidPatch)
+		}
+
+		doc, value, err := remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	}
+
+	return nil, fmt.Errorf("unknown op %q: %w", op.Op, ErrInvalidPatch)
+}
+
+// =============================================================================
+
+// parsePointer splits a JSON Pointer as described in RFC 6901 into its
+// unescaped reference tokens. The empty pointer refers to the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer %q: %w", pointer, ErrInvalidPatch)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+// isPrefix reports if the prefix tokens start the path.
+func isPrefix(prefix []string, path []string) bool {
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// index parses an array index token. The index may be one past the end of
+// the array when the array is being added to, and "-" refers to that
+// position as well.
+func index(token string, length int, adding bool) (int, error) {
+	if adding && token == "-" {
+		return length, nil
+	}
+
+	// Leading zeros are not allowed in an index.
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("index %q: %w", token, ErrPathNotFound)
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("index %q: %w", token, ErrPathNotFound)
+	}
+
+	max := length - 1
+	if adding {
+		max = length
+	}
+	if i > max {
+		return 0, fmt.Errorf("index %d: %w", i, ErrPathNotFound)
+	}
+
+	return i, nil
+}
+
+// get returns the value at the path.
+func get(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, exists := node[token]
+			if !exists {
+				return nil, fmt.Errorf("member %q: %w", token, ErrPathNotFound)
+			}
+			doc = value
+
+		case []interface{}:
+			i, err := index(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+
+		default:
+			return nil, fmt.Errorf("%q: %w", token, ErrPathNotFound)
+		}
+	}
+
+	return doc, nil
+}
+
+// update walks the document down to the parent of the last token of the path
+// and replaces the parent with the one returned by fn. It returns the new
+// document.
+func update(doc interface{}, path []string, fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, exists := node[path[0]]
+		if !exists {
+			return nil, fmt.Errorf("member %q: %w", path[0], ErrPathNotFound)
+		}
+		child, err := update(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		node[path[0]] = child
+		return node, nil
+
+	case []interface{}:
+		i, err := index(path[0], len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := update(node[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+	}
+
+	return nil, fmt.Errorf("%q: %w", path[0], ErrPathNotFound)
+}
+
+// add adds the value at the path. A member is added or replaced, while an
+// array element is inserted.
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return update(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+
+		case []interface{}:
+			i, err := index(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+
+		return nil, fmt.Errorf("%q: %w", token, ErrPathNotFound)
+	})
+}
+
+// replace replaces the value at the path, which must exist.
+func replace(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return update(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			if _, exists := node[token]; !exists {
+				return nil, fmt.Errorf("member %q: %w", token, ErrPathNotFound)
+			}
+			node[token] = value
+			return node, nil
+
+		case []interface{}:
+			i, err := index(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			node[i] = value
+			return node, nil
+		}
+
+		return nil, fmt.Errorf("%q: %w", token, ErrPathNotFound)
+	})
+}
+
+// remove removes the value at the path, which must exist. It returns the new
+// document and the value removed.
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("removing the whole document: %w", ErrInvalidPatch)
+	}
+
+	var removed interface{}
+	doc, err := update(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			value, exists := node[token]
+			if !exists {
+				return nil, fmt.Errorf("member %q: %w", token, ErrPathNotFound)
+			}
+			removed = value
+			delete(node, token)
+			return node, nil
+
+		case []interface{}:
+			i, err := index(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[i]
+			return append(node[:i], node[i+1:]...), nil
+		}
+
+		return nil, fmt.Errorf("%q: %w", token, ErrPathNotFound)
+	})
+
+	return doc, removed, err
+}
+
+// =============================================================================
+
+// decode decodes a JSON value keeping numbers exact.
+func decode(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	if dec.More() {
+		return nil, errors.New("unexpected data after the value")
+	}
+
+	return v, nil
+}
+
+// deepCopy copies a decoded JSON value so the copy can be changed on its own.
+func deepCopy(v interface{}) interface{} {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(node))
+		for name, value := range node {
+			c[name] = deepCopy(value)
+		}
+		return c
+
+	case []interface{}:
+		c := make([]interface{}, len(node))
+		for i, value := range node {
+			c[i] = deepCopy(value)
+		}
+		return c
+	}
+
+	return v
+}
+
+// equal compares decoded JSON values as described for the test operation in
+// RFC 6902 section 4.6, so numbers are equal when their values are.
+func equal(a interface{}, b interface{}) bool {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for name, value := range x {
+			other, exists := y[name]
+			if !exists || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if x == y {
+			return true
+		}
+		fx, errx := x.Float64()
+		fy, erry := y.Float64()
+		return errx == nil && erry == nil && fx == fy
+	}
+
+	return a == b
+}
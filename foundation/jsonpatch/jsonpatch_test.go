@@ -0,0 +1,129 @@
+package jsonpatch_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ardanlabs/service/foundation/jsonpatch"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestMergePatch(t *testing.T) {
+
+	// The examples from RFC 7396 appendix A.
+	tests := []struct {
+		doc   string
+		patch string
+		exp   string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	t.Log("Given the need to apply JSON Merge Patches.")
+	{
+		for testID, tt := range tests {
+			t.Logf("\tTest %d:\tWhen applying %s to %s.", testID, tt.patch, tt.doc)
+			{
+				got, err := jsonpatch.MergePatch([]byte(tt.doc), []byte(tt.patch))
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to apply the patch : %v.", failed, testID, err)
+				}
+
+				if !sameJSON(t, got, tt.exp) {
+					t.Fatalf("\t%s\tTest %d:\tShould match the RFC example : got %s, exp %s.", failed, testID, got, tt.exp)
+				}
+				t.Logf("\t%s\tTest %d:\tShould match the RFC example.", success, testID)
+			}
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+
+	// Mostly the examples from RFC 6902 appendix A.
+	tests := []struct {
+		doc   string
+		patch string
+		exp   string
+		err   error
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`, nil},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, nil},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`, nil},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, nil},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`, nil},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, nil},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`, nil},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`, `{"baz":"qux","foo":["a",2,"c"]}`, nil},
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ``, jsonpatch.ErrTestFailed},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`, nil},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ``, jsonpatch.ErrPathNotFound},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`, nil},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`, nil},
+		{`{"foo":"bar"}`, `[{"op":"copy","from":"/foo","path":"/baz"}]`, `{"foo":"bar","baz":"bar"}`, nil},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"},{"op":"test","path":"/foo","value":"no"}]`, ``, jsonpatch.ErrTestFailed},
+		{`{"foo":"bar"}`, `[{"op":"move","from":"/foo","path":"/foo/bar"}]`, ``, jsonpatch.ErrInvalidPatch},
+		{`{"foo":"bar"}`, `[{"op":"launch","path":"/foo"}]`, ``, jsonpatch.ErrInvalidPatch},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz"}]`, ``, jsonpatch.ErrInvalidPatch},
+		{`{"foo":[1]}`, `[{"op":"add","path":"/foo/01","value":2}]`, ``, jsonpatch.ErrPathNotFound},
+	}
+
+	t.Log("Given the need to apply JSON Patches.")
+	{
+		for testID, tt := range tests {
+			t.Logf("\tTest %d:\tWhen applying %s to %s.", testID, tt.patch, tt.doc)
+			{
+				got, err := jsonpatch.Apply([]byte(tt.doc), []byte(tt.patch))
+				if tt.err != nil {
+					if !errors.Is(err, tt.err) {
+						t.Fatalf("\t%s\tTest %d:\tShould fail with %q : %v.", failed, testID, tt.err, err)
+					}
+					t.Logf("\t%s\tTest %d:\tShould fail with %q.", success, testID, tt.err)
+					continue
+				}
+
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to apply the patch : %v.", failed, testID, err)
+				}
+
+				if !sameJSON(t, got, tt.exp) {
+					t.Fatalf("\t%s\tTest %d:\tShould get the patched document : got %s, exp %s.", failed, testID, got, tt.exp)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get the patched document.", success, testID)
+			}
+		}
+	}
+}
+
+// sameJSON reports if both documents hold the same JSON value.
+func sameJSON(t *testing.T, got []byte, exp string) bool {
+	var g, e interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("decoding %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(exp), &e); err != nil {
+		t.Fatalf("decoding %s: %v", exp, err)
+	}
+	return reflect.DeepEqual(g, e)
+}
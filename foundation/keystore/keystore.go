@@ -3,7 +3,12 @@
 package keystore
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
@@ -11,26 +16,25 @@ import (
 	"path"
 	"strings"
 	"sync"
-
-	"github.com/golang-jwt/jwt/v4"
 )
 
 // KeyStore represents an in memory store implementation of the
 // KeyLookup interface for use with the auth package.
 type KeyStore struct {
 	mu    sync.RWMutex
-	store map[string]*rsa.PrivateKey
+	store map[string]crypto.Signer
+	fsys  fs.FS
 }
 
 // New constructs an empty KeyStore ready for use.
 func New() *KeyStore {
 	return &KeyStore{
-		store: make(map[string]*rsa.PrivateKey),
+		store: make(map[string]crypto.Signer),
 	}
 }
 
 // NewMap constructs a KeyStore with an initial set of keys.
-func NewMap(store map[string]*rsa.PrivateKey) *KeyStore {
+func NewMap(store map[string]crypto.Signer) *KeyStore {
 	return &KeyStore{
 		store: store,
 	}
@@ -41,7 +45,118 @@ func NewMap(store map[string]*rsa.PrivateKey) *KeyStore {
 // Example: keystore.NewFS(os.DirFS("/zarf/keys/"))
 // Example: /zarf/keys/54bb2165-71e1-41a6-af3e-7da4a0e1e2c1.pem
 func NewFS(fsys fs.FS) (*KeyStore, error) {
-	ks := New()
+	store, err := readFS(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := KeyStore{
+		store: store,
+		fsys:  fsys,
+	}
+
+	return &ks, nil
+}
+
+// Reload reads the PEM files from the file system the KeyStore was
+// constructed with and replaces the keys in the store. Keys whose files were
+// removed are no longer available. If any file can't be read, or any of the
+// required keys was removed, the store is left untouched.
+func (ks *KeyStore) Reload(required ...string) error {
+	if ks.fsys == nil {
+		return errors.New("key store is not backed by a file system")
+	}
+
+	store, err := readFS(ks.fsys)
+	if err != nil {
+		return err
+	}
+
+	for _, kid := range required {
+		if _, exists := store[kid]; !exists {
+			return fmt.Errorf("required key %s is missing", kid)
+		}
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.store = store
+	return nil
+}
+
+// Add adds a private key and combination kid to the store.
+func (ks *KeyStore) Add(privateKey crypto.Signer, kid string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.store[kid] = privateKey
+}
+
+// Remove removes a private key and combination kid to the store.
+func (ks *KeyStore) Remove(kid string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	delete(ks.store, kid)
+}
+
+// PrivateKey searches the key store for a given kid and returns
+// the private key.
+func (ks *KeyStore) PrivateKey(kid string) (crypto.Signer, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	privateKey, found := ks.store[kid]
+	if !found {
+		return nil, errors.New("kid lookup failed")
+	}
+	return privateKey, nil
+}
+
+// PublicKey searches the key store for a given kid and returns
+// the public key.
+func (ks *KeyStore) PublicKey(kid string) (crypto.PublicKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	privateKey, found := ks.store[kid]
+	if !found {
+		return nil, errors.New("kid lookup failed")
+	}
+	return privateKey.Public(), nil
+}
+
+// Algorithm searches the key store for a given kid and returns the name of
+// the JWT signing algorithm to use with the key.
+func (ks *KeyStore) Algorithm(kid string) (string, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	privateKey, found := ks.store[kid]
+	if !found {
+		return "", errors.New("kid lookup failed")
+	}
+	return algorithm(privateKey)
+}
+
+// KIDs returns the key ids of all the keys in the store.
+func (ks *KeyStore) KIDs() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	kids := make([]string, 0, len(ks.store))
+	for kid := range ks.store {
+		kids = append(kids, kid)
+	}
+	return kids
+}
+
+// =============================================================================
+
+// readFS reads all the PEM files rooted inside of the file system.
+func readFS(fsys fs.FS) (map[string]crypto.Signer, error) {
+	store := make(map[string]crypto.Signer)
 
 	fn := func(fileName string, dirEntry fs.DirEntry, err error) error {
 		if err != nil {
@@ -70,12 +185,12 @@ func NewFS(fsys fs.FS) (*KeyStore, error) {
 			return fmt.Errorf("reading auth private key: %w", err)
 		}
 
-		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
+		privateKey, err := parsePrivateKey(privatePEM)
 		if err != nil {
-			return fmt.Errorf("parsing auth private key: %w", err)
+			return fmt.Errorf("parsing auth private key %s: %w", fileName, err)
 		}
 
-		ks.store[strings.TrimSuffix(dirEntry.Name(), ".pem")] = privateKey
+		store[strings.TrimSuffix(dirEntry.Name(), ".pem")] = privateKey
 		return nil
 	}
 
@@ -83,47 +198,67 @@ func NewFS(fsys fs.FS) (*KeyStore, error) {
 		return nil, fmt.Errorf("walking directory: %w", err)
 	}
 
-	return ks, nil
+	return store, nil
 }
 
-// Add adds a private key and combination kid to the store.
-func (ks *KeyStore) Add(privateKey *rsa.PrivateKey, kid string) {
-	ks.mu.Lock()
-	defer ks.mu.Unlock()
-
-	ks.store[kid] = privateKey
-}
-
-// Remove removes a private key and combination kid to the store.
-func (ks *KeyStore) Remove(kid string) {
-	ks.mu.Lock()
-	defer ks.mu.Unlock()
+// parsePrivateKey parses a PEM encoded private key. RSA keys can be in
+// PKCS#1 or PKCS#8 form, ECDSA keys in SEC 1 or PKCS#8 form and Ed25519 keys
+// in PKCS#8 form.
+func parsePrivateKey(privatePEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(privatePEM)
+	if block == nil {
+		return nil, errors.New("key must be PEM encoded")
+	}
 
-	delete(ks.store, kid)
-}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-// PrivateKey searches the key store for a given kid and returns
-// the private key.
-func (ks *KeyStore) PrivateKey(kid string) (*rsa.PrivateKey, error) {
-	ks.mu.RLock()
-	defer ks.mu.RUnlock()
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("key is not a signing key")
+	}
 
-	privateKey, found := ks.store[kid]
-	if !found {
-		return nil, errors.New("kid lookup failed")
+	if _, err := algorithm(signer); err != nil {
+		return nil, err
 	}
-	return privateKey, nil
+
+	return signer, nil
 }
 
-// PublicKey searches the key store for a given kid and returns
-// the public key.
-func (ks *KeyStore) PublicKey(kid string) (*rsa.PublicKey, error) {
-	ks.mu.RLock()
-	defer ks.mu.RUnlock()
+// algorithm returns the name of the JWT signing algorithm to use with the
+// specified key.
+func algorithm(privateKey crypto.Signer) (string, error) {
+	switch pk := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return "RS256", nil
 
-	privateKey, found := ks.store[kid]
-	if !found {
-		return nil, errors.New("kid lookup failed")
+	case *ecdsa.PrivateKey:
+		switch pk.Curve.Params().Name {
+		case "P-256":
+			return "ES256", nil
+		case "P-384":
+			return "ES384", nil
+		case "P-521":
+			return "ES512", nil
+		}
+		return "", fmt.Errorf("unsupported elliptic curve %s", pk.Curve.Params().Name)
+
+	case ed25519.PrivateKey:
+		return "EdDSA", nil
 	}
-	return &privateKey.PublicKey, nil
+
+	return "", fmt.Errorf("unsupported key type %T", privateKey)
 }
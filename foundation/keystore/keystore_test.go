@@ -1,8 +1,16 @@
 package keystore_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
 	"embed" // Calls init function.
+	"encoding/pem"
 	"testing"
+	"testing/fstest"
 
 	"github.com/ardanlabs/service/foundation/keystore"
 )
@@ -35,10 +43,113 @@ func TestRead(t *testing.T) {
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to find key in store.", success, testID)
 
-			if err := pk.Validate(); err != nil {
+			if err := pk.(*rsa.PrivateKey).Validate(); err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to validate the key: %v", failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to validate the key.", success, testID)
 		}
 	}
 }
+
+func TestReload(t *testing.T) {
+	t.Log("Given the need to pick up key file changes at runtime.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the key files change.", testID)
+		{
+			privatePEM, err := keyDocs.ReadFile("test.pem")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to read the key file: %v", failed, testID, err)
+			}
+
+			fsys := fstest.MapFS{
+				"test.pem": &fstest.MapFile{Data: privatePEM},
+			}
+
+			ks, err := keystore.NewFS(fsys)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to construct key store: %v", failed, testID, err)
+			}
+
+			delete(fsys, "test.pem")
+			fsys["rotated.pem"] = &fstest.MapFile{Data: privatePEM}
+
+			if err := ks.Reload(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reload the key store: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to reload the key store.", success, testID)
+
+			if _, err := ks.PrivateKey("rotated"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to find the new key: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to find the new key.", success, testID)
+
+			if _, err := ks.PrivateKey("test"); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to find the removed key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to find the removed key.", success, testID)
+
+			delete(fsys, "rotated.pem")
+
+			if err := ks.Reload("rotated"); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to reload without a required key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to reload without a required key.", success, testID)
+
+			if _, err := ks.PrivateKey("rotated"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the keys after a failed reload: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the keys after a failed reload.", success, testID)
+		}
+	}
+}
+
+func TestReadPKCS8(t *testing.T) {
+	t.Log("Given the need to parse elliptic curve private key files.")
+	{
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create an ECDSA key: %v", failed, err)
+		}
+
+		_, edKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create an Ed25519 key: %v", failed, err)
+		}
+
+		tt := []struct {
+			kid string
+			key interface{}
+			alg string
+		}{
+			{"ecdsa", ecKey, "ES256"},
+			{"eddsa", edKey, "EdDSA"},
+		}
+
+		for testID, tst := range tt {
+			t.Logf("\tTest %d:\tWhen handling a PKCS#8 %s key file.", testID, tst.alg)
+			{
+				der, err := x509.MarshalPKCS8PrivateKey(tst.key)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to marshal the key: %v", failed, testID, err)
+				}
+
+				fsys := fstest.MapFS{
+					tst.kid + ".pem": &fstest.MapFile{Data: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})},
+				}
+
+				ks, err := keystore.NewFS(fsys)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to construct key store: %v", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be able to construct key store.", success, testID)
+
+				alg, err := ks.Algorithm(tst.kid)
+				if err != nil || alg != tst.alg {
+					t.Fatalf("\t%s\tTest %d:\tShould report the %s algorithm for the key: %s %v", failed, testID, tst.alg, alg, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould report the %s algorithm for the key.", success, testID, tst.alg)
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// File writes emails to a folder instead of sending them and logs where
+// each one was written. It is meant for local development.
+type File struct {
+	log    *zap.SugaredLogger
+	folder string
+	from   string
+}
+
+// NewFile constructs a mailer that writes to the specified folder. If the
+// folder is empty the emails are only logged.
+func NewFile(log *zap.SugaredLogger, folder string, from string) *File {
+	return &File{
+		log:    log,
+		folder: folder,
+		from:   from,
+	}
+}
+
+// Send writes the message to a file named after the time it was sent.
+func (f *File) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data := format(f.from, msg, now)
+
+	if f.folder == "" {
+		f.log.Infow("mail", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+		return nil
+	}
+
+	if err := os.MkdirAll(f.folder, 0755); err != nil {
+		return fmt.Errorf("creating folder: %w", err)
+	}
+
+	name := filepath.Join(f.folder, fmt.Sprintf("%d.eml", now.UnixNano()))
+	if err := os.WriteFile(name, data, 0600); err != nil {
+		return fmt.Errorf("writing mail: %w", err)
+	}
+
+	f.log.Infow("mail", "to", msg.To, "subject", msg.Subject, "file", name)
+
+	return nil
+}
@@ -0,0 +1,40 @@
+// Package mail provides support for sending emails.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Message represents a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer declares the behavior for sending emails.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// format renders the message in the internet message format. Line breaks
+// are removed from the header values so a value can't inject headers.
+func format(from string, msg Message, now time.Time) []byte {
+	clean := strings.NewReplacer("\r", "", "\n", "")
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", clean.Replace(from))
+	fmt.Fprintf(&b, "To: %s\r\n", clean.Replace(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", clean.Replace(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return b.Bytes()
+}
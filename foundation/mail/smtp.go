@@ -0,0 +1,61 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPConfig represents the settings for sending emails through an SMTP
+// server.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	From     string
+}
+
+// SMTP sends emails through an SMTP server.
+type SMTP struct {
+	cfg SMTPConfig
+}
+
+// NewSMTP constructs a mailer for the specified SMTP server. The connection
+// is upgraded with STARTTLS when the server supports it, which is required
+// for authenticating.
+func NewSMTP(cfg SMTPConfig) *SMTP {
+	return &SMTP{
+		cfg: cfg,
+	}
+}
+
+// Send sends the message.
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+
+	var auth smtp.Auth
+	if s.cfg.User != "" {
+		auth = smtp.PlainAuth("", s.cfg.User, s.cfg.Password, s.cfg.Host)
+	}
+
+	// The smtp package doesn't support a context, so the call is abandoned
+	// when the context is done.
+	errs := make(chan error, 1)
+	go func() {
+		errs <- smtp.SendMail(addr, auth, s.cfg.From, []string{msg.To}, format(s.cfg.From, msg, time.Now()))
+	}()
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			return fmt.Errorf("sending mail: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("sending mail: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,336 @@
+// Package openapi generates an OpenAPI 3.1 document describing the routes
+// registered with a web.App, using the documentation of each route and the
+// Go types of the request and response bodies.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/ardanlabs/service/foundation/web"
+)
+
+// Version is the version of the OpenAPI specification documents follow.
+const Version = "3.1.0"
+
+// Names of the security schemes requests authenticate with.
+const (
+	SchemeBearer = "bearerAuth"
+	SchemeAPIKey = "apiKeyAuth"
+)
+
+// Info describes the API being documented.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Config is the required properties to generate a document. Error holds a
+// value of the type of the body of error responses, if there is one.
+type Config struct {
+	Info  Info
+	Error interface{}
+}
+
+// Document is an OpenAPI document. Only the parts used by this package are
+// modeled.
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+// Components holds the schemas of the named types used by the operations and
+// the security schemes.
+type Components struct {
+	Schemas         map[string]Schema `json:"schemas,omitempty"`
+	SecuritySchemes map[string]Schema `json:"securitySchemes,omitempty"`
+}
+
+// Operation describes a single method on a path.
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter describes a path or query string parameter.
+type Parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Schema      Schema `json:"schema"`
+}
+
+// RequestBody describes the body of a request.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body.
+type MediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+// Generate builds the document describing the routes.
+func Generate(cfg Config, routes []web.Route) (Document, error) {
+	g := newGenerator()
+
+	doc := Document{
+		OpenAPI: Version,
+		Info:    cfg.Info,
+		Paths:   make(map[string]map[string]Operation),
+		Components: Components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]Schema{
+				SchemeBearer: {"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				SchemeAPIKey: {"type": "apiKey", "in": "header", "name": "Authorization", "description": "ApiKey <key>"},
+			},
+		},
+	}
+
+	var errorSchema Schema
+	if cfg.Error != nil {
+		var err error
+		if errorSchema, err = g.schemaOf(typeOf(cfg.Error)); err != nil {
+			return Document{}, fmt.Errorf("error: %w", err)
+		}
+	}
+
+	for _, route := range routes {
+		path := specPath(route.Path)
+
+		op, err := g.operation(route, errorSchema)
+		if err != nil {
+			return Document{}, fmt.Errorf("%s %s: %w", route.Method, route.Path, err)
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]Operation)
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = op
+	}
+
+	return doc, nil
+}
+
+// Marshal generates the document describing the routes encoded as JSON.
+func Marshal(cfg Config, routes []web.Route) ([]byte, error) {
+	doc, err := Generate(cfg, routes)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// =============================================================================
+
+// operation describes the route as an operation.
+func (g *generator) operation(route web.Route, errorSchema Schema) (Operation, error) {
+	d := route.Doc
+
+	op := Operation{
+		OperationID: operationID(route.Method, route.Path),
+		Summary:     d.Summary,
+		Tags:        tags(route.Path),
+		Responses:   make(map[string]Response),
+	}
+
+	for _, name := range route.Params {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   Schema{"type": "string"},
+		})
+	}
+
+	for _, q := range d.Query {
+		typ := q.Type
+		if typ == "" {
+			typ = "string"
+		}
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:        q.Name,
+			In:          "query",
+			Description: q.Description,
+			Schema:      Schema{"type": typ},
+		})
+	}
+
+	if d.Request != nil {
+		s, err := g.schemaOf(typeOf(d.Request))
+		if err != nil {
+			return Operation{}, fmt.Errorf("request: %w", err)
+		}
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  jsonContent(s),
+		}
+	}
+
+	status := d.Status
+	if status == 0 {
+		status = http.StatusNoContent
+		if d.Response != nil {
+			status = http.StatusOK
+		}
+	}
+
+	resp := Response{
+		Description: http.StatusText(status),
+	}
+	if d.Response != nil {
+		s, err := g.schemaOf(typeOf(d.Response))
+		if err != nil {
+			return Operation{}, fmt.Errorf("response: %w", err)
+		}
+		resp.Content = jsonContent(s)
+	}
+	op.Responses[fmt.Sprint(status)] = resp
+
+	if errorSchema != nil {
+		op.Responses["default"] = Response{
+			Description: "Error",
+			Content:     jsonContent(errorSchema),
+		}
+	}
+
+	if !d.Public {
+
+		// Each security requirement of an operation is an alternative, which
+		// needs all of its scopes. So there is one for every way of meeting
+		// the requirements of the route, with either scheme.
+		sets := scopeSets(d.Requires)
+		for _, scheme := range []string{SchemeBearer, SchemeAPIKey} {
+			for _, scopes := range sets {
+				op.Security = append(op.Security, map[string][]string{scheme: scopes})
+			}
+		}
+
+		if len(d.Requires) > 0 {
+			op.Description = "Requires " + describeRequirements(d.Requires) + "."
+		}
+	}
+
+	return op, nil
+}
+
+// scopeSets returns every set of scopes meeting all the requirements, taking
+// one of the alternatives of each requirement.
+func scopeSets(reqs []web.Requirement) [][]string {
+	sets := [][]string{{}}
+	for _, req := range reqs {
+		if len(req) == 0 {
+			continue
+		}
+
+		var next [][]string
+		for _, set := range sets {
+			for _, scope := range req {
+				scopes := make([]string, len(set), len(set)+1)
+				copy(scopes, set)
+				next = append(next, append(scopes, scope))
+			}
+		}
+		sets = next
+	}
+	return sets
+}
+
+// describeRequirements describes the requirements in words, as requirements
+// that must all be met and are met by any of their alternatives.
+func describeRequirements(reqs []web.Requirement) string {
+	parts := make([]string, len(reqs))
+	for i, req := range reqs {
+		parts[i] = strings.Join(req, " or ")
+		if len(req) > 1 && len(reqs) > 1 {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, " and ")
+}
+
+// jsonContent returns the content of a JSON body with the schema.
+func jsonContent(s Schema) map[string]MediaType {
+	return map[string]MediaType{
+		"application/json": {Schema: s},
+	}
+}
+
+// specPath converts the parameters of a route path into the OpenAPI syntax.
+func specPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// operationID builds a unique name for the operation from the method and the
+// path, like getV1UsersId.
+func operationID(method string, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+
+	upper := true
+	for _, r := range path {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// tags groups the operation by the first segment of the path that isn't a
+// version.
+func tags(path string) []string {
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || isVersion(segment) {
+			continue
+		}
+		return []string{strings.TrimPrefix(segment, ".")}
+	}
+	return nil
+}
+
+// isVersion reports if the path segment is a version, like v1.
+func isVersion(segment string) bool {
+	if len(segment) < 2 || segment[0] != 'v' {
+		return false
+	}
+	for _, r := range segment[1:] {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,207 @@
+package openapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/foundation/openapi"
+	"github.com/ardanlabs/service/foundation/web"
+	"github.com/google/go-cmp/cmp"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type widget struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Parent      *widget   `json:"parent,omitempty"`
+	DateCreated time.Time `json:"date_created"`
+}
+
+type newWidget struct {
+	Name  string   `json:"name" validate:"required,min=3,max=20"`
+	Code  string   `json:"code" validate:"required,uppercase"`
+	Email *string  `json:"email" validate:"omitempty,email"`
+	Count int      `json:"count" validate:"gte=1,lt=10"`
+	Kind  string   `json:"kind" validate:"oneof=small large"`
+	Tags  []string `json:"tags" validate:"max=5,dive,min=1"`
+	Note  string   `json:"-"`
+}
+
+func TestGenerate(t *testing.T) {
+	app := web.NewApp(make(chan os.Signal, 1))
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error { return nil }
+
+	v1 := app.Version("v1")
+	v1.Handle(http.MethodGet, "/widgets/:id", handler).Describe(web.Doc{
+		Summary:  "Get a widget.",
+		Response: widget{},
+		Requires: []web.Requirement{{"widgets:read"}},
+	})
+	v1.Handle(http.MethodDelete, "/widgets/:id", handler).Describe(web.Doc{
+		Summary:  "Delete a widget.",
+		Requires: []web.Requirement{{"admin"}, {"widgets:write", "widgets:delete"}},
+	})
+	v1.Handle(http.MethodPost, "/widgets", handler).Describe(web.Doc{
+		Request:  newWidget{},
+		Response: widget{},
+		Status:   http.StatusCreated,
+	})
+	v1.Handle(http.MethodGet, "/status", handler).Describe(web.Doc{
+		Query:  []web.QueryParam{{Name: "verbose", Type: "boolean"}},
+		Public: true,
+	})
+
+	cfg := openapi.Config{
+		Info:  openapi.Info{Title: "Widgets", Version: "test"},
+		Error: errorResponse{},
+	}
+	doc, err := openapi.Generate(cfg, app.Routes())
+
+	t.Log("Given the need to document the routes of an application.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen generating the document.", testID)
+		{
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate the document : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to generate the document.", success, testID)
+
+			if doc.OpenAPI != openapi.Version {
+				t.Fatalf("\t%s\tTest %d:\tShould follow version %s of the specification : got %s.", failed, testID, openapi.Version, doc.OpenAPI)
+			}
+			t.Logf("\t%s\tTest %d:\tShould follow version %s of the specification.", success, testID, openapi.Version)
+
+			op, exists := doc.Paths["/v1/widgets/{id}"]["get"]
+			if !exists {
+				t.Fatalf("\t%s\tTest %d:\tShould document the path with its parameter : %v.", failed, testID, doc.Paths)
+			}
+			t.Logf("\t%s\tTest %d:\tShould document the path with its parameter.", success, testID)
+
+			if len(op.Parameters) != 1 || op.Parameters[0].Name != "id" || op.Parameters[0].In != "path" || !op.Parameters[0].Required {
+				t.Fatalf("\t%s\tTest %d:\tShould require the path parameter : %+v.", failed, testID, op.Parameters)
+			}
+			t.Logf("\t%s\tTest %d:\tShould require the path parameter.", success, testID)
+
+			if op.OperationID != "getV1WidgetsId" || len(op.Tags) != 1 || op.Tags[0] != "widgets" {
+				t.Fatalf("\t%s\tTest %d:\tShould name and tag the operation : %s %v.", failed, testID, op.OperationID, op.Tags)
+			}
+			t.Logf("\t%s\tTest %d:\tShould name and tag the operation.", success, testID)
+
+			if diff := cmp.Diff([]map[string][]string{{openapi.SchemeBearer: {"widgets:read"}}, {openapi.SchemeAPIKey: {"widgets:read"}}}, op.Security); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould require the roles of the route. Diff:\n%s", failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould require the roles of the route.", success, testID)
+
+			del := doc.Paths["/v1/widgets/{id}"]["delete"]
+			security := []map[string][]string{
+				{openapi.SchemeBearer: {"admin", "widgets:write"}},
+				{openapi.SchemeBearer: {"admin", "widgets:delete"}},
+				{openapi.SchemeAPIKey: {"admin", "widgets:write"}},
+				{openapi.SchemeAPIKey: {"admin", "widgets:delete"}},
+			}
+			if diff := cmp.Diff(security, del.Security); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould require every requirement of the route, met by any of its alternatives. Diff:\n%s", failed, testID, diff)
+			}
+			if del.Description != "Requires admin and (widgets:write or widgets:delete)." {
+				t.Fatalf("\t%s\tTest %d:\tShould describe every requirement of the route : %s.", failed, testID, del.Description)
+			}
+			t.Logf("\t%s\tTest %d:\tShould require every requirement of the route, met by any of its alternatives.", success, testID)
+
+			if _, exists := op.Responses["200"]; !exists {
+				t.Fatalf("\t%s\tTest %d:\tShould default to a 200 response : %v.", failed, testID, op.Responses)
+			}
+			if _, exists := op.Responses["default"]; !exists {
+				t.Fatalf("\t%s\tTest %d:\tShould document the error response : %v.", failed, testID, op.Responses)
+			}
+			t.Logf("\t%s\tTest %d:\tShould document the responses.", success, testID)
+
+			if _, exists := doc.Paths["/v1/widgets"]["post"].Responses["201"]; !exists {
+				t.Fatalf("\t%s\tTest %d:\tShould use the documented status.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould use the documented status.", success, testID)
+
+			status := doc.Paths["/v1/status"]["get"]
+			if status.Security != nil || status.Parameters[0].Schema["type"] != "boolean" {
+				t.Fatalf("\t%s\tTest %d:\tShould document public routes and query parameters : %+v.", failed, testID, status)
+			}
+			t.Logf("\t%s\tTest %d:\tShould document public routes and query parameters.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen describing the types of the bodies.", testID)
+		{
+			var got map[string]interface{}
+			if err := roundTrip(doc.Components.Schemas["openapi_test.newWidget"], &got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to encode the schema : %s.", failed, testID, err)
+			}
+
+			exp := map[string]interface{}{
+				"type":     "object",
+				"required": []interface{}{"name", "code"},
+				"properties": map[string]interface{}{
+					"name":  map[string]interface{}{"type": "string", "minLength": 3.0, "maxLength": 20.0},
+					"code":  map[string]interface{}{"type": "string", "pattern": "^[^a-z]*$"},
+					"email": map[string]interface{}{"type": []interface{}{"string", "null"}, "format": "email"},
+					"count": map[string]interface{}{"type": "integer", "minimum": 1.0, "exclusiveMaximum": 10.0},
+					"kind":  map[string]interface{}{"type": "string", "enum": []interface{}{"small", "large"}},
+					"tags": map[string]interface{}{
+						"type":     "array",
+						"maxItems": 5.0,
+						"items":    map[string]interface{}{"type": "string", "minLength": 1.0},
+					},
+				},
+			}
+			if diff := cmp.Diff(exp, got); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould translate the validate tags into constraints. Diff:\n%s", failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould translate the validate tags into constraints.", success, testID)
+
+			var w map[string]interface{}
+			if err := roundTrip(doc.Components.Schemas["openapi_test.widget"], &w); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to encode the schema : %s.", failed, testID, err)
+			}
+
+			props := w["properties"].(map[string]interface{})
+			parent := map[string]interface{}{
+				"anyOf": []interface{}{
+					map[string]interface{}{"$ref": "#/components/schemas/openapi_test.widget"},
+					map[string]interface{}{"type": "null"},
+				},
+			}
+			if diff := cmp.Diff(parent, props["parent"]); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould reference recursive types. Diff:\n%s", failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reference recursive types.", success, testID)
+
+			date := map[string]interface{}{"type": "string", "format": "date-time"}
+			if diff := cmp.Diff(date, props["date_created"]); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould describe times as date-time strings. Diff:\n%s", failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould describe times as date-time strings.", success, testID)
+		}
+	}
+}
+
+// roundTrip encodes the value as JSON and decodes it into v, so values can be
+// compared as they are served.
+func roundTrip(value interface{}, v interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
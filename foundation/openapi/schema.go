@@ -0,0 +1,363 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema describing a value.
+type Schema map[string]interface{}
+
+// Types given a schema of their own rather than derived from their kind.
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+	byteType = reflect.TypeOf([]byte{})
+)
+
+// generator builds the schemas of Go types, collecting the schemas of named
+// struct types so operations can reference them.
+type generator struct {
+	schemas map[string]Schema
+}
+
+// newGenerator constructs a generator with no schemas.
+func newGenerator() *generator {
+	return &generator{
+		schemas: make(map[string]Schema),
+	}
+}
+
+// typeOf returns the type of the value, without the pointers to it.
+func typeOf(v interface{}) reflect.Type {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// schemaOf returns the schema of the type. Named struct types are added to
+// the collected schemas and referenced.
+func (g *generator) schemaOf(t reflect.Type) (Schema, error) {
+	switch t {
+	case timeType:
+		return Schema{"type": "string", "format": "date-time"}, nil
+	case rawType:
+		return Schema{}, nil
+	case byteType:
+		return Schema{"type": "string", "contentEncoding": "base64"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}, nil
+
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}, nil
+
+	case reflect.String:
+		return Schema{"type": "string"}, nil
+
+	case reflect.Interface:
+		return Schema{}, nil
+
+	case reflect.Ptr:
+		s, err := g.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return nullable(s), nil
+
+	case reflect.Slice, reflect.Array:
+		items, err := g.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return Schema{"type": "array", "items": items}, nil
+
+	case reflect.Map:
+		values, err := g.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return Schema{"type": "object", "additionalProperties": values}, nil
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return g.ref(t)
+	}
+
+	return nil, fmt.Errorf("type %s can't be described", t)
+}
+
+// ref returns a reference to the schema of the named struct type, adding the
+// schema to the collected ones the first time the type is seen.
+func (g *generator) ref(t reflect.Type) (Schema, error) {
+	name := path.Base(t.PkgPath()) + "." + t.Name()
+	ref := Schema{"$ref": "#/components/schemas/" + name}
+
+	if _, exists := g.schemas[name]; exists {
+		return ref, nil
+	}
+
+	// Reserve the name first so a type referring to itself terminates.
+	g.schemas[name] = Schema{}
+
+	s, err := g.object(t)
+	if err != nil {
+		return nil, err
+	}
+	g.schemas[name] = s
+
+	return ref, nil
+}
+
+// object returns the schema of the struct type, with the properties named
+// as encoding/json names them and constrained by their validate tags.
+func (g *generator) object(t reflect.Type) (Schema, error) {
+	props := make(map[string]interface{})
+	var required []string
+
+	if err := g.fields(t, props, &required); err != nil {
+		return nil, err
+	}
+
+	s := Schema{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+
+	return s, nil
+}
+
+// fields adds the properties of the fields of the struct type, flattening
+// embedded structs the way encoding/json does.
+func (g *generator) fields(t reflect.Type, props map[string]interface{}, required *[]string) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.SplitN(tag, ",", 2)[0]
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := g.fields(ft, props, required); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s, isRequired, err := g.field(f.Type, strings.Split(f.Tag.Get("validate"), ","))
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+
+		props[name] = s
+		if isRequired {
+			*required = append(*required, name)
+		}
+	}
+
+	return nil
+}
+
+// field returns the schema of a field of the type, constrained by its
+// validate rules, and if the rules require the field.
+func (g *generator) field(t reflect.Type, rules []string) (Schema, bool, error) {
+
+	// The rules of a pointer field apply to the value it points to.
+	if t.Kind() == reflect.Ptr {
+		s, isRequired, err := g.field(t.Elem(), rules)
+		if err != nil {
+			return nil, false, err
+		}
+		return nullable(s), isRequired, nil
+	}
+
+	// The rules after dive apply to the elements of a slice.
+	for i, rule := range rules {
+		if rule != "dive" || (t.Kind() != reflect.Slice && t.Kind() != reflect.Array) {
+			continue
+		}
+
+		items, _, err := g.field(t.Elem(), rules[i+1:])
+		if err != nil {
+			return nil, false, err
+		}
+
+		s := Schema{"type": "array", "items": items}
+		return s, constrain(s, t, rules[:i]), nil
+	}
+
+	s, err := g.schemaOf(t)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return s, constrain(s, t, rules), nil
+}
+
+// constrain adds the constraints of the validate rules the schema can
+// express to the schema of the type, and reports if the rules require a
+// value. Rules without an equivalent, like eqfield, are left out.
+func constrain(s Schema, t reflect.Type, rules []string) bool {
+	var required bool
+
+	for _, rule := range rules {
+		tag, param := rule, ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			tag, param = rule[:i], rule[i+1:]
+		}
+
+		switch tag {
+		case "required":
+			required = true
+
+		case "email":
+			s["format"] = "email"
+		case "url", "uri":
+			s["format"] = "uri"
+		case "uuid", "uuid4":
+			s["format"] = "uuid"
+		case "ip":
+			s["format"] = "ipv4"
+
+		case "uppercase":
+			s["pattern"] = "^[^a-z]*$"
+		case "lowercase":
+			s["pattern"] = "^[^A-Z]*$"
+		case "alpha":
+			s["pattern"] = "^[a-zA-Z]+$"
+		case "alphanum":
+			s["pattern"] = "^[a-zA-Z0-9]+$"
+		case "numeric":
+			s["pattern"] = "^[-+]?[0-9]+(?:\\.[0-9]+)?$"
+
+		case "oneof":
+			var enum []interface{}
+			for _, v := range strings.Fields(param) {
+				enum = append(enum, enumValue(t, v))
+			}
+			s["enum"] = enum
+
+		case "min", "gte":
+			bound(s, t, "min", param, 0)
+		case "max", "lte":
+			bound(s, t, "max", param, 0)
+		case "len":
+			bound(s, t, "min", param, 0)
+			bound(s, t, "max", param, 0)
+		case "gt":
+			bound(s, t, "exclusiveMin", param, 1)
+		case "lt":
+			bound(s, t, "exclusiveMax", param, -1)
+		}
+	}
+
+	return required
+}
+
+// bound sets the bound of the kind on the length of strings, the number of
+// items of slices and properties of maps, and the value of numbers. Lengths
+// are whole, so the exclusive bounds on them are moved by offset.
+func bound(s Schema, t reflect.Type, kind string, param string, offset int) {
+	exclusive := strings.HasPrefix(kind, "exclusive")
+	isMin := strings.HasSuffix(kind, "Min") || kind == "min"
+
+	keyword := func(min string, max string) string {
+		if isMin {
+			return min
+		}
+		return max
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return
+		}
+
+		switch t.Kind() {
+		case reflect.String:
+			s[keyword("minLength", "maxLength")] = n + offset
+		case reflect.Map:
+			s[keyword("minProperties", "maxProperties")] = n + offset
+		default:
+			s[keyword("minItems", "maxItems")] = n + offset
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return
+		}
+
+		if exclusive {
+			s[keyword("exclusiveMinimum", "exclusiveMaximum")] = n
+			return
+		}
+		s[keyword("minimum", "maximum")] = n
+	}
+}
+
+// enumValue returns the value of a oneof rule as a value of the kind of the
+// type, so numbers are listed as numbers.
+func enumValue(t reflect.Type, v string) interface{} {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
+
+// nullable returns the schema allowing null as well.
+func nullable(s Schema) Schema {
+	if typ, ok := s["type"].(string); ok {
+		s["type"] = []string{typ, "null"}
+		return s
+	}
+	if len(s) == 0 {
+		return s
+	}
+	return Schema{"anyOf": []Schema{s, {"type": "null"}}}
+}
@@ -0,0 +1,312 @@
+// Package prometheus provides support for collecting metrics and exposing them
+// in the Prometheus text exposition format.
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets are the default buckets of histograms measuring seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds a set of metrics exposed together.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []*metric
+	names   map[string]bool
+}
+
+// NewRegistry constructs an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		names: make(map[string]bool),
+	}
+}
+
+// NewCounter registers a counter partitioned by the labels.
+func (r *Registry) NewCounter(name string, help string, labels ...string) *Counter {
+	return &Counter{r.register(name, help, "counter", labels, nil, nil)}
+}
+
+// NewCounterFunc registers a counter whose value is read from the function
+// when the metrics are collected.
+func (r *Registry) NewCounterFunc(name string, help string, fn func() float64) {
+	r.register(name, help, "counter", nil, nil, fn)
+}
+
+// NewGauge registers a gauge partitioned by the labels.
+func (r *Registry) NewGauge(name string, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(name, help, "gauge", labels, nil, nil)}
+}
+
+// NewGaugeFunc registers a gauge whose value is read from the function when
+// the metrics are collected.
+func (r *Registry) NewGaugeFunc(name string, help string, fn func() float64) {
+	r.register(name, help, "gauge", nil, nil, fn)
+}
+
+// NewHistogram registers a histogram with the upper bounds of the buckets in
+// increasing order, partitioned by the labels.
+func (r *Registry) NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("prometheus: buckets of %s are not sorted", name))
+	}
+	return &Histogram{r.register(name, help, "histogram", labels, buckets, nil)}
+}
+
+// ServeHTTP writes the metrics of the registry.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.Write(w)
+}
+
+// Write writes the metrics of the registry in the text exposition format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := make([]*metric, len(r.metrics))
+	copy(metrics, r.metrics)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// register adds a metric to the registry. Registering a name twice is a
+// programming error.
+func (r *Registry) register(name string, help string, typ string, labels []string, buckets []float64, fn func() float64) *metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[name] {
+		panic(fmt.Sprintf("prometheus: %s is already registered", name))
+	}
+	r.names[name] = true
+
+	m := metric{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  labels,
+		buckets: buckets,
+		fn:      fn,
+		series:  make(map[string]*series),
+	}
+	r.metrics = append(r.metrics, &m)
+
+	return &m
+}
+
+// =============================================================================
+
+// Counter is a value that only goes up.
+type Counter struct {
+	m *metric
+}
+
+// Inc adds one to the counter of the label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds the value, which must not be negative, to the counter of the label
+// values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("prometheus: counter %s can't decrease", c.m.name))
+	}
+	c.m.update(labelValues, func(s *series) { s.value += v })
+}
+
+// Gauge is a value that goes up and down.
+type Gauge struct {
+	m *metric
+}
+
+// Set sets the gauge of the label values.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.m.update(labelValues, func(s *series) { s.value = v })
+}
+
+// Add adds the value to the gauge of the label values.
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.m.update(labelValues, func(s *series) { s.value += v })
+}
+
+// Histogram counts observations in buckets.
+type Histogram struct {
+	m *metric
+}
+
+// Observe adds the value to the histogram of the label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.m.update(labelValues, func(s *series) {
+		if s.counts == nil {
+			s.counts = make([]uint64, len(h.m.buckets))
+		}
+		for i, upper := range h.m.buckets {
+			if v <= upper {
+				s.counts[i]++
+				break
+			}
+		}
+		s.count++
+		s.sum += v
+	})
+}
+
+// =============================================================================
+
+// metric is a family of series of the same name, one per set of label
+// values.
+type metric struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64
+	fn      func() float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series holds the value of a metric for a set of label values. Histograms
+// count the observations that fall in each bucket but not the ones before.
+type series struct {
+	values []string
+	value  float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// update applies the change to the series of the label values.
+func (m *metric) update(labelValues []string, change func(s *series)) {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("prometheus: %s has %d labels, got %d values", m.name, len(m.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, exists := m.series[key]
+	if !exists {
+		s = &series{values: append([]string(nil), labelValues...)}
+		m.series[key] = s
+	}
+	change(s)
+}
+
+// write writes the metric in the text exposition format, with the series
+// sorted by their label values.
+func (m *metric) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.typ)
+
+	if m.fn != nil {
+		fmt.Fprintf(w, "%s %s\n", m.name, formatFloat(m.fn()))
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]string, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := m.series[k]
+
+		if m.typ != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", m.name, labelPairs(m.labels, s.values, "", ""), formatFloat(s.value))
+			continue
+		}
+
+		var cumulative uint64
+		for i, upper := range m.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, labelPairs(m.labels, s.values, "le", formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, labelPairs(m.labels, s.values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", m.name, labelPairs(m.labels, s.values, "", ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", m.name, labelPairs(m.labels, s.values, "", ""), s.count)
+	}
+}
+
+// labelPairs formats the labels with their values, followed by the extra
+// label when there is one.
+func labelPairs(labels []string, values []string, extra string, extraValue string) string {
+	if len(labels) == 0 && extra == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	if extra != "" {
+		if len(labels) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extra)
+		b.WriteString(`="`)
+		b.WriteString(extraValue)
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+// formatFloat formats a sample value the way Prometheus expects.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// helpReplacer escapes the text of HELP lines.
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// labelReplacer escapes label values.
+var labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
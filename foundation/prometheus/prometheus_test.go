@@ -0,0 +1,72 @@
+package prometheus_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ardanlabs/service/foundation/prometheus"
+	"github.com/google/go-cmp/cmp"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestWrite(t *testing.T) {
+	r := prometheus.NewRegistry()
+
+	requests := r.NewCounter("requests_total", "Requests served.", "method", "path")
+	requests.Inc("GET", "/users")
+	requests.Add(2, "GET", "/users")
+	requests.Inc("POST", `/say "hi"`)
+
+	inFlight := r.NewGauge("in_flight", "Requests in flight.")
+	inFlight.Add(3)
+	inFlight.Add(-1)
+
+	r.NewGaugeFunc("answer", "The answer.\nOn two lines.", func() float64 { return 42 })
+
+	duration := r.NewHistogram("duration_seconds", "Request duration.", []float64{0.1, 1}, "method")
+	duration.Observe(0.05, "GET")
+	duration.Observe(0.5, "GET")
+	duration.Observe(5, "GET")
+
+	exp := `# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{method="GET",path="/users"} 3
+requests_total{method="POST",path="/say \"hi\""} 1
+# HELP in_flight Requests in flight.
+# TYPE in_flight gauge
+in_flight 2
+# HELP answer The answer.\nOn two lines.
+# TYPE answer gauge
+answer 42
+# HELP duration_seconds Request duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{method="GET",le="0.1"} 1
+duration_seconds_bucket{method="GET",le="1"} 2
+duration_seconds_bucket{method="GET",le="+Inf"} 3
+duration_seconds_sum{method="GET"} 5.55
+duration_seconds_count{method="GET"} 3
+`
+
+	t.Log("Given the need to expose metrics to Prometheus.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen writing the metrics of a registry.", testID)
+		{
+			var buf bytes.Buffer
+			if err := r.Write(&buf); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to write the metrics : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to write the metrics.", success, testID)
+
+			if diff := cmp.Diff(exp, buf.String()); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould follow the text exposition format. Diff:\n%s", failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould follow the text exposition format.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/foundation/totp"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestCode(t *testing.T) {
+
+	// The SHA1 test vectors from RFC 6238 appendix B, truncated to 6 digits.
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	t.Log("Given the need to generate TOTP codes.")
+	{
+		for testID, tt := range tests {
+			t.Logf("\tTest %d:\tWhen generating the code at %d.", testID, tt.unix)
+			{
+				code, err := totp.Code(secret, totp.Step(time.Unix(tt.unix, 0)))
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to generate a code : %v.", failed, testID, err)
+				}
+
+				if code != tt.code {
+					t.Fatalf("\t%s\tTest %d:\tShould match the RFC test vector : got %s, exp %s.", failed, testID, code, tt.code)
+				}
+				t.Logf("\t%s\tTest %d:\tShould match the RFC test vector.", success, testID)
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Log("Given the need to validate TOTP codes.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen validating codes around the current time.", testID)
+		{
+			secret, err := totp.GenerateSecret()
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a secret : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to generate a secret.", success, testID)
+
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+			code, err := totp.Code(secret, totp.Step(now.Add(-totp.Period)))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a code : %v.", failed, testID, err)
+			}
+
+			step, ok := totp.Validate(secret, code, now, 1)
+			if !ok || step != totp.Step(now)-1 {
+				t.Fatalf("\t%s\tTest %d:\tShould accept a code from the previous step.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept a code from the previous step.", success, testID)
+
+			if _, ok := totp.Validate(secret, code, now.Add(totp.Period), 1); ok {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept a code outside of the skew.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept a code outside of the skew.", success, testID)
+
+			uri := totp.URI("Sales", "admin@example.com", secret)
+			if !strings.HasPrefix(uri, "otpauth://totp/Sales:admin@example.com?") || !strings.Contains(uri, "secret="+secret) {
+				t.Fatalf("\t%s\tTest %d:\tShould build a provisioning URI : got %s.", failed, testID, uri)
+			}
+			t.Logf("\t%s\tTest %d:\tShould build a provisioning URI.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package tracer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Status codes of OTLP spans.
+const (
+	statusUnset = 0
+	statusError = 2
+)
+
+// OTLP exports spans to an OpenTelemetry collector with the OTLP/HTTP
+// protocol, using its JSON encoding.
+type OTLP struct {
+	endpoint string
+	service  string
+	headers  map[string]string
+	client   *http.Client
+}
+
+// NewOTLP constructs an exporter posting to the traces endpoint of a
+// collector, like http://localhost:4318/v1/traces, on behalf of the service.
+// The headers are added to every request, for collectors that require
+// authentication.
+func NewOTLP(endpoint string, service string, headers map[string]string, timeout time.Duration) *OTLP {
+	return &OTLP{
+		endpoint: endpoint,
+		service:  service,
+		headers:  headers,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+// Export posts the spans to the collector.
+func (o *OTLP) Export(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(o.request(spans))
+	if err != nil {
+		return fmt.Errorf("encoding spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting spans: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("collector responded %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
+
+// =============================================================================
+
+// The types below follow the JSON encoding of the OTLP
+// ExportTraceServiceRequest message. Ids are hex and 64 bit integers are
+// strings, as the encoding requires.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	TraceState        string          `json:"traceState,omitempty"`
+	Name              string          `json:"name"`
+	Kind              Kind            `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// request converts the spans into the request for the collector.
+func (o *OTLP) request(spans []SpanData) otlpRequest {
+	ss := otlpScopeSpans{
+		Scope: otlpScope{Name: "github.com/ardanlabs/service/foundation/tracer"},
+		Spans: make([]otlpSpan, len(spans)),
+	}
+
+	for i, sd := range spans {
+		s := otlpSpan{
+			TraceID:           sd.SpanContext.TraceID.String(),
+			SpanID:            sd.SpanContext.SpanID.String(),
+			TraceState:        sd.SpanContext.State,
+			Name:              sd.Name,
+			Kind:              sd.Kind,
+			StartTimeUnixNano: strconv.FormatInt(sd.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(sd.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(sd.Attributes),
+			Status:            otlpStatus{Code: statusUnset},
+		}
+		if sd.Parent.IsValid() {
+			s.ParentSpanID = sd.Parent.String()
+		}
+		if sd.Err != "" {
+			s.Status = otlpStatus{Code: statusError, Message: sd.Err}
+		}
+		ss.Spans[i] = s
+	}
+
+	return otlpRequest{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: otlpAttributes([]Attribute{String("service.name", o.service)}),
+				},
+				ScopeSpans: []otlpScopeSpans{ss},
+			},
+		},
+	}
+}
+
+// otlpAttributes converts the attributes, formatting values of unknown types
+// as strings.
+func otlpAttributes(attrs []Attribute) []otlpAttribute {
+	out := make([]otlpAttribute, len(attrs))
+	for i, a := range attrs {
+		var v otlpValue
+		switch val := a.Value.(type) {
+		case string:
+			v.StringValue = &val
+		case int64:
+			s := strconv.FormatInt(val, 10)
+			v.IntValue = &s
+		case bool:
+			v.BoolValue = &val
+		case float64:
+			v.DoubleValue = &val
+		default:
+			s := fmt.Sprint(val)
+			v.StringValue = &s
+		}
+		out[i] = otlpAttribute{Key: a.Key, Value: v}
+	}
+	return out
+}
@@ -0,0 +1,180 @@
+package tracer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Names of the headers the W3C Trace Context is propagated in.
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+)
+
+// maxTracestate is the length past which the tracestate header is dropped
+// rather than propagated.
+const maxTracestate = 512
+
+// ErrInvalidTraceparent is returned when a traceparent header doesn't follow
+// the W3C Trace Context format.
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// String returns the trace id as lowercase hex.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid reports if the trace id isn't all zeros.
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// String returns the span id as lowercase hex.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid reports if the span id isn't all zeros.
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// SpanContext is the part of a span that is propagated to other services: the
+// ids of the trace and the span, if the trace is sampled, and the vendor
+// specific tracestate.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	State   string
+}
+
+// IsValid reports if the span context identifies a span.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent returns the span context in the format of the traceparent
+// header.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent parses the value of the traceparent and tracestate
+// headers. Versions after 00 are parsed as far as version 00 goes, as the
+// specification asks.
+func ParseTraceparent(traceparent string, tracestate string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	version, ok := decodeHex(parts[0], 1)
+	if !ok || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	var sc SpanContext
+
+	traceID, ok := decodeHex(parts[1], 16)
+	if !ok {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	copy(sc.TraceID[:], traceID)
+
+	spanID, ok := decodeHex(parts[2], 8)
+	if !ok {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	copy(sc.SpanID[:], spanID)
+
+	flags, ok := decodeHex(parts[3], 1)
+	if !ok {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	sc.Sampled = flags[0]&0x01 == 0x01
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	if tracestate = strings.TrimSpace(tracestate); len(tracestate) <= maxTracestate {
+		sc.State = tracestate
+	}
+
+	return sc, nil
+}
+
+// Extract returns the span context propagated in the headers of a request,
+// if there is a valid one.
+func Extract(h http.Header) (SpanContext, bool) {
+	sc, err := ParseTraceparent(h.Get(HeaderTraceparent), strings.Join(h.Values(HeaderTracestate), ","))
+	if err != nil {
+		return SpanContext{}, false
+	}
+	return sc, true
+}
+
+// Inject sets the headers of a request to propagate the span in the context
+// to another service.
+func Inject(ctx context.Context, h http.Header) {
+	sc := SpanContextFrom(ctx)
+	if !sc.IsValid() {
+		return
+	}
+
+	h.Set(HeaderTraceparent, sc.Traceparent())
+	if sc.State != "" {
+		h.Set(HeaderTracestate, sc.State)
+	} else {
+		h.Del(HeaderTracestate)
+	}
+}
+
+// =============================================================================
+
+// decodeHex decodes n bytes of lowercase hex, which is the only case the
+// specification allows.
+func decodeHex(s string, n int) ([]byte, bool) {
+	if strings.ToLower(s) != s {
+		return nil, false
+	}
+
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != n {
+		return nil, false
+	}
+	return b, true
+}
+
+// newTraceID returns a random trace id.
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+// newSpanID returns a random span id.
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
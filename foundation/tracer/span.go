@@ -0,0 +1,149 @@
+package tracer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ctxKey represents the type of value for the context key.
+type ctxKey int
+
+// key is how the current span is stored/retrieved.
+const key ctxKey = 1
+
+// Attribute is a key value pair describing a span.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute.
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanData is what is exported about a span once it ends.
+type SpanData struct {
+	Name        string
+	Kind        Kind
+	SpanContext SpanContext
+	Parent      SpanID
+	Start       time.Time
+	End         time.Time
+	Attributes  []Attribute
+	Err         string
+}
+
+// Span is an operation within a trace.
+type Span struct {
+	tracer *Tracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// Start starts a span as a child of the span in the context, which is
+// returned in a new context. Without a span in the context the span does
+// nothing, so code can be traced whether it runs for a request or not.
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	parent, ok := ctx.Value(key).(*Span)
+	if !ok {
+		return ctx, &Span{ended: true}
+	}
+
+	psc := parent.SpanContext()
+
+	s := Span{
+		tracer: parent.tracer,
+		data: SpanData{
+			Name: name,
+			Kind: KindInternal,
+			SpanContext: SpanContext{
+				TraceID: psc.TraceID,
+				SpanID:  newSpanID(),
+				Sampled: psc.Sampled,
+				State:   psc.State,
+			},
+			Parent:     psc.SpanID,
+			Start:      time.Now(),
+			Attributes: attrs,
+		},
+	}
+
+	return context.WithValue(ctx, key, &s), &s
+}
+
+// SpanContextFrom returns the span context of the span in the context, which
+// is the zero value when there is none.
+func SpanContextFrom(ctx context.Context) SpanContext {
+	s, ok := ctx.Value(key).(*Span)
+	if !ok {
+		return SpanContext{}
+	}
+	return s.SpanContext()
+}
+
+// SpanContext returns the ids identifying the span.
+func (s *Span) SpanContext() SpanContext {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.data.SpanContext
+}
+
+// SetAttributes adds attributes describing the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return
+	}
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+}
+
+// RecordError marks the span as failed with the error.
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return
+	}
+	s.data.Err = err.Error()
+}
+
+// End ends the span, queueing it for export when the trace is sampled. Only
+// the first call has an effect.
+func (s *Span) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return
+	}
+	s.ended = true
+
+	if s.tracer == nil || s.tracer.exporter == nil || !s.data.SpanContext.Sampled {
+		return
+	}
+
+	s.data.End = time.Now()
+	s.tracer.record(s.data)
+}
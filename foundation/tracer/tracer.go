@@ -0,0 +1,202 @@
+// Package tracer provides support for tracing requests across services with
+// spans propagated using the W3C Trace Context headers and exported to an
+// OpenTelemetry collector.
+package tracer
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Kind is the role of a span in a trace, numbered as OTLP numbers them.
+type Kind int
+
+// Set of kinds of spans.
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// Exporter sends ended spans to where traces are collected.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+// Config is the required properties to use the tracer. Probability is the
+// fraction of the traces started by this service that are sampled, traces
+// propagated from callers are sampled when the caller sampled them. Without
+// an Exporter spans get ids but are not recorded.
+type Config struct {
+	Exporter    Exporter
+	Probability float64
+	BatchSize   int
+	Interval    time.Duration
+}
+
+// Tracer starts spans and exports them in batches.
+type Tracer struct {
+	log         *zap.SugaredLogger
+	exporter    Exporter
+	probability float64
+	batchSize   int
+	interval    time.Duration
+	spans       chan SpanData
+	shutdown    chan struct{}
+	done        chan struct{}
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// New constructs a tracer and starts exporting the spans that end. Shutdown
+// must be called to export the spans that are left.
+func New(log *zap.SugaredLogger, cfg Config) *Tracer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 512
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Second
+	}
+
+	t := Tracer{
+		log:         log,
+		exporter:    cfg.Exporter,
+		probability: cfg.Probability,
+		batchSize:   cfg.BatchSize,
+		interval:    cfg.Interval,
+		spans:       make(chan SpanData, 4*cfg.BatchSize),
+		shutdown:    make(chan struct{}),
+		done:        make(chan struct{}),
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	if t.exporter == nil {
+		close(t.done)
+		return &t
+	}
+
+	go t.run()
+
+	return &t
+}
+
+// Shutdown stops exporting spans once the spans that already ended are
+// exported.
+func (t *Tracer) Shutdown() {
+	if t.exporter != nil {
+		close(t.shutdown)
+	}
+	<-t.done
+}
+
+// StartServer starts the span of a request served for the caller whose span
+// is the parent. Without a valid parent the span starts a new trace. The
+// tracer can be nil, in which case the span gets ids but isn't recorded.
+func (t *Tracer) StartServer(ctx context.Context, parent SpanContext, name string, attrs ...Attribute) (context.Context, *Span) {
+	s := Span{
+		tracer: t,
+		data: SpanData{
+			Name:       name,
+			Kind:       KindServer,
+			Start:      time.Now(),
+			Attributes: attrs,
+		},
+	}
+
+	switch {
+	case parent.IsValid():
+		s.data.SpanContext = SpanContext{
+			TraceID: parent.TraceID,
+			SpanID:  newSpanID(),
+			Sampled: parent.Sampled,
+			State:   parent.State,
+		}
+		s.data.Parent = parent.SpanID
+
+	default:
+		s.data.SpanContext = SpanContext{
+			TraceID: newTraceID(),
+			SpanID:  newSpanID(),
+			Sampled: t.sample(),
+		}
+	}
+
+	return context.WithValue(ctx, key, &s), &s
+}
+
+// sample decides if a new trace is sampled.
+func (t *Tracer) sample() bool {
+	if t == nil || t.exporter == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.rand.Float64() < t.probability
+}
+
+// record queues the span for export. Spans are dropped rather than slowing
+// requests down when the exporter falls behind.
+func (t *Tracer) record(sd SpanData) {
+	select {
+	case t.spans <- sd:
+	default:
+		t.log.Errorw("tracer", "status", "dropping span, export queue is full", "name", sd.Name)
+	}
+}
+
+// run exports the spans that end in batches, at least once per interval.
+func (t *Tracer) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, t.batchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := t.exporter.Export(ctx, batch); err != nil {
+			t.log.Errorw("tracer", "status", "exporting spans", "spans", len(batch), "ERROR", err)
+		}
+		batch = make([]SpanData, 0, t.batchSize)
+	}
+
+	for {
+		select {
+		case sd := <-t.spans:
+			batch = append(batch, sd)
+			if len(batch) == t.batchSize {
+				export()
+			}
+
+		case <-ticker.C:
+			export()
+
+		case <-t.shutdown:
+			for {
+				select {
+				case sd := <-t.spans:
+					batch = append(batch, sd)
+					if len(batch) == t.batchSize {
+						export()
+					}
+				default:
+					export()
+					return
+				}
+			}
+		}
+	}
+}
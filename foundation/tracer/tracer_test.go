@@ -0,0 +1,163 @@
+package tracer_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/foundation/tracer"
+	"go.uber.org/zap"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+const (
+	traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	spanID  = "00f067aa0ba902b7"
+)
+
+func TestTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+		valid       bool
+		sampled     bool
+	}{
+		{"sampled", "00-" + traceID + "-" + spanID + "-01", true, true},
+		{"not sampled", "00-" + traceID + "-" + spanID + "-00", true, false},
+		{"future version", "01-" + traceID + "-" + spanID + "-01-extra", true, true},
+		{"version ff", "ff-" + traceID + "-" + spanID + "-01", false, false},
+		{"extra field", "00-" + traceID + "-" + spanID + "-01-extra", false, false},
+		{"uppercase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-" + spanID + "-01", false, false},
+		{"zero trace id", "00-00000000000000000000000000000000-" + spanID + "-01", false, false},
+		{"zero span id", "00-" + traceID + "-0000000000000000-01", false, false},
+		{"short", "00-" + traceID + "-01", false, false},
+		{"empty", "", false, false},
+	}
+
+	t.Log("Given the need to parse W3C traceparent headers.")
+	{
+		for testID, tt := range tests {
+			t.Logf("\tTest %d:\tWhen parsing a %s traceparent.", testID, tt.name)
+			{
+				sc, err := tracer.ParseTraceparent(tt.traceparent, "congo=t61rcWkgMzE")
+				if (err == nil) != tt.valid {
+					t.Fatalf("\t%s\tTest %d:\tShould be valid %v : %v.", failed, testID, tt.valid, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be valid %v.", success, testID, tt.valid)
+
+				if !tt.valid {
+					continue
+				}
+
+				if sc.TraceID.String() != traceID || sc.SpanID.String() != spanID || sc.Sampled != tt.sampled || sc.State != "congo=t61rcWkgMzE" {
+					t.Fatalf("\t%s\tTest %d:\tShould get the ids, flags and state : %+v.", failed, testID, sc)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get the ids, flags and state.", success, testID)
+			}
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	var mu sync.Mutex
+	var received []map[string]interface{}
+
+	// The collector stand-in records the spans posted to it.
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []map[string]interface{} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				received = append(received, ss.Spans...)
+			}
+		}
+	}))
+	defer collector.Close()
+
+	cfg := tracer.Config{
+		Exporter:    tracer.NewOTLP(collector.URL+"/v1/traces", "test", nil, time.Second),
+		Probability: 1,
+		Interval:    time.Hour,
+	}
+	tr := tracer.New(zap.NewNop().Sugar(), cfg)
+
+	parent, err := tracer.ParseTraceparent("00-"+traceID+"-"+spanID+"-01", "")
+	if err != nil {
+		t.Fatalf("Should be able to parse the traceparent : %s.", err)
+	}
+
+	ctx, span := tr.StartServer(context.Background(), parent, "GET /test")
+	_, child := tracer.Start(ctx, "child", tracer.Int("rows", 3))
+	child.RecordError(errors.New("boom"))
+	child.End()
+	span.End()
+
+	h := make(http.Header)
+	tracer.Inject(ctx, h)
+
+	tr.Shutdown()
+
+	t.Log("Given the need to export spans to a collector.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen ending spans of a sampled trace.", testID)
+		{
+			mu.Lock()
+			defer mu.Unlock()
+
+			if len(received) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould export both spans on shutdown : got %d.", failed, testID, len(received))
+			}
+			t.Logf("\t%s\tTest %d:\tShould export both spans on shutdown.", success, testID)
+
+			c, s := received[0], received[1]
+			if s["traceId"] != traceID || s["parentSpanId"] != spanID {
+				t.Fatalf("\t%s\tTest %d:\tShould continue the caller's trace : %v.", failed, testID, s)
+			}
+			t.Logf("\t%s\tTest %d:\tShould continue the caller's trace.", success, testID)
+
+			if c["traceId"] != traceID || c["parentSpanId"] != s["spanId"] {
+				t.Fatalf("\t%s\tTest %d:\tShould nest the child span : %v.", failed, testID, c)
+			}
+			t.Logf("\t%s\tTest %d:\tShould nest the child span.", success, testID)
+
+			status, _ := c["status"].(map[string]interface{})
+			if status["code"] != 2.0 || status["message"] != "boom" {
+				t.Fatalf("\t%s\tTest %d:\tShould record the error : %v.", failed, testID, c["status"])
+			}
+			t.Logf("\t%s\tTest %d:\tShould record the error.", success, testID)
+
+			exp := "00-" + traceID + "-" + s["spanId"].(string) + "-01"
+			if got := h.Get(tracer.HeaderTraceparent); got != exp {
+				t.Fatalf("\t%s\tTest %d:\tShould propagate the span : got %s, exp %s.", failed, testID, got, exp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould propagate the span.", success, testID)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"time"
+
+	"github.com/ardanlabs/service/foundation/tracer"
 )
 
 // ctxKey represents the type of value for the context key.
@@ -12,10 +14,14 @@ type ctxKey int
 // key is how request values are stored/retrieved.
 const key ctxKey = 1
 
-// Values represent state for each request.
+// Values represent state for each request. Trace identifies the span of the
+// request, which continues the trace of the caller when the request carries
+// a traceparent header, and TraceID is the id of that trace.
 type Values struct {
 	TraceID    string
+	Trace      tracer.SpanContext
 	Now        time.Time
+	RemoteIP   string
 	StatusCode int
 }
 
@@ -32,11 +38,20 @@ func GetValues(ctx context.Context) (*Values, error) {
 func GetTraceID(ctx context.Context) string {
 	v, ok := ctx.Value(key).(*Values)
 	if !ok {
-		return "00000000-0000-0000-0000-000000000000"
+		return "00000000000000000000000000000000"
 	}
 	return v.TraceID
 }
 
+// GetRemoteIP returns the IP address of the client from the context.
+func GetRemoteIP(ctx context.Context) string {
+	v, ok := ctx.Value(key).(*Values)
+	if !ok {
+		return ""
+	}
+	return v.RemoteIP
+}
+
 // SetStatusCode sets the status code back into the context.
 func SetStatusCode(ctx context.Context, statusCode int) error {
 	v, ok := ctx.Value(key).(*Values)
@@ -0,0 +1,44 @@
+package web
+
+// Group is a set of routes sharing a path prefix and middleware. Groups can
+// be nested, composing the prefixes and the middleware of each level.
+type Group struct {
+	app    *App
+	prefix string
+	mw     []Middleware
+}
+
+// Group returns a group of routes under the path prefix. The middleware of
+// the group runs after the application's general middleware and before the
+// middleware of each route.
+func (a *App) Group(prefix string, mw ...Middleware) *Group {
+	return &Group{
+		app:    a,
+		prefix: prefix,
+		mw:     mw,
+	}
+}
+
+// Group returns a group nested in this one, under the path prefix joined to
+// the prefix of this group. Its middleware runs after this group's.
+func (g *Group) Group(prefix string, mw ...Middleware) *Group {
+	return &Group{
+		app:    g.app,
+		prefix: g.prefix + prefix,
+		mw:     g.combine(mw),
+	}
+}
+
+// Handle sets a handler function for a given HTTP method and a path relative
+// to the prefix of the group. It returns the route so it can be documented.
+func (g *Group) Handle(method string, path string, handler Handler, mw ...Middleware) *Route {
+	return g.app.Handle(method, g.prefix+path, handler, g.combine(mw)...)
+}
+
+// combine returns the middleware of the group followed by the specified
+// middleware, without sharing the backing array of the group's slice.
+func (g *Group) combine(mw []Middleware) []Middleware {
+	all := make([]Middleware, 0, len(g.mw)+len(mw))
+	all = append(all, g.mw...)
+	return append(all, mw...)
+}
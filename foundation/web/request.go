@@ -2,7 +2,10 @@ package web
 
 import (
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/dimfeld/httptreemux/v5"
 )
@@ -13,6 +16,12 @@ func Param(r *http.Request, key string) string {
 	return m[key]
 }
 
+// RoutePattern returns the pattern of the route that matched the request, like
+// /v1/users/:id, or an empty string when no route matched.
+func RoutePattern(r *http.Request) string {
+	return httptreemux.ContextRoute(r.Context())
+}
+
 // Decode reads the body of an HTTP request looking for a JSON document. The
 // body is decoded into the provided value.
 //
@@ -26,3 +35,79 @@ func Decode(r *http.Request, val interface{}) error {
 
 	return nil
 }
+
+// ParseNetworks parses a list of networks in CIDR notation. A single IP
+// address is taken as a network of its own.
+func ParseNetworks(list []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		if ip := net.ParseIP(s); ip != nil {
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			bits := 8 * len(ip)
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("parsing network %q: %w", s, err)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// remoteIP returns the IP address of the client that sent the request. When
+// the request comes from a trusted proxy, the X-Forwarded-For header is read
+// from the right, where each proxy appended the address it got the request
+// from, up to the first address that isn't a trusted proxy. The addresses
+// further left are set by the client and can't be trusted.
+func remoteIP(r *http.Request, proxies []*net.IPNet) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	if !contains(proxies, ip) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			return ip
+		}
+
+		ip = hop
+		if !contains(proxies, ip) {
+			return ip
+		}
+	}
+
+	return ip
+}
+
+// contains reports if the IP address is in any of the networks.
+func contains(networks []*net.IPNet, s string) bool {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
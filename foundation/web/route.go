@@ -0,0 +1,72 @@
+package web
+
+import "strings"
+
+// Route describes a route registered with the App, so the API can be
+// documented from the routes it serves.
+type Route struct {
+	Method string
+	Path   string
+	Params []string
+	Doc    Doc
+}
+
+// Doc documents how a route is used. Request and Response hold a value of
+// the type of the body, or nil when there is none. Status is the status of a
+// successful response, which defaults to 200 with a response body and 204
+// without one. Routes require authentication unless they are Public, and
+// Requires lists what the middleware of the route enforces on top of it.
+// Every requirement must be met.
+type Doc struct {
+	Summary  string
+	Query    []QueryParam
+	Request  interface{}
+	Response interface{}
+	Status   int
+	Public   bool
+	Requires []Requirement
+}
+
+// Requirement lists roles or permissions, any of which meets the requirement.
+type Requirement []string
+
+// QueryParam documents a query string parameter. The type is a JSON schema
+// type and defaults to string.
+type QueryParam struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+// Describe sets the documentation of the route.
+func (r *Route) Describe(doc Doc) *Route {
+	r.Doc = doc
+	return r
+}
+
+// Routes returns the routes registered with the App in the order they were
+// registered.
+func (a *App) Routes() []Route {
+	routes := make([]Route, len(a.routes))
+	for i, r := range a.routes {
+		routes[i] = *r
+	}
+	return routes
+}
+
+// addRoute records a route registered with the App.
+func (a *App) addRoute(method string, path string) *Route {
+	r := Route{
+		Method: method,
+		Path:   path,
+	}
+
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			r.Params = append(r.Params, segment[1:])
+		}
+	}
+
+	a.routes = append(a.routes, &r)
+	return &r
+}
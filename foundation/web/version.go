@@ -0,0 +1,114 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrUnsupportedVersion is returned to the middleware of the App for requests
+// asking for a version of the API that doesn't exist, which can't be served.
+var ErrUnsupportedVersion = errors.New("API version is not supported")
+
+// Version returns the group of routes for a version of the API, like "v1",
+// served under the version as a path prefix. Requests to paths without a
+// version prefix are routed to the version named by the version parameter of
+// their Accept header media type, and otherwise to the default version, when
+// a route exists there.
+func (a *App) Version(version string, mw ...Middleware) *Group {
+	a.versions[version] = true
+	return a.Group("/"+version, mw...)
+}
+
+// SetDefaultVersion sets the version requests without a version are routed
+// to. Without a default version those requests are routed as they are.
+func (a *App) SetDefaultVersion(version string) {
+	a.defaultVersion = version
+}
+
+// ServeHTTP routes the request to the handler of its route, after finding
+// the version of the API it asks for.
+func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(a.versions) == 0 || a.versions[firstSegment(r.URL.Path)] {
+		a.ContextMux.ServeHTTP(w, r)
+		return
+	}
+
+	// The failure goes through the middleware of the App like the failures
+	// of any route, so it is logged, counted and answered the same way.
+	version, err := a.requestedVersion(r)
+	if err != nil {
+		reject := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return err
+		}
+		a.serve(r.Method, r.URL.Path, reject).ServeHTTP(w, r)
+		return
+	}
+
+	// Routes that exist outside of the versions, and paths that don't exist
+	// in the version, are served as they are.
+	if version != "" {
+		vr := withPrefix(r, "/"+version)
+		if lr, found := a.ContextMux.Lookup(w, vr); found {
+			a.ContextMux.ServeLookupResult(w, vr, lr)
+			return
+		}
+	}
+
+	a.ContextMux.ServeHTTP(w, r)
+}
+
+// requestedVersion returns the version named by the version parameter of the
+// Accept header, or the default version when there is none. Naming a version
+// that doesn't exist is an error.
+func (a *App) requestedVersion(r *http.Request) (string, error) {
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		_, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+
+		version, exists := params["version"]
+		if !exists {
+			continue
+		}
+
+		if !strings.HasPrefix(version, "v") {
+			version = "v" + version
+		}
+		if !a.versions[version] {
+			return "", fmt.Errorf("version %q: %w", version, ErrUnsupportedVersion)
+		}
+
+		return version, nil
+	}
+
+	return a.defaultVersion, nil
+}
+
+// firstSegment returns the first segment of the path.
+func firstSegment(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+// withPrefix returns a copy of the request for the path with the prefix.
+func withPrefix(r *http.Request, prefix string) *http.Request {
+	r2 := r.Clone(r.Context())
+
+	r2.URL.Path = prefix + r.URL.Path
+	if r.URL.RawPath != "" {
+		r2.URL.RawPath = prefix + r.URL.RawPath
+	}
+	if strings.HasPrefix(r.RequestURI, "/") {
+		r2.RequestURI = prefix + r.RequestURI
+	}
+
+	return r2
+}
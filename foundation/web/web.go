@@ -3,13 +3,15 @@ package web
 
 import (
 	"context"
+	"errors"
+	"net"
 	"net/http"
 	"os"
 	"syscall"
 	"time"
 
+	"github.com/ardanlabs/service/foundation/tracer"
 	"github.com/dimfeld/httptreemux/v5"
-	"github.com/google/uuid"
 )
 
 // A Handler is a type that handles a http request within our own little mini
@@ -21,8 +23,13 @@ type Handler func(ctx context.Context, w http.ResponseWriter, r *http.Request) e
 // data/logic on this App struct.
 type App struct {
 	*httptreemux.ContextMux
-	shutdown chan os.Signal
-	mw       []Middleware
+	shutdown       chan os.Signal
+	mw             []Middleware
+	versions       map[string]bool
+	defaultVersion string
+	routes         []*Route
+	tracer         *tracer.Tracer
+	proxies        []*net.IPNet
 }
 
 // NewApp creates an App value that handle a set of routes for the application.
@@ -31,6 +38,7 @@ func NewApp(shutdown chan os.Signal, mw ...Middleware) *App {
 		ContextMux: httptreemux.NewContextMux(),
 		shutdown:   shutdown,
 		mw:         mw,
+		versions:   make(map[string]bool),
 	}
 }
 
@@ -40,32 +48,75 @@ func (a *App) SignalShutdown() {
 	a.shutdown <- syscall.SIGTERM
 }
 
+// SetTracer sets the tracer recording a span for each request. Without one
+// requests still continue the trace of their caller, but no spans are
+// recorded.
+func (a *App) SetTracer(t *tracer.Tracer) {
+	a.tracer = t
+}
+
+// SetTrustedProxies sets the networks of the proxies in front of the App. The
+// IP address of the client is read from the X-Forwarded-For header of the
+// requests they forward. Without any, the header is ignored since any client
+// can set it, and clients behind a proxy all share the address of the proxy.
+func (a *App) SetTrustedProxies(proxies []*net.IPNet) {
+	a.proxies = proxies
+}
+
 // Handle sets a handler function for a given HTTP method and path pair
-// to the application server mux.
-func (a *App) Handle(method string, path string, handler Handler, mw ...Middleware) {
+// to the application server mux. It returns the route so it can be
+// documented.
+func (a *App) Handle(method string, path string, handler Handler, mw ...Middleware) *Route {
 
 	// First wrap handler specific middleware around this handler.
 	handler = wrapMiddleware(mw, handler)
 
+	a.ContextMux.Handle(method, path, a.serve(method, path, handler))
+
+	return a.addRoute(method, path)
+}
+
+// serve returns the function serving the requests of a route with the
+// handler, wrapped in the application's general middleware.
+func (a *App) serve(method string, path string, handler Handler) http.HandlerFunc {
+
 	// Add the application's general middleware to the handler chain.
 	handler = wrapMiddleware(a.mw, handler)
 
 	h := func(w http.ResponseWriter, r *http.Request) {
 
+		// Continue the trace of the caller, if it propagated one.
+		parent, _ := tracer.Extract(r.Header)
+		ctx, span := a.tracer.StartServer(r.Context(), parent, method+" "+path,
+			tracer.String("http.method", r.Method),
+			tracer.String("http.route", path),
+			tracer.String("http.target", r.URL.RequestURI()),
+		)
+		defer span.End()
+
 		// Set the context with the required values to
 		// process the request.
+		sc := span.SpanContext()
 		v := Values{
-			TraceID: uuid.NewString(),
-			Now:     time.Now().UTC(),
+			TraceID:  sc.TraceID.String(),
+			Trace:    sc,
+			Now:      time.Now().UTC(),
+			RemoteIP: remoteIP(r, a.proxies),
 		}
-		ctx := context.WithValue(r.Context(), key, &v)
+		ctx = context.WithValue(ctx, key, &v)
 
 		// Call the wrapped handler functions.
 		if err := handler(ctx, w, r); err != nil {
+			span.RecordError(err)
 			a.SignalShutdown()
 			return
 		}
+
+		span.SetAttributes(tracer.Int("http.status_code", v.StatusCode))
+		if v.StatusCode >= http.StatusInternalServerError {
+			span.RecordError(errors.New(http.StatusText(v.StatusCode)))
+		}
 	}
 
-	a.ContextMux.Handle(method, path, h)
+	return h
 }
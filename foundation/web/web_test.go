@@ -0,0 +1,245 @@
+package web_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ardanlabs/service/foundation/web"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// respond returns a handler that writes the text as the body.
+func respond(text string) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte(text))
+		return err
+	}
+}
+
+// tag returns middleware that appends the name to the X-Chain header, so the
+// order middleware runs in can be checked.
+func tag(name string) web.Middleware {
+	return func(handler web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			w.Header().Add("X-Chain", name)
+			return handler(ctx, w, r)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	app := web.NewApp(make(chan os.Signal, 1), tag("app"))
+
+	api := app.Group("/api", tag("api"))
+	users := api.Group("/users", tag("users"))
+	users.Handle(http.MethodGet, "/:id", respond("user"), tag("route"))
+	api.Handle(http.MethodGet, "/status", respond("status"))
+
+	t.Log("Given the need to group routes.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen calling a route of a nested group.", testID)
+		{
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/users/42", nil))
+
+			if w.Body.String() != "user" {
+				t.Fatalf("\t%s\tTest %d:\tShould reach the route under both prefixes : %d %s.", failed, testID, w.Code, w.Body)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reach the route under both prefixes.", success, testID)
+
+			exp := "app,api,users,route"
+			if got := strings.Join(w.Header()["X-Chain"], ","); got != exp {
+				t.Fatalf("\t%s\tTest %d:\tShould run the middleware outside in : got %s, exp %s.", failed, testID, got, exp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould run the middleware outside in.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen calling a route of the parent group.", testID)
+		{
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+
+			exp := "app,api"
+			if got := strings.Join(w.Header()["X-Chain"], ","); w.Body.String() != "status" || got != exp {
+				t.Fatalf("\t%s\tTest %d:\tShould only run the parent's middleware : got %s, exp %s.", failed, testID, got, exp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only run the parent's middleware.", success, testID)
+		}
+	}
+}
+
+func TestVersion(t *testing.T) {
+
+	// The App leaves answering the requests for unsupported versions to its
+	// middleware, like the failures of its routes.
+	reject := func(handler web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			err := handler(ctx, w, r)
+			if errors.Is(err, web.ErrUnsupportedVersion) {
+				return web.Respond(ctx, w, err.Error(), http.StatusNotAcceptable)
+			}
+			return err
+		}
+	}
+	app := web.NewApp(make(chan os.Signal, 1), reject)
+
+	app.Version("v1").Handle(http.MethodGet, "/users", respond("v1"))
+	app.Version("v2").Handle(http.MethodGet, "/users", respond("v2"))
+	app.Handle(http.MethodGet, "/health", respond("health"))
+	app.SetDefaultVersion("v1")
+
+	tests := []struct {
+		path   string
+		accept string
+		status int
+		body   string
+	}{
+		{"/v1/users", "", http.StatusOK, "v1"},
+		{"/v2/users", "", http.StatusOK, "v2"},
+		{"/users", "", http.StatusOK, "v1"},
+		{"/users", "application/json; version=2", http.StatusOK, "v2"},
+		{"/users", "text/html, application/json;version=v2", http.StatusOK, "v2"},
+		{"/v1/users", "application/json; version=2", http.StatusOK, "v1"},
+		{"/users", "application/json; version=9", http.StatusNotAcceptable, ""},
+		{"/health", "", http.StatusOK, "health"},
+		{"/health", "application/json; version=2", http.StatusOK, "health"},
+		{"/missing", "", http.StatusNotFound, ""},
+	}
+
+	t.Log("Given the need to route requests by API version.")
+	{
+		for testID, tt := range tests {
+			t.Logf("\tTest %d:\tWhen requesting %s with Accept %q.", testID, tt.path, tt.accept)
+			{
+				r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+				if tt.accept != "" {
+					r.Header.Set("Accept", tt.accept)
+				}
+				w := httptest.NewRecorder()
+				app.ServeHTTP(w, r)
+
+				if w.Code != tt.status {
+					t.Fatalf("\t%s\tTest %d:\tShould get status %d : got %d.", failed, testID, tt.status, w.Code)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get status %d.", success, testID, tt.status)
+
+				if tt.body != "" && w.Body.String() != tt.body {
+					t.Fatalf("\t%s\tTest %d:\tShould be routed to %s : got %s.", failed, testID, tt.body, w.Body)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be routed to the expected version.", success, testID)
+			}
+		}
+	}
+}
+
+func TestTraceContext(t *testing.T) {
+	app := web.NewApp(make(chan os.Signal, 1))
+
+	app.Handle(http.MethodGet, "/trace", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		v, err := web.GetValues(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write([]byte(v.TraceID + " " + v.Trace.State))
+		return err
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	t.Log("Given the need to continue the trace of the caller.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the request carries a traceparent header.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/trace", nil)
+			r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+			r.Header.Set("tracestate", "congo=t61rcWkgMzE")
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			exp := traceID + " congo=t61rcWkgMzE"
+			if w.Body.String() != exp {
+				t.Fatalf("\t%s\tTest %d:\tShould use the trace of the caller : got %q, exp %q.", failed, testID, w.Body, exp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould use the trace of the caller.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the request carries an invalid traceparent header.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/trace", nil)
+			r.Header.Set("traceparent", "00-"+traceID+"-0000000000000000-01")
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			got := strings.TrimSpace(w.Body.String())
+			if len(got) != 32 || got == traceID {
+				t.Fatalf("\t%s\tTest %d:\tShould start a new trace : got %q.", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould start a new trace.", success, testID)
+		}
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	proxies, err := web.ParseNetworks([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("parsing the proxies: %v", err)
+	}
+
+	app := web.NewApp(make(chan os.Signal, 1))
+	app.SetTrustedProxies(proxies)
+	app.Handle(http.MethodGet, "/ip", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte(web.GetRemoteIP(ctx)))
+		return err
+	})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		exp        string
+	}{
+		{"a client connecting directly", "203.0.113.7:1234", "", "203.0.113.7"},
+		{"a client forging the header", "203.0.113.7:1234", "198.51.100.1", "203.0.113.7"},
+		{"a client behind a trusted proxy", "10.0.0.2:1234", "198.51.100.1", "198.51.100.1"},
+		{"a client behind trusted proxies", "10.0.0.2:1234", "198.51.100.1, 192.168.1.1", "198.51.100.1"},
+		{"a client forging the header behind a trusted proxy", "10.0.0.2:1234", "192.0.2.9, 198.51.100.1", "198.51.100.1"},
+		{"a trusted proxy without the header", "10.0.0.2:1234", "", "10.0.0.2"},
+		{"a trusted proxy with a malformed header", "10.0.0.2:1234", "garbage", "10.0.0.2"},
+	}
+
+	t.Log("Given the need to know the IP address of the client.")
+	{
+		for testID, tt := range tests {
+			t.Logf("\tTest %d:\tWhen handling a request from %s.", testID, tt.name)
+			{
+				r := httptest.NewRequest(http.MethodGet, "/ip", nil)
+				r.RemoteAddr = tt.remoteAddr
+				if tt.forwarded != "" {
+					r.Header.Set("X-Forwarded-For", tt.forwarded)
+				}
+
+				w := httptest.NewRecorder()
+				app.ServeHTTP(w, r)
+
+				if got := w.Body.String(); got != tt.exp {
+					t.Fatalf("\t%s\tTest %d:\tShould resolve the client to %s : got %s.", failed, testID, tt.exp, got)
+				}
+				t.Logf("\t%s\tTest %d:\tShould resolve the client to %s.", success, testID, tt.exp)
+			}
+		}
+	}
+}